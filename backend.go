@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/cache"
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/db/memory"
+	"github.com/Bruary/twitter-clone/db/mongodb"
+	"github.com/Bruary/twitter-clone/db/postgres"
+	"github.com/Bruary/twitter-clone/db/sqlite"
+	"github.com/Bruary/twitter-clone/lockout"
+	"github.com/Bruary/twitter-clone/oidc"
+	"github.com/Bruary/twitter-clone/password"
+	"github.com/Bruary/twitter-clone/revocation"
+	"github.com/Bruary/twitter-clone/token"
+	"github.com/Bruary/twitter-clone/validate"
+	"github.com/go-redis/redis/v8"
+)
+
+// openBackend connects to the storage backend chosen by DB_BACKEND,
+// close releases its connections once the service is done with it
+func openBackend(ctx context.Context, cfg *config.Config, logger *log.Logger) (store db.Store, migrator db.Migrator, close func() error, err error) {
+
+	switch cfg.DB.Backend {
+	case config.BackendMongo:
+		database, err := mongodb.Connect(ctx, cfg.Mongo)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		logger.Println("Connected to MongoDB!")
+
+		close := func() error {
+			return database.Client().Disconnect(context.Background())
+		}
+
+		return mongodb.New(database, logger), mongodb.NewMigrator(database), close, nil
+
+	case config.BackendPostgres:
+		database, err := postgres.Open(ctx, cfg.Postgres)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		logger.Println("Connected to PostgreSQL!")
+
+		return postgres.New(database), postgres.NewMigrator(database), database.Close, nil
+
+	case config.BackendSQLite:
+		database, err := sqlite.Open(ctx, cfg.SQLite)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		logger.Println("Opened SQLite database " + cfg.SQLite.Path + "!")
+
+		return sqlite.New(database), sqlite.NewMigrator(database), database.Close, nil
+
+	case config.BackendMemory:
+		logger.Println("Using the in-memory store, nothing will be persisted!")
+
+		return memory.New(), noMigrations{}, func() error { return nil }, nil
+	}
+
+	return nil, nil, nil, fmt.Errorf("unknown DB_BACKEND %q", cfg.DB.Backend)
+}
+
+// serviceCache is the cache chosen by CACHE_BACKEND, it counts its hits, misses and errors
+type serviceCache struct {
+	*cache.Counting
+
+	backend  string
+	fallback *cache.Fallback // nil unless the backend is redis
+	redis    *redis.Client   // nil unless the backend is redis
+	close    func() error
+}
+
+// cacheStatus is the body of /debug/cache
+type cacheStatus struct {
+	Backend string `json:"backend"`
+	cache.Stats
+	*cache.FallbackStats
+}
+
+// openCache sets up the cache chosen by CACHE_BACKEND. Redis being down does not fail the startup,
+// the in-process cache is used until it is back.
+func openCache(ctx context.Context, cfg *config.Config, logger *log.Logger) *serviceCache {
+
+	switch cfg.Cache.Backend {
+	case config.CacheNone:
+		return &serviceCache{Counting: cache.WithStats(cache.Nop()), backend: cfg.Cache.Backend, close: func() error { return nil }}
+	case config.CacheMemory:
+		memoryCache := cache.NewMemory(clock.Real(), cfg.Cache.MaxEntries)
+		return &serviceCache{Counting: cache.WithStats(memoryCache), backend: cfg.Cache.Backend, close: func() error { return nil }}
+	}
+
+	client := cache.NewRedisClient(cfg.Redis)
+
+	fallback := cache.NewFallback(
+		cache.NewRedis(client, cfg.Redis.Timeout),
+		cache.NewMemory(clock.Real(), cfg.Cache.MaxEntries),
+		cfg.Redis.ReconnectMinBackoff,
+		cfg.Redis.ReconnectMaxBackoff,
+		logger,
+	)
+
+	// a failed ping starts the cache degraded
+	if err := fallback.Ping(ctx); err == nil {
+		logger.Println("Connected to Redis!")
+	}
+
+	return &serviceCache{
+		Counting: cache.WithStats(fallback),
+		backend:  cfg.Cache.Backend,
+		fallback: fallback,
+		redis:    client,
+		close: func() error {
+			fallback.Close()
+			return client.Close()
+		},
+	}
+}
+
+func (c *serviceCache) Status() cacheStatus {
+	status := cacheStatus{
+		Backend: c.backend,
+		Stats:   c.Stats(),
+	}
+
+	if c.fallback != nil {
+		fallbackStats := c.fallback.Stats()
+		status.FallbackStats = &fallbackStats
+	}
+
+	return status
+}
+
+// openRevocations keeps the revoked tokens in redis when it is the cache, so that every instance sees them,
+// and in-process for the calls redis fails unless JWT_REVOCATION_FAIL_CLOSED is set.
+// Otherwise they are kept in-process, which only fits a single instance.
+func openRevocations(cfg *config.Config, appCache *serviceCache, logger *log.Logger) revocation.Store {
+
+	// the revocations of a user must outlive the longest lived token
+	memory := revocation.NewMemory(clock.Real(), cfg.JWT.RefreshTokenTTL)
+
+	if appCache.redis == nil {
+		return memory
+	}
+
+	shared := revocation.NewRedis(appCache.redis, cfg.Redis.Timeout, cfg.JWT.RefreshTokenTTL)
+	if cfg.JWT.RevocationFailClosed {
+		return shared
+	}
+
+	return revocation.NewFallback(shared, memory, logger)
+}
+
+// openLockout counts the failed sign ins in redis when it is the cache, so that every instance sees them,
+// and in-process for the calls redis fails. Otherwise they are only counted in-process.
+func openLockout(cfg *config.Config, appCache *serviceCache, logger *log.Logger) lockout.Store {
+
+	memory := lockout.NewMemory(clock.Real())
+
+	if appCache.redis != nil {
+		return lockout.NewFallback(lockout.NewRedis(appCache.redis, cfg.Redis.Timeout), memory, logger)
+	}
+
+	return memory
+}
+
+// openPasswordPolicy loads the blocklist of PASSWORD_BLOCKLIST_FILE into the password policy, when it is set
+func openPasswordPolicy(cfg *config.Config, logger *log.Logger) (*validate.PasswordPolicy, error) {
+
+	var blocklist []string
+
+	if cfg.Password.BlocklistFile != "" {
+
+		var err error
+
+		blocklist, err = validate.LoadPasswordList(cfg.Password.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Printf("Loaded %d passwords to refuse from %s\n", len(blocklist), cfg.Password.BlocklistFile)
+	}
+
+	policy := validate.NewPasswordPolicy(cfg.Password.MinLength, cfg.Password.MaxLength, blocklist)
+	policy.MaxBytes = password.MaxBytes(cfg.Password.Algorithm)
+
+	return policy, nil
+}
+
+// openOIDCProviders returns the providers of OIDC_PROVIDERS, their discovery documents are fetched on first use
+func openOIDCProviders(cfg *config.Config) []*oidc.Provider {
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var providers []*oidc.Provider
+	for _, provider := range cfg.OIDC.Providers {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         provider.Name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			Scopes:       provider.Scopes,
+			RedirectURL:  strings.TrimSuffix(cfg.HTTP.PublicURL, "/") + "/api/v1/auth/oidc/" + provider.Name + "/callback",
+		}, client, clock.Real()))
+	}
+
+	return providers
+}
+
+// openKeyring loads the keyring of JWT_KEYS_FILE, or signs with JWT_ACCESS_SECRET when it is not set
+func openKeyring(cfg *config.Config) (*token.Keyring, error) {
+
+	settings := token.Settings{
+		Issuer:      cfg.JWT.Issuer,
+		Audience:    cfg.JWT.Audience,
+		GracePeriod: cfg.JWT.KeyGracePeriod,
+	}
+
+	if cfg.JWT.KeysFile != "" {
+		return token.LoadKeyring(cfg.JWT.KeysFile, settings)
+	}
+
+	key := token.Key{ID: token.DefaultKeyID, Algorithm: token.HS256, Secret: []byte(cfg.JWT.AccessSecret)}
+
+	return token.NewKeyring([]token.Key{key}, token.DefaultKeyID, settings)
+}
+
+// noMigrations is the migrator of the backends without a schema
+type noMigrations struct{}
+
+func (noMigrations) Migrate(ctx context.Context) ([]db.MigrationStatus, error) {
+	return nil, nil
+}
+
+func (noMigrations) Status(ctx context.Context) ([]db.MigrationStatus, error) {
+	return nil, nil
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned by Get when the key is not cached
+var ErrMiss = errors.New("cache: key not found")
+
+// Cache is a key/value cache, callers should treat every error as a miss
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set saves the value under key, a ttl of 0 means the key never expires
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the key, deleting a key that is not cached is no error
+	Delete(ctx context.Context, key string) error
+
+	// Ping checks that the cache is reachable
+	Ping(ctx context.Context) error
+}
+
+type nop struct{}
+
+// Nop returns a cache that never holds anything
+func Nop() Cache {
+	return nop{}
+}
+
+func (nop) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, ErrMiss
+}
+
+func (nop) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+func (nop) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (nop) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fallback serves the keys from primary, typically Redis, and switches to fallback, typically an
+// in-process cache, as soon as a call to primary fails. While it is degraded primary is pinged in the
+// background with an exponential backoff, and used again once it answers.
+//
+// The keys written while degraded only reach the fallback, the values primary still holds for them
+// are stale. They are deleted from primary before switching back to it.
+type Fallback struct {
+	primary    Cache
+	fallback   Cache
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	logger     *log.Logger
+
+	mu       sync.Mutex
+	degraded bool
+	dirty    map[string]struct{} // the keys written while degraded
+	closed   bool
+	stop     chan struct{} // closed by Close to end the reconnection
+	wg       sync.WaitGroup
+
+	// accessed atomically
+	primaryErrors uint64
+	reconnects    uint64
+}
+
+// FallbackStats tells whether a Fallback cache runs degraded and how often primary failed
+type FallbackStats struct {
+	Degraded      bool   `json:"degraded"`
+	PrimaryErrors uint64 `json:"primary_errors"`
+	Reconnects    uint64 `json:"reconnects"`
+}
+
+// NewFallback returns a cache using primary while it works, the backoff between two reconnection
+// attempts starts at minBackoff and doubles up to maxBackoff. The switches are logged to logger.
+func NewFallback(primary Cache, fallback Cache, minBackoff time.Duration, maxBackoff time.Duration, logger *log.Logger) *Fallback {
+	return &Fallback{
+		primary:    primary,
+		fallback:   fallback,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		logger:     logger,
+		dirty:      map[string]struct{}{},
+		stop:       make(chan struct{}),
+	}
+}
+
+func (f *Fallback) Get(ctx context.Context, key string) ([]byte, error) {
+
+	if !f.Degraded() {
+		value, err := f.primary.Get(ctx, key)
+		if err == nil || err == ErrMiss || ctx.Err() != nil {
+			return value, err
+		}
+
+		f.fail(err)
+	}
+
+	return f.fallback.Get(ctx, key)
+}
+
+func (f *Fallback) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+
+	if !f.markDirty(key) {
+		err := f.primary.Set(ctx, key, value, ttl)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		f.fail(err)
+		f.markDirty(key)
+	}
+
+	return f.fallback.Set(ctx, key, value, ttl)
+}
+
+func (f *Fallback) Delete(ctx context.Context, key string) error {
+
+	if !f.markDirty(key) {
+		err := f.primary.Delete(ctx, key)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		f.fail(err)
+		f.markDirty(key)
+	}
+
+	return f.fallback.Delete(ctx, key)
+}
+
+// Ping checks primary, a failure switches to the fallback.
+// It is used at startup so that the service starts degraded instead of failing when primary is down.
+func (f *Fallback) Ping(ctx context.Context) error {
+
+	err := f.primary.Ping(ctx)
+	if err != nil && ctx.Err() == nil {
+		f.fail(err)
+	}
+
+	return err
+}
+
+// Degraded reports whether the keys are currently served by the fallback
+func (f *Fallback) Degraded() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.degraded
+}
+
+func (f *Fallback) Stats() FallbackStats {
+	return FallbackStats{
+		Degraded:      f.Degraded(),
+		PrimaryErrors: atomic.LoadUint64(&f.primaryErrors),
+		Reconnects:    atomic.LoadUint64(&f.reconnects),
+	}
+}
+
+// Close stops the reconnection, it does not close primary
+func (f *Fallback) Close() {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.stop)
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+}
+
+// markDirty records that key is written to the fallback, it reports false when not degraded
+func (f *Fallback) markDirty(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.degraded {
+		return false
+	}
+
+	f.dirty[key] = struct{}{}
+
+	return true
+}
+
+// fail switches to the fallback and starts the reconnection, unless it already runs
+func (f *Fallback) fail(err error) {
+	atomic.AddUint64(&f.primaryErrors, 1)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.degraded || f.closed {
+		return
+	}
+
+	f.logger.Println("Cache degraded, using the in-process cache:", err)
+
+	f.degraded = true
+	f.wg.Add(1)
+	go f.reconnect()
+}
+
+// reconnect pings primary until it answers and then switches back to it
+func (f *Fallback) reconnect() {
+	defer f.wg.Done()
+
+	backoff := f.minBackoff
+
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-f.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := f.primary.Ping(context.Background()); err == nil {
+			invalidated, err := f.recover()
+			if err == nil {
+				atomic.AddUint64(&f.reconnects, 1)
+				f.logger.Printf("Cache recovered, invalidated %d keys written while degraded!\n", invalidated)
+
+				return
+			}
+
+			f.logger.Println("Cache still degraded, invalidating the keys written while degraded failed:", err)
+		}
+
+		backoff *= 2
+		if backoff > f.maxBackoff {
+			backoff = f.maxBackoff
+		}
+	}
+}
+
+// recover deletes the keys written while degraded from primary and the fallback, and switches back
+// to primary once none is left. The keys written in the meantime are deleted too.
+func (f *Fallback) recover() (int, error) {
+
+	invalidated := 0
+
+	for {
+		f.mu.Lock()
+		if len(f.dirty) == 0 {
+			f.degraded = false
+			f.mu.Unlock()
+
+			return invalidated, nil
+		}
+
+		keys := make([]string, 0, len(f.dirty))
+		for key := range f.dirty {
+			keys = append(keys, key)
+		}
+		f.mu.Unlock()
+
+		for _, key := range keys {
+			if err := f.primary.Delete(context.Background(), key); err != nil {
+				return invalidated, err
+			}
+
+			// a later outage must not serve it either
+			f.fallback.Delete(context.Background(), key)
+
+			f.mu.Lock()
+			delete(f.dirty, key)
+			f.mu.Unlock()
+
+			invalidated++
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+// flaky is a cache failing every call while down is set
+type flaky struct {
+	Cache
+	down int32
+}
+
+var errDown = errors.New("down")
+
+func (f *flaky) setDown(down bool) {
+	var v int32
+	if down {
+		v = 1
+	}
+
+	atomic.StoreInt32(&f.down, v)
+}
+
+func (f *flaky) isDown() bool {
+	return atomic.LoadInt32(&f.down) == 1
+}
+
+func (f *flaky) Get(ctx context.Context, key string) ([]byte, error) {
+	if f.isDown() {
+		return nil, errDown
+	}
+
+	return f.Cache.Get(ctx, key)
+}
+
+func (f *flaky) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if f.isDown() {
+		return errDown
+	}
+
+	return f.Cache.Set(ctx, key, value, ttl)
+}
+
+func (f *flaky) Delete(ctx context.Context, key string) error {
+	if f.isDown() {
+		return errDown
+	}
+
+	return f.Cache.Delete(ctx, key)
+}
+
+func (f *flaky) Ping(ctx context.Context) error {
+	if f.isDown() {
+		return errDown
+	}
+
+	return nil
+}
+
+func TestFallbackInvalidatesTheKeysWrittenWhileDegraded(t *testing.T) {
+
+	ctx := context.Background()
+	primary := &flaky{Cache: NewMemory(clock.Real(), 0)}
+	fallback := NewFallback(primary, NewMemory(clock.Real(), 0), time.Millisecond, time.Millisecond, log.New(ioutil.Discard, "", 0))
+	defer fallback.Close()
+
+	for _, key := range []string{"written", "deleted", "untouched"} {
+		if err := fallback.Set(ctx, key, []byte("before"), 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	primary.setDown(true)
+
+	if err := fallback.Set(ctx, "written", []byte("during"), 0); err != nil {
+		t.Fatalf("Set while primary is down: %v", err)
+	}
+
+	if !fallback.Degraded() {
+		t.Fatal("a failed Set did not degrade the cache")
+	}
+
+	if err := fallback.Delete(ctx, "deleted"); err != nil {
+		t.Fatalf("Delete while degraded: %v", err)
+	}
+
+	if value, err := fallback.Get(ctx, "written"); err != nil || string(value) != "during" {
+		t.Errorf("Get while degraded = %q, %v, want the value written while degraded", value, err)
+	}
+
+	primary.setDown(false)
+
+	deadline := time.Now().Add(time.Second)
+	for fallback.Degraded() {
+		if time.Now().After(deadline) {
+			t.Fatal("the cache did not recover")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	// primary must not serve the values that predate the outage
+	for _, key := range []string{"written", "deleted"} {
+		if value, err := fallback.Get(ctx, key); err != ErrMiss {
+			t.Errorf("Get(%q) after the recovery = %q, %v, want a miss", key, value, err)
+		}
+	}
+
+	if value, err := fallback.Get(ctx, "untouched"); err != nil || string(value) != "before" {
+		t.Errorf("Get of a key not written while degraded = %q, %v, want it kept", value, err)
+	}
+
+	if stats := fallback.Stats(); stats.Reconnects != 1 {
+		t.Errorf("Reconnects = %d, want 1", stats.Reconnects)
+	}
+}
+
+func TestFallbackStaysDegradedUntilTheKeysAreInvalidated(t *testing.T) {
+
+	ctx := context.Background()
+	primary := &flaky{Cache: NewMemory(clock.Real(), 0)}
+	deletes := &failingDeletes{Cache: primary}
+	fallback := NewFallback(deletes, NewMemory(clock.Real(), 0), time.Millisecond, time.Millisecond, log.New(ioutil.Discard, "", 0))
+	defer fallback.Close()
+
+	primary.setDown(true)
+	fallback.Set(ctx, "key", []byte("during"), 0)
+
+	// primary answers the pings but fails the deletes
+	atomic.StoreInt32(&deletes.failing, 1)
+	primary.setDown(false)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !fallback.Degraded() {
+		t.Fatal("the cache recovered without invalidating the key written while degraded")
+	}
+
+	atomic.StoreInt32(&deletes.failing, 0)
+
+	deadline := time.Now().Add(time.Second)
+	for fallback.Degraded() {
+		if time.Now().After(deadline) {
+			t.Fatal("the cache did not recover")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// failingDeletes fails the deletes while failing is set
+type failingDeletes struct {
+	Cache
+	failing int32
+}
+
+func (f *failingDeletes) Delete(ctx context.Context, key string) error {
+	if atomic.LoadInt32(&f.failing) == 1 {
+		return errDown
+	}
+
+	return f.Cache.Delete(ctx, key)
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // zero when the key never expires
+}
+
+// memoryCache is an LRU cache, entries are kept in a list ordered from the most to the least recently used
+type memoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List
+	maxEntries int
+	clock      clock.Clock
+}
+
+// NewMemory returns an in-process cache holding at most maxEntries keys, the least recently used key
+// is evicted to make room for a new one. The expired keys are dropped when they are read or evicted.
+func NewMemory(clk clock.Clock, maxEntries int) Cache {
+	return &memoryCache{
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+		maxEntries: maxEntries,
+		clock:      clk,
+	}
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, found := m.entries[key]
+	if !found {
+		return nil, ErrMiss
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && !m.clock.Now().Before(entry.expiresAt) {
+		m.remove(element)
+		return nil, ErrMiss
+	}
+
+	m.order.MoveToFront(element)
+
+	return entry.value, nil
+}
+
+func (m *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoryEntry{
+		key: key,
+		// copy so that the caller can reuse value
+		value: append([]byte(nil), value...),
+	}
+
+	if ttl > 0 {
+		entry.expiresAt = m.clock.Now().Add(ttl)
+	}
+
+	if element, found := m.entries[key]; found {
+		element.Value = entry
+		m.order.MoveToFront(element)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+
+	for m.maxEntries > 0 && m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+
+	return nil
+}
+
+func (m *memoryCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, found := m.entries[key]; found {
+		m.remove(element)
+	}
+
+	return nil
+}
+
+func (m *memoryCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+// remove drops an entry, the caller must hold the lock
+func (m *memoryCache) remove(element *list.Element) {
+	m.order.Remove(element)
+	delete(m.entries, element.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/go-redis/redis/v8"
+)
+
+type redisCache struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+// NewRedis returns a cache backed by the given redis client, every call is bounded by timeout unless it is 0
+func NewRedis(client *redis.Client, timeout time.Duration) Cache {
+	return &redisCache{
+		client:  client,
+		timeout: timeout,
+	}
+}
+
+// NewRedisClient returns a client for the configured redis, it connects on its first call
+func NewRedisClient(cfg config.Redis) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
+
+func (r *redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	value, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+
+	return value, err
+}
+
+func (r *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *redisCache) Delete(ctx context.Context, key string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *redisCache) Ping(ctx context.Context) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *redisCache) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, r.timeout)
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts the outcome of the cache calls
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+// Counting wraps a cache and counts its hits, misses and errors
+type Counting struct {
+	cache Cache
+
+	// accessed atomically
+	hits   uint64
+	misses uint64
+	errors uint64
+}
+
+func WithStats(c Cache) *Counting {
+	return &Counting{cache: c}
+}
+
+func (c *Counting) Get(ctx context.Context, key string) ([]byte, error) {
+
+	value, err := c.cache.Get(ctx, key)
+	switch {
+	case err == nil:
+		atomic.AddUint64(&c.hits, 1)
+	case err == ErrMiss:
+		atomic.AddUint64(&c.misses, 1)
+	default:
+		atomic.AddUint64(&c.errors, 1)
+	}
+
+	return value, err
+}
+
+func (c *Counting) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+
+	err := c.cache.Set(ctx, key, value, ttl)
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+	}
+
+	return err
+}
+
+func (c *Counting) Delete(ctx context.Context, key string) error {
+
+	err := c.cache.Delete(ctx, key)
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+	}
+
+	return err
+}
+
+func (c *Counting) Ping(ctx context.Context) error {
+	return c.cache.Ping(ctx)
+}
+
+func (c *Counting) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Errors: atomic.LoadUint64(&c.errors),
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/reconcile"
+)
+
+// runMigrate applies the pending migrations, or lists every migration with "migrate status"
+func runMigrate(ctx context.Context, migrator db.Migrator, args []string) error {
+
+	if len(args) == 0 || args[0] == "up" {
+		applied, err := migrator.Migrate(ctx)
+		printMigrations(applied)
+
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations.")
+		}
+
+		return nil
+	}
+
+	if args[0] == "status" {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		printMigrations(statuses)
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q, expected up or status", args[0])
+}
+
+// runReconcile recomputes the user and tweet metrics and reports the drift, with -fix it also repairs it
+func runReconcile(ctx context.Context, store db.MetricsStore, args []string) error {
+
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "overwrite the drifted counters with the recomputed ones")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := reconcile.Run(ctx, store, *fix)
+	if report != nil {
+		report.Print(os.Stdout)
+	}
+
+	return err
+}
+
+func printMigrations(statuses []db.MigrationStatus) {
+	for _, m := range statuses {
+		appliedAt := "pending"
+		if m.Applied() {
+			appliedAt = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Printf("%4d  %-28s  %s\n", m.Version, appliedAt, m.Description)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] [command]
+
+Commands:
+  (none)           serve the API
+  migrate [up]     apply the pending database migrations
+  migrate status   list the database migrations and whether they were applied
+  reconcile [-fix] recompute the user and tweet counters, report the drift and optionally fix it
+
+Flags:
+`, os.Args[0])
+
+	flag.PrintDefaults()
+}
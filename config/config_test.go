@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// valid returns the default config with the settings that have no default
+func valid() *Config {
+
+	cfg := Default()
+	cfg.JWT.AccessSecret = "a secret of 32 characters ......"
+
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+
+	for _, tt := range []struct {
+		name    string
+		change  func(cfg *Config)
+		problem string // a part of the only problem reported, empty when the config is valid
+	}{
+		{"defaults with a secret", func(cfg *Config) {}, ""},
+
+		// the development secret is public, only local development can use it
+		{"dev secret in production", func(cfg *Config) {
+			cfg.JWT.AccessSecret = DevJWTSecret
+		}, "JWT_ACCESS_SECRET is the public development secret"},
+		{"dev secret in development", func(cfg *Config) {
+			cfg.Env = EnvDevelopment
+			cfg.JWT.AccessSecret = DevJWTSecret
+		}, ""},
+
+		{"unknown env", func(cfg *Config) {
+			cfg.Env = "staging"
+		}, "APP_ENV must be development or production"},
+		{"no secret", func(cfg *Config) {
+			cfg.JWT.AccessSecret = ""
+		}, "JWT_ACCESS_SECRET is required unless JWT_KEYS_FILE is set"},
+		{"keys file instead of a secret", func(cfg *Config) {
+			cfg.JWT.AccessSecret = ""
+			cfg.JWT.KeysFile = "keys.json"
+		}, ""},
+		{"short secret", func(cfg *Config) {
+			cfg.JWT.AccessSecret = strings.Repeat("s", JWTSecretMinLength-1)
+		}, "JWT_ACCESS_SECRET should have at least 16 characters"},
+		{"secret of the minimum length", func(cfg *Config) {
+			cfg.JWT.AccessSecret = strings.Repeat("s", JWTSecretMinLength)
+		}, ""},
+		{"relative public URL", func(cfg *Config) {
+			cfg.HTTP.PublicURL = "/api"
+		}, "PUBLIC_URL must be an absolute URL"},
+		{"negative request timeout", func(cfg *Config) {
+			cfg.HTTP.RequestTimeout = -time.Second
+		}, "HTTP_REQUEST_TIMEOUT must not be negative"},
+		{"no shutdown timeout", func(cfg *Config) {
+			cfg.HTTP.ShutdownTimeout = 0
+		}, "HTTP_SHUTDOWN_TIMEOUT must be positive"},
+		{"unknown db backend", func(cfg *Config) {
+			cfg.DB.Backend = "mysql"
+		}, "DB_BACKEND must be one of"},
+		{"mongo URI without the scheme", func(cfg *Config) {
+			cfg.Mongo.URI = "localhost:27017"
+		}, "MONGO_URI must start with mongodb://"},
+		{"postgres without a URL", func(cfg *Config) {
+			cfg.DB.Backend = BackendPostgres
+		}, "POSTGRES_URL is required"},
+		{"unknown cache backend", func(cfg *Config) {
+			cfg.Cache.Backend = "memcached"
+		}, "CACHE_BACKEND must be one of"},
+		{"SMTP without a sender", func(cfg *Config) {
+			cfg.SMTP.Host = "smtp.example.com"
+			cfg.SMTP.Username = "user"
+			cfg.SMTP.Password = "password"
+		}, "SMTP_FROM is required"},
+		{"grace period shorter than the access tokens", func(cfg *Config) {
+			cfg.JWT.KeyGracePeriod = cfg.JWT.AccessTokenTTL - time.Minute
+		}, "JWT_KEY_GRACE_PERIOD must be at least JWT_ACCESS_TTL"},
+		{"refresh tokens shorter than the access tokens", func(cfg *Config) {
+			cfg.JWT.RefreshTokenTTL = cfg.JWT.AccessTokenTTL
+		}, "JWT_REFRESH_TTL must be longer than JWT_ACCESS_TTL"},
+		{"unknown hash algorithm", func(cfg *Config) {
+			cfg.Password.Algorithm = "md5"
+		}, `PASSWORD_HASH_ALGORITHM must be bcrypt or argon2id, got "md5"`},
+		{"bcrypt cost too high", func(cfg *Config) {
+			cfg.Password.BcryptCost = 32
+		}, "BCRYPT_COST must be between 4 and 31"},
+		{"unknown verification action", func(cfg *Config) {
+			cfg.Verification.RequiredFor = []string{"retweet"}
+		}, `got "retweet"`},
+		{"IP threshold below the account threshold", func(cfg *Config) {
+			cfg.Lockout.IPThreshold = cfg.Lockout.AccountThreshold - 1
+		}, "LOGIN_IP_THRESHOLD must not be below LOGIN_LOCKOUT_THRESHOLD"},
+		{"OIDC without a state secret", func(cfg *Config) {
+			cfg.OIDC.Providers = []OIDCProvider{{Name: "google", Issuer: "https://accounts.google.com", ClientID: "id", ClientSecret: "secret"}}
+		}, "OIDC_STATE_SECRET should have at least 32 characters"},
+		{"OIDC provider name with a dash", func(cfg *Config) {
+			cfg.OIDC.StateSecret = strings.Repeat("s", OIDCStateSecretMinLength)
+			cfg.OIDC.Providers = []OIDCProvider{{Name: "my-idp", Issuer: "https://idp.example.com", ClientID: "id", ClientSecret: "secret"}}
+		}, "OIDC_PROVIDERS can only hold letters and digits"},
+		{"PAT lifetime under a day", func(cfg *Config) {
+			cfg.AccessTokens.MaxTTL = time.Hour
+		}, "PAT_MAX_TTL must be 0 or at least 24h"},
+		{"short admin key", func(cfg *Config) {
+			cfg.Admin.APIKey = strings.Repeat("k", AdminAPIKeyMinLength-1)
+		}, "ADMIN_API_KEY should have at least 16 characters"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+
+			cfg := valid()
+			tt.change(cfg)
+
+			err := cfg.Validate()
+
+			if tt.problem == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+
+			var invalid *Error
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Validate = %v, want an *Error", err)
+			}
+
+			if len(invalid.Problems) != 1 || !strings.Contains(invalid.Problems[0], tt.problem) {
+				t.Errorf("Validate problems = %q, want only one holding %q", invalid.Problems, tt.problem)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+
+	cfg := valid()
+	cfg.Env = "staging"
+	cfg.JWT.AccessSecret = ""
+	cfg.Password.BcryptCost = 3
+
+	err := cfg.Validate()
+
+	var invalid *Error
+	if !errors.As(err, &invalid) || len(invalid.Problems) != 3 {
+		t.Fatalf("Validate = %v, want the 3 problems", err)
+	}
+
+	if !strings.HasPrefix(err.Error(), "invalid config: APP_ENV") {
+		t.Errorf("Error() = %q, want the problems in order after \"invalid config: \"", err.Error())
+	}
+}
@@ -0,0 +1,136 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func testRefreshTokens(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	user := NewUser(t, store)
+	familyID := newID()
+
+	token := &models.RefreshToken{
+		Hash:       newID(),
+		Family_ID:  familyID,
+		User_UUID:  user.UUID,
+		Account_ID: user.Account_ID,
+		Created_At: now(),
+		Expires_At: now().Add(time.Hour),
+	}
+
+	if err := store.CreateRefreshToken(ctx, token); err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	if err := store.CreateRefreshToken(ctx, token); err != db.ErrDuplicate {
+		t.Errorf("CreateRefreshToken of an existing hash = %v, want ErrDuplicate", err)
+	}
+
+	used, err := store.UseRefreshToken(ctx, token.Hash)
+	if err != nil {
+		t.Fatalf("UseRefreshToken: %v", err)
+	}
+
+	if used.Family_ID != familyID || used.User_UUID != user.UUID || used.Account_ID != user.Account_ID ||
+		!used.Expires_At.Equal(token.Expires_At) || used.Used || used.Revoked {
+		t.Errorf("UseRefreshToken = %+v, want %+v", used, token)
+	}
+
+	// a token works once
+	again, err := store.UseRefreshToken(ctx, token.Hash)
+	if err != db.ErrAlreadyUsed || again == nil || again.Family_ID != familyID {
+		t.Errorf("UseRefreshToken of a used token = %+v, %v, want the token and ErrAlreadyUsed", again, err)
+	}
+
+	if _, err := store.UseRefreshToken(ctx, newID()); err != db.ErrNotFound {
+		t.Errorf("UseRefreshToken of an unknown token = %v, want ErrNotFound", err)
+	}
+
+	next := *token
+	next.Hash = newID()
+
+	if err := store.CreateRefreshToken(ctx, &next); err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	if err := store.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+	}
+
+	revoked, err := store.UseRefreshToken(ctx, next.Hash)
+	if err != nil || !revoked.Revoked {
+		t.Errorf("UseRefreshToken of a revoked family = %+v, %v, want the token revoked", revoked, err)
+	}
+}
+
+func testPasswordResets(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	user := NewUser(t, store)
+
+	first := &models.PasswordReset{
+		Hash:       newID(),
+		User_UUID:  user.UUID,
+		Created_At: now(),
+		Expires_At: now().Add(time.Hour),
+	}
+
+	if err := store.CreatePasswordReset(ctx, first); err != nil {
+		t.Fatalf("CreatePasswordReset: %v", err)
+	}
+
+	got, err := store.GetPasswordReset(ctx, first.Hash)
+	if err != nil || got.User_UUID != user.UUID || !got.Expires_At.Equal(first.Expires_At) || got.Used || got.Invalidated {
+		t.Errorf("GetPasswordReset = %+v, %v, want %+v", got, err, first)
+	}
+
+	if _, err := store.GetPasswordReset(ctx, newID()); err != db.ErrNotFound {
+		t.Errorf("GetPasswordReset of an unknown token = %v, want ErrNotFound", err)
+	}
+
+	// only the newest reset of the user works
+	second := *first
+	second.Hash = newID()
+
+	if err := store.CreatePasswordReset(ctx, &second); err != nil {
+		t.Fatalf("CreatePasswordReset: %v", err)
+	}
+
+	if reset, err := store.UsePasswordReset(ctx, first.Hash); err != db.ErrAlreadyUsed || reset == nil || !reset.Invalidated {
+		t.Errorf("UsePasswordReset of a replaced reset = %+v, %v, want it invalidated and ErrAlreadyUsed", reset, err)
+	}
+
+	if reset, err := store.UsePasswordReset(ctx, second.Hash); err != nil || reset.User_UUID != user.UUID {
+		t.Errorf("UsePasswordReset = %+v, %v, want the reset", reset, err)
+	}
+
+	if _, err := store.UsePasswordReset(ctx, second.Hash); err != db.ErrAlreadyUsed {
+		t.Errorf("UsePasswordReset of a used reset = %v, want ErrAlreadyUsed", err)
+	}
+
+	if _, err := store.UsePasswordReset(ctx, newID()); err != db.ErrNotFound {
+		t.Errorf("UsePasswordReset of an unknown token = %v, want ErrNotFound", err)
+	}
+}
+
+func testAuditEvents(t *testing.T, store db.Store) {
+
+	user := NewUser(t, store)
+
+	err := store.CreateAuditEvent(context.Background(), &models.AuditEvent{
+		ID:         newID(),
+		User_UUID:  user.UUID,
+		Type:       "sign_in",
+		IP:         "192.0.2.1",
+		Created_At: now(),
+	})
+	if err != nil {
+		t.Errorf("CreateAuditEvent: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Package dbtest holds the tests every storage backend has to pass, the backends run them
+// from their own tests against a store of their kind
+package dbtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Run checks that the stores returned by newStore keep the contract of db.Store.
+// Every test gets a store of its own, the stores may share their database as long as the tests
+// only create documents with fresh IDs.
+func Run(t *testing.T, newStore func(t *testing.T) db.Store) {
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, store db.Store)
+	}{
+		{"Users", testUsers},
+		{"Passwords", testPasswords},
+		{"Tweets", testTweets},
+		{"Follows", testFollows},
+		{"ConcurrentFollow", testConcurrentFollow},
+		{"Metrics", testMetrics},
+		{"RefreshTokens", testRefreshTokens},
+		{"PasswordResets", testPasswordResets},
+		{"AuditEvents", testAuditEvents},
+		{"TwoFactors", testTwoFactors},
+		{"Identities", testIdentities},
+		{"PersonalAccessTokens", testPersonalAccessTokens},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore(t)
+
+			if err := store.Ping(context.Background()); err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+
+			tt.test(t, store)
+		})
+	}
+}
+
+// NewUser saves a user with a fresh UUID, email and account ID, so that the tests of a run
+// never collide with each other or with the users of an earlier run
+func NewUser(t *testing.T, store db.UserStore) *models.UserInfo {
+	t.Helper()
+
+	user := newUserInfo()
+
+	if err := store.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	return user
+}
+
+func newUserInfo() *models.UserInfo {
+
+	id := newID()
+	now := now()
+
+	return &models.UserInfo{
+		UUID:       id,
+		Account_ID: "#" + strings.ToUpper(strings.ReplaceAll(id, "-", "")[:10]),
+		FirstName:  "Test",
+		LastName:   "User",
+		Age:        30,
+		Email:      id + "@example.com",
+		Password:   "hash",
+		Created_At: now,
+		Updated_At: now,
+	}
+}
+
+func newID() string {
+	return uuid.NewV4().String()
+}
+
+// now is rounded to the millisecond, the finest precision every backend keeps
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// sameTime compares two optional times
+func sameTime(got *time.Time, want *time.Time) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+
+	return got.Equal(*want)
+}
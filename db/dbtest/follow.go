@@ -0,0 +1,144 @@
+package dbtest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func testFollows(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	follower := NewUser(t, store)
+	following := NewUser(t, store)
+
+	follow := &models.Followers{
+		ID:                   newID(),
+		Follower_Account_ID:  follower.Account_ID,
+		Following_Account_ID: following.Account_ID,
+	}
+
+	created, err := store.Follow(ctx, follow)
+	if err != nil || !created {
+		t.Fatalf("Follow = %v, %v, want created", created, err)
+	}
+
+	// following again changes nothing
+	again := *follow
+	again.ID = newID()
+
+	created, err = store.Follow(ctx, &again)
+	if err != nil || created {
+		t.Errorf("Follow of an existing follow = %v, %v, want not created", created, err)
+	}
+
+	exists, err := store.FollowExists(ctx, follower.Account_ID, following.Account_ID)
+	if err != nil || !exists {
+		t.Errorf("FollowExists = %v, %v, want true", exists, err)
+	}
+
+	// a follow has a direction
+	exists, err = store.FollowExists(ctx, following.Account_ID, follower.Account_ID)
+	if err != nil || exists {
+		t.Errorf("FollowExists of the reverse follow = %v, %v, want false", exists, err)
+	}
+
+	_, err = store.Follow(ctx, &models.Followers{
+		ID:                   newID(),
+		Follower_Account_ID:  follower.Account_ID,
+		Following_Account_ID: "#" + newID(),
+	})
+	if err != db.ErrNotFound {
+		t.Errorf("Follow of an unknown account = %v, want ErrNotFound", err)
+	}
+
+	checkFollow(t, store, follower, following)
+}
+
+// testConcurrentFollow follows the same pair from many goroutines at once, a single follow must be saved
+// and the counters of both accounts must move by one
+func testConcurrentFollow(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	follower := NewUser(t, store)
+	following := NewUser(t, store)
+
+	const goroutines = 16
+
+	var wg sync.WaitGroup
+	var created int32
+	errs := make(chan error, goroutines)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ok, err := store.Follow(ctx, &models.Followers{
+				ID:                   newID(),
+				Follower_Account_ID:  follower.Account_ID,
+				Following_Account_ID: following.Account_ID,
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if ok {
+				atomic.AddInt32(&created, 1)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Follow: %v", err)
+	}
+
+	if created != 1 {
+		t.Errorf("Follow reported %d follows created, want 1", created)
+	}
+
+	checkFollow(t, store, follower, following)
+}
+
+// checkFollow checks that follower follows following and nobody else, and that the counters of both moved by one
+func checkFollow(t *testing.T, store db.Store, follower *models.UserInfo, following *models.UserInfo) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	followingIDs, err := store.GetFollowingAccountIDs(ctx, follower.Account_ID)
+	if err != nil {
+		t.Fatalf("GetFollowingAccountIDs: %v", err)
+	}
+
+	if len(followingIDs) != 1 || followingIDs[0] != following.Account_ID {
+		t.Errorf("GetFollowingAccountIDs = %v, want [%s]", followingIDs, following.Account_ID)
+	}
+
+	gotFollower, err := store.GetUserByUUID(ctx, follower.UUID)
+	if err != nil {
+		t.Fatalf("GetUserByUUID: %v", err)
+	}
+
+	if gotFollower.Metrics.Following_count != 1 || gotFollower.Metrics.Followers_count != 0 {
+		t.Errorf("metrics of the follower = %+v, want 1 following and no followers", gotFollower.Metrics)
+	}
+
+	gotFollowing, err := store.GetUserByUUID(ctx, following.UUID)
+	if err != nil {
+		t.Fatalf("GetUserByUUID: %v", err)
+	}
+
+	if gotFollowing.Metrics.Followers_count != 1 || gotFollowing.Metrics.Following_count != 0 {
+		t.Errorf("metrics of the followed account = %+v, want 1 follower and no following", gotFollowing.Metrics)
+	}
+}
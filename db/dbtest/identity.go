@@ -0,0 +1,62 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func testIdentities(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	user := NewUser(t, store)
+	other := NewUser(t, store)
+	subject := newID()
+
+	identity := &models.Identity{
+		Provider:   "google",
+		Subject:    subject,
+		User_UUID:  user.UUID,
+		Created_At: now(),
+	}
+
+	if err := store.CreateIdentity(ctx, identity); err != nil {
+		t.Fatalf("CreateIdentity: %v", err)
+	}
+
+	// an account at a provider links to a single user
+	taken := *identity
+	taken.User_UUID = other.UUID
+
+	if err := store.CreateIdentity(ctx, &taken); err != db.ErrDuplicate {
+		t.Errorf("CreateIdentity of a linked account = %v, want ErrDuplicate", err)
+	}
+
+	// the same subject at another provider is another account
+	if err := store.CreateIdentity(ctx, &models.Identity{Provider: "gitlab", Subject: subject, User_UUID: other.UUID, Created_At: now()}); err != nil {
+		t.Errorf("CreateIdentity at another provider: %v", err)
+	}
+
+	got, err := store.GetIdentity(ctx, "google", subject)
+	if err != nil || got.User_UUID != user.UUID || !got.Created_At.Equal(identity.Created_At) {
+		t.Errorf("GetIdentity = %+v, %v, want %+v", got, err, identity)
+	}
+
+	if _, err := store.GetIdentity(ctx, "google", newID()); err != db.ErrNotFound {
+		t.Errorf("GetIdentity of an unknown subject = %v, want ErrNotFound", err)
+	}
+
+	if err := store.DeleteIdentities(ctx, user.UUID); err != nil {
+		t.Fatalf("DeleteIdentities: %v", err)
+	}
+
+	if _, err := store.GetIdentity(ctx, "google", subject); err != db.ErrNotFound {
+		t.Errorf("GetIdentity after DeleteIdentities = %v, want ErrNotFound", err)
+	}
+
+	if _, err := store.GetIdentity(ctx, "gitlab", subject); err != nil {
+		t.Errorf("DeleteIdentities removed the identity of another user: %v", err)
+	}
+}
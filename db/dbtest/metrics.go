@@ -0,0 +1,103 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func testMetrics(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	user := NewUser(t, store)
+	other := NewUser(t, store)
+
+	if _, err := store.Follow(ctx, &models.Followers{ID: newID(), Follower_Account_ID: other.Account_ID, Following_Account_ID: user.Account_ID}); err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+
+	tweet := newTweet(user, now())
+	tweet.Metrics = models.TweetMetrics{Retweets_count: 2, Likes_count: 3, Characters_count: 25}
+
+	if err := store.CreateTweet(ctx, tweet); err != nil {
+		t.Fatalf("CreateTweet: %v", err)
+	}
+
+	counted, err := store.CountUserMetrics(ctx, user)
+	if err != nil {
+		t.Fatalf("CountUserMetrics: %v", err)
+	}
+
+	want := models.UserMetrics{Followers_count: 1, Total_tweets_count: 1, Total_retweets_count: 2, Total_likes_count: 3}
+	if counted != want {
+		t.Errorf("CountUserMetrics = %+v, want %+v", counted, want)
+	}
+
+	stored, err := store.GetUserByUUID(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("GetUserByUUID: %v", err)
+	}
+
+	// the metrics are only replaced while they still are the old ones
+	updated, err := store.SetUserMetrics(ctx, user.UUID, models.UserMetrics{Followers_count: 42}, want)
+	if err != nil || updated {
+		t.Errorf("SetUserMetrics with stale metrics = %v, %v, want not updated", updated, err)
+	}
+
+	updated, err = store.SetUserMetrics(ctx, user.UUID, stored.Metrics, want)
+	if err != nil || !updated {
+		t.Errorf("SetUserMetrics = %v, %v, want updated", updated, err)
+	}
+
+	stored, err = store.GetUserByUUID(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("GetUserByUUID: %v", err)
+	}
+
+	if stored.Metrics != want {
+		t.Errorf("metrics = %+v, want %+v", stored.Metrics, want)
+	}
+
+	tweetMetrics := models.TweetMetrics{Retweets_count: 5, Likes_count: 8, Characters_count: 25}
+
+	updated, err = store.SetTweetMetrics(ctx, tweet.Tweet_UUID, models.TweetMetrics{Likes_count: 42}, tweetMetrics)
+	if err != nil || updated {
+		t.Errorf("SetTweetMetrics with stale metrics = %v, %v, want not updated", updated, err)
+	}
+
+	updated, err = store.SetTweetMetrics(ctx, tweet.Tweet_UUID, tweet.Metrics, tweetMetrics)
+	if err != nil || !updated {
+		t.Errorf("SetTweetMetrics = %v, %v, want updated", updated, err)
+	}
+
+	seenUsers := map[string]bool{}
+	err = store.ForEachUser(ctx, func(u *models.UserInfo) error {
+		seenUsers[u.UUID] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachUser: %v", err)
+	}
+
+	if !seenUsers[user.UUID] || !seenUsers[other.UUID] {
+		t.Errorf("ForEachUser skipped the users of the test")
+	}
+
+	var seenTweet *models.TweetDB
+	err = store.ForEachTweet(ctx, func(tw *models.TweetDB) error {
+		if tw.Tweet_UUID == tweet.Tweet_UUID {
+			copied := *tw
+			seenTweet = &copied
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachTweet: %v", err)
+	}
+
+	if seenTweet == nil || seenTweet.Metrics != tweetMetrics || seenTweet.User_UUID != user.UUID {
+		t.Errorf("ForEachTweet gave %+v, want the tweet with %+v", seenTweet, tweetMetrics)
+	}
+}
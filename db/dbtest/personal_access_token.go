@@ -0,0 +1,154 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func testPersonalAccessTokens(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	user := NewUser(t, store)
+	other := NewUser(t, store)
+	start := now()
+	expiresAt := start.Add(24 * time.Hour)
+
+	older := newAccessToken(user, start, &expiresAt, models.ScopeTweetsRead, models.ScopeTweetsWrite)
+	newer := newAccessToken(user, start.Add(time.Minute), nil, models.ScopeFeedRead)
+	others := newAccessToken(other, start, nil, models.ScopeFeedRead)
+
+	for _, token := range []*models.PersonalAccessToken{older, newer, others} {
+		if err := store.CreatePersonalAccessToken(ctx, token); err != nil {
+			t.Fatalf("CreatePersonalAccessToken: %v", err)
+		}
+	}
+
+	sameID := newAccessToken(user, start, nil)
+	sameID.ID = older.ID
+
+	sameHash := newAccessToken(user, start, nil)
+	sameHash.Hash = older.Hash
+
+	for _, duplicate := range []*models.PersonalAccessToken{sameID, sameHash} {
+		if err := store.CreatePersonalAccessToken(ctx, duplicate); err != db.ErrDuplicate {
+			t.Errorf("CreatePersonalAccessToken of a used ID or hash = %v, want ErrDuplicate", err)
+		}
+	}
+
+	got, err := store.GetPersonalAccessToken(ctx, older.Hash)
+	if err != nil {
+		t.Fatalf("GetPersonalAccessToken: %v", err)
+	}
+
+	if got.ID != older.ID || got.User_UUID != user.UUID || got.Account_ID != user.Account_ID || got.Name != older.Name ||
+		!sameScopes(got.Scopes, older.Scopes) || !got.Created_At.Equal(older.Created_At) || !sameTime(got.Expires_At, &expiresAt) ||
+		got.Last_Used_At != nil || got.Revoked_At != nil {
+		t.Errorf("GetPersonalAccessToken = %+v, want %+v", got, older)
+	}
+
+	if _, err := store.GetPersonalAccessToken(ctx, newID()); err != db.ErrNotFound {
+		t.Errorf("GetPersonalAccessToken of an unknown token = %v, want ErrNotFound", err)
+	}
+
+	listed, err := store.ListPersonalAccessTokens(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("ListPersonalAccessTokens: %v", err)
+	}
+
+	if len(listed) != 2 || listed[0].ID != newer.ID || listed[1].ID != older.ID {
+		t.Errorf("ListPersonalAccessTokens = %+v, want the newer token and then the older one", listed)
+	}
+
+	usedAt := start.Add(time.Hour)
+	if err := store.SetPersonalAccessTokenLastUsed(ctx, older.ID, usedAt); err != nil {
+		t.Fatalf("SetPersonalAccessTokenLastUsed: %v", err)
+	}
+
+	// a user only revokes their own tokens, and a revocation keeps its time
+	if err := store.RevokePersonalAccessToken(ctx, other.UUID, older.ID, start); err != db.ErrNotFound {
+		t.Errorf("RevokePersonalAccessToken of the token of another user = %v, want ErrNotFound", err)
+	}
+
+	revokedAt := start.Add(2 * time.Hour)
+	if err := store.RevokePersonalAccessToken(ctx, user.UUID, older.ID, revokedAt); err != nil {
+		t.Fatalf("RevokePersonalAccessToken: %v", err)
+	}
+
+	if err := store.RevokePersonalAccessToken(ctx, user.UUID, older.ID, revokedAt.Add(time.Hour)); err != nil {
+		t.Errorf("RevokePersonalAccessToken of a revoked token: %v", err)
+	}
+
+	got, err = store.GetPersonalAccessToken(ctx, older.Hash)
+	if err != nil {
+		t.Fatalf("GetPersonalAccessToken: %v", err)
+	}
+
+	if !sameTime(got.Last_Used_At, &usedAt) || !sameTime(got.Revoked_At, &revokedAt) {
+		t.Errorf("last used at %v and revoked at %v, want %v and %v", got.Last_Used_At, got.Revoked_At, usedAt, revokedAt)
+	}
+
+	allRevokedAt := start.Add(3 * time.Hour)
+	if err := store.RevokePersonalAccessTokens(ctx, user.UUID, allRevokedAt); err != nil {
+		t.Fatalf("RevokePersonalAccessTokens: %v", err)
+	}
+
+	listed, err = store.ListPersonalAccessTokens(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("ListPersonalAccessTokens: %v", err)
+	}
+
+	for _, token := range listed {
+		want := allRevokedAt
+		if token.ID == older.ID {
+			want = revokedAt
+		}
+
+		if !sameTime(token.Revoked_At, &want) {
+			t.Errorf("token %s revoked at %v, want %v", token.ID, token.Revoked_At, want)
+		}
+	}
+
+	if err := store.DeletePersonalAccessTokens(ctx, user.UUID); err != nil {
+		t.Fatalf("DeletePersonalAccessTokens: %v", err)
+	}
+
+	if _, err := store.GetPersonalAccessToken(ctx, newer.Hash); err != db.ErrNotFound {
+		t.Errorf("GetPersonalAccessToken after DeletePersonalAccessTokens = %v, want ErrNotFound", err)
+	}
+
+	got, err = store.GetPersonalAccessToken(ctx, others.Hash)
+	if err != nil || got.Revoked_At != nil {
+		t.Errorf("the token of another user = %+v, %v, want it untouched", got, err)
+	}
+}
+
+func newAccessToken(user *models.UserInfo, createdAt time.Time, expiresAt *time.Time, scopes ...string) *models.PersonalAccessToken {
+	return &models.PersonalAccessToken{
+		ID:         newID(),
+		User_UUID:  user.UUID,
+		Account_ID: user.Account_ID,
+		Name:       "bot",
+		Hash:       newID(),
+		Scopes:     scopes,
+		Created_At: createdAt,
+		Expires_At: expiresAt,
+	}
+}
+
+func sameScopes(got []string, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
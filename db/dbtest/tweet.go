@@ -0,0 +1,100 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func testTweets(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	alice := NewUser(t, store)
+	bob := NewUser(t, store)
+	carol := NewUser(t, store)
+
+	start := now()
+
+	// posted oldest first, one minute apart
+	var posted []*models.TweetDB
+	for i, author := range []*models.UserInfo{alice, bob, alice, carol, bob} {
+		tweet := newTweet(author, start.Add(time.Duration(i)*time.Minute))
+
+		if err := store.CreateTweet(ctx, tweet); err != nil {
+			t.Fatalf("CreateTweet: %v", err)
+		}
+
+		posted = append(posted, tweet)
+	}
+
+	byUser, err := store.GetTweetsByUserUUID(ctx, alice.UUID)
+	if err != nil {
+		t.Fatalf("GetTweetsByUserUUID: %v", err)
+	}
+
+	checkTweets(t, "GetTweetsByUserUUID", byUser, false, posted[0], posted[2])
+
+	byAccount, err := store.GetTweetsByAccountID(ctx, bob.Account_ID)
+	if err != nil {
+		t.Fatalf("GetTweetsByAccountID: %v", err)
+	}
+
+	checkTweets(t, "GetTweetsByAccountID", byAccount, false, posted[1], posted[4])
+
+	feed, err := store.GetTweetsForAccounts(ctx, []string{alice.Account_ID, bob.Account_ID}, 3)
+	if err != nil {
+		t.Fatalf("GetTweetsForAccounts: %v", err)
+	}
+
+	checkTweets(t, "GetTweetsForAccounts", feed, true, posted[4], posted[2], posted[1])
+
+	none, err := store.GetTweetsByUserUUID(ctx, newID())
+	if err != nil || len(none) != 0 {
+		t.Errorf("GetTweetsByUserUUID of a user without tweets = %v, %v, want none", none, err)
+	}
+}
+
+func newTweet(author *models.UserInfo, createdAt time.Time) *models.TweetDB {
+	return &models.TweetDB{
+		User_UUID:  author.UUID,
+		Account_ID: author.Account_ID,
+		Tweet_UUID: newID(),
+		Email:      author.Email,
+		Tweet:      "tweet " + createdAt.Format(time.RFC3339),
+		Metrics:    models.TweetMetrics{Characters_count: 25},
+		Created_At: createdAt,
+		Updated_At: createdAt,
+	}
+}
+
+// checkTweets checks the tweets in order when ordered is set, otherwise as a set
+// since only the feed promises an order
+func checkTweets(t *testing.T, call string, got []models.Tweet, ordered bool, want ...*models.TweetDB) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%s returned %d tweets, want %d", call, len(got), len(want))
+		return
+	}
+
+	if !ordered {
+		byUUID := map[string]models.Tweet{}
+		for _, tweet := range got {
+			byUUID[tweet.Tweet_UUID] = tweet
+		}
+
+		got = make([]models.Tweet, 0, len(want))
+		for _, tweet := range want {
+			got = append(got, byUUID[tweet.Tweet_UUID])
+		}
+	}
+
+	for i := range want {
+		if got[i].Tweet_UUID != want[i].Tweet_UUID || got[i].Tweet != want[i].Tweet || got[i].Metrics != want[i].Metrics {
+			t.Errorf("%s tweet %d = %+v, want %+v", call, i, got[i], *want[i])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func testTwoFactors(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	user := NewUser(t, store)
+
+	if _, err := store.GetTwoFactor(ctx, user.UUID); err != db.ErrNotFound {
+		t.Errorf("GetTwoFactor of a user without it = %v, want ErrNotFound", err)
+	}
+
+	if err := store.CreateTwoFactor(ctx, &models.TwoFactor{User_UUID: user.UUID, Secret: "first"}); err != nil {
+		t.Fatalf("CreateTwoFactor: %v", err)
+	}
+
+	// a pending one is replaced
+	if err := store.CreateTwoFactor(ctx, &models.TwoFactor{User_UUID: user.UUID, Secret: "second"}); err != nil {
+		t.Fatalf("CreateTwoFactor replacing a pending one: %v", err)
+	}
+
+	pending, err := store.GetTwoFactor(ctx, user.UUID)
+	if err != nil || pending.Secret != "second" || pending.Enabled_At != nil {
+		t.Errorf("GetTwoFactor = %+v, %v, want the second secret pending", pending, err)
+	}
+
+	enabledAt := now()
+	codes := []models.RecoveryCode{{Hash: "code-1"}, {Hash: "code-2"}}
+
+	if err := store.EnableTwoFactor(ctx, user.UUID, enabledAt, codes); err != nil {
+		t.Fatalf("EnableTwoFactor: %v", err)
+	}
+
+	if err := store.EnableTwoFactor(ctx, user.UUID, enabledAt, codes); err != db.ErrNotFound {
+		t.Errorf("EnableTwoFactor of an enabled one = %v, want ErrNotFound", err)
+	}
+
+	if err := store.CreateTwoFactor(ctx, &models.TwoFactor{User_UUID: user.UUID, Secret: "third"}); err != db.ErrDuplicate {
+		t.Errorf("CreateTwoFactor with one enabled = %v, want ErrDuplicate", err)
+	}
+
+	enabled, err := store.GetTwoFactor(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("GetTwoFactor: %v", err)
+	}
+
+	if enabled.Secret != "second" || !sameTime(enabled.Enabled_At, &enabledAt) || len(enabled.Recovery_Codes) != 2 {
+		t.Errorf("GetTwoFactor = %+v, want the second secret enabled with 2 recovery codes", enabled)
+	}
+
+	// a code works once, and so do the codes of the steps before it
+	if err := store.UseTwoFactorStep(ctx, user.UUID, 10); err != nil {
+		t.Errorf("UseTwoFactorStep: %v", err)
+	}
+
+	for _, step := range []int64{10, 9} {
+		if err := store.UseTwoFactorStep(ctx, user.UUID, step); err != db.ErrAlreadyUsed {
+			t.Errorf("UseTwoFactorStep of step %d after step 10 = %v, want ErrAlreadyUsed", step, err)
+		}
+	}
+
+	if err := store.UseTwoFactorStep(ctx, user.UUID, 11); err != nil {
+		t.Errorf("UseTwoFactorStep of the next step: %v", err)
+	}
+
+	if err := store.UseRecoveryCode(ctx, user.UUID, "code-1"); err != nil {
+		t.Errorf("UseRecoveryCode: %v", err)
+	}
+
+	if err := store.UseRecoveryCode(ctx, user.UUID, "code-1"); err != db.ErrNotFound {
+		t.Errorf("UseRecoveryCode of a used code = %v, want ErrNotFound", err)
+	}
+
+	if err := store.UseRecoveryCode(ctx, user.UUID, "unknown"); err != db.ErrNotFound {
+		t.Errorf("UseRecoveryCode of an unknown code = %v, want ErrNotFound", err)
+	}
+
+	used, err := store.GetTwoFactor(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("GetTwoFactor: %v", err)
+	}
+
+	if used.Last_Step != 11 {
+		t.Errorf("Last_Step = %d, want 11", used.Last_Step)
+	}
+
+	for _, code := range used.Recovery_Codes {
+		if code.Used != (code.Hash == "code-1") {
+			t.Errorf("recovery code %s used = %v", code.Hash, code.Used)
+		}
+	}
+
+	if err := store.DeleteTwoFactor(ctx, user.UUID); err != nil {
+		t.Fatalf("DeleteTwoFactor: %v", err)
+	}
+
+	if _, err := store.GetTwoFactor(ctx, user.UUID); err != db.ErrNotFound {
+		t.Errorf("GetTwoFactor after DeleteTwoFactor = %v, want ErrNotFound", err)
+	}
+}
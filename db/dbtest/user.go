@@ -0,0 +1,148 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/db"
+)
+
+func testUsers(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	user := NewUser(t, store)
+
+	// the UUID, the email and the account ID are unique
+	sameUUID := newUserInfo()
+	sameUUID.UUID = user.UUID
+
+	sameEmail := newUserInfo()
+	sameEmail.Email = user.Email
+
+	sameAccountID := newUserInfo()
+	sameAccountID.Account_ID = user.Account_ID
+
+	for _, duplicate := range []struct {
+		field string
+		err   error
+	}{
+		{"UUID", store.CreateUser(ctx, sameUUID)},
+		{"email", store.CreateUser(ctx, sameEmail)},
+		{"account ID", store.CreateUser(ctx, sameAccountID)},
+	} {
+		if duplicate.err != db.ErrDuplicate {
+			t.Errorf("CreateUser with the %s of another user = %v, want ErrDuplicate", duplicate.field, duplicate.err)
+		}
+	}
+
+	exists, err := store.UserExists(ctx, user.Email)
+	if err != nil || !exists {
+		t.Errorf("UserExists = %v, %v, want true", exists, err)
+	}
+
+	exists, err = store.UserExists(ctx, newID()+"@example.com")
+	if err != nil || exists {
+		t.Errorf("UserExists of an unknown email = %v, %v, want false", exists, err)
+	}
+
+	byEmail, err := store.GetUserByEmail(ctx, user.Email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+
+	if byEmail.UUID != user.UUID || byEmail.Account_ID != user.Account_ID || byEmail.Password != user.Password ||
+		byEmail.FirstName != user.FirstName || byEmail.LastName != user.LastName || byEmail.Age != user.Age {
+		t.Errorf("GetUserByEmail = %+v, want %+v", byEmail, user)
+	}
+
+	if !byEmail.Created_At.Equal(user.Created_At) {
+		t.Errorf("Created_At = %v, want %v", byEmail.Created_At, user.Created_At)
+	}
+
+	if byEmail.Verified_At != nil {
+		t.Errorf("Verified_At of a new user = %v, want nil", byEmail.Verified_At)
+	}
+
+	byUUID, err := store.GetUserByUUID(ctx, user.UUID)
+	if err != nil || byUUID.Email != user.Email {
+		t.Errorf("GetUserByUUID = %+v, %v, want the user", byUUID, err)
+	}
+
+	if _, err := store.GetUserByEmail(ctx, newID()+"@example.com"); err != db.ErrNotFound {
+		t.Errorf("GetUserByEmail of an unknown email = %v, want ErrNotFound", err)
+	}
+
+	if _, err := store.GetUserByUUID(ctx, newID()); err != db.ErrNotFound {
+		t.Errorf("GetUserByUUID of an unknown user = %v, want ErrNotFound", err)
+	}
+
+	verifiedAt := now()
+	if err := store.SetEmailVerified(ctx, user.UUID, verifiedAt); err != nil {
+		t.Fatalf("SetEmailVerified: %v", err)
+	}
+
+	if err := store.SetEmailVerified(ctx, newID(), verifiedAt); err != db.ErrNotFound {
+		t.Errorf("SetEmailVerified of an unknown user = %v, want ErrNotFound", err)
+	}
+
+	if err := store.IncrementTweetsCount(ctx, user.UUID); err != nil {
+		t.Fatalf("IncrementTweetsCount: %v", err)
+	}
+
+	got, err := store.GetUserByUUID(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("GetUserByUUID: %v", err)
+	}
+
+	if !sameTime(got.Verified_At, &verifiedAt) {
+		t.Errorf("Verified_At = %v, want %v", got.Verified_At, verifiedAt)
+	}
+
+	if got.Metrics.Total_tweets_count != 1 {
+		t.Errorf("tweets count = %d, want 1", got.Metrics.Total_tweets_count)
+	}
+
+	if err := store.DeleteUser(ctx, user.UUID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if _, err := store.GetUserByUUID(ctx, user.UUID); err != db.ErrNotFound {
+		t.Errorf("GetUserByUUID of a deleted user = %v, want ErrNotFound", err)
+	}
+
+	// the email of a deleted user can be used again
+	reused := newUserInfo()
+	reused.Email = user.Email
+
+	if err := store.CreateUser(ctx, reused); err != nil {
+		t.Errorf("CreateUser with the email of a deleted user: %v", err)
+	}
+}
+
+func testPasswords(t *testing.T, store db.Store) {
+
+	ctx := context.Background()
+	user := NewUser(t, store)
+
+	if err := store.UpdatePassword(ctx, user.UUID, "new hash"); err != nil {
+		t.Fatalf("UpdatePassword: %v", err)
+	}
+
+	// the rehash only replaces the hash it was computed from
+	if err := store.RehashPassword(ctx, user.UUID, user.Password, "rehash"); err != db.ErrNotFound {
+		t.Errorf("RehashPassword of a changed password = %v, want ErrNotFound", err)
+	}
+
+	if err := store.RehashPassword(ctx, user.UUID, "new hash", "rehash"); err != nil {
+		t.Errorf("RehashPassword: %v", err)
+	}
+
+	got, err := store.GetUserByUUID(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("GetUserByUUID: %v", err)
+	}
+
+	if got.Password != "rehash" {
+		t.Errorf("password = %q, want %q", got.Password, "rehash")
+	}
+}
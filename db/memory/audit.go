@@ -0,0 +1,16 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditEvents = append(s.auditEvents, *event)
+
+	return nil
+}
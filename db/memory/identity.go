@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return db.ErrDuplicate
+		}
+	}
+
+	s.identities = append(s.identities, *identity)
+
+	return nil
+}
+
+func (s *Store) GetIdentity(ctx context.Context, provider string, subject string) (*models.Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, identity := range s.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+
+	return nil, db.ErrNotFound
+}
+
+func (s *Store) DeleteIdentities(ctx context.Context, userUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.identities[:0]
+	for _, identity := range s.identities {
+		if identity.User_UUID != userUUID {
+			kept = append(kept, identity)
+		}
+	}
+
+	s.identities = kept
+
+	return nil
+}
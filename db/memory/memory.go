@@ -0,0 +1,277 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+// Store implements db.Store in memory,
+// it is safe for concurrent use and is meant for tests and local development
+type Store struct {
+	mu      sync.RWMutex
+	users   map[string]models.UserInfo // keyed by user UUID
+	tweets  []models.TweetDB
+	follows []models.Followers
+
+	refreshTokens  map[string]models.RefreshToken  // keyed by hash
+	passwordResets map[string]models.PasswordReset // keyed by hash
+	auditEvents    []models.AuditEvent
+	twoFactors     map[string]models.TwoFactor // keyed by user UUID
+	identities     []models.Identity
+	accessTokens   map[string]models.PersonalAccessToken // keyed by hash
+}
+
+var _ db.Store = (*Store)(nil)
+
+// New returns an empty store
+func New() *Store {
+	return &Store{
+		users:          map[string]models.UserInfo{},
+		refreshTokens:  map[string]models.RefreshToken{},
+		passwordResets: map[string]models.PasswordReset{},
+		twoFactors:     map[string]models.TwoFactor{},
+		accessTokens:   map[string]models.PersonalAccessToken{},
+	}
+}
+
+// Ping always succeeds, there is nothing to reach
+func (s *Store) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, user *models.UserInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, found := s.findUser(func(u *models.UserInfo) bool {
+		return u.UUID == user.UUID || u.Email == user.Email || u.Account_ID == user.Account_ID
+	})
+	if found {
+		return db.ErrDuplicate
+	}
+
+	s.users[user.UUID] = *user
+
+	return nil
+}
+
+func (s *Store) UserExists(ctx context.Context, email string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, found := s.findUser(func(u *models.UserInfo) bool { return u.Email == email })
+
+	return found, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*models.UserInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, found := s.findUser(func(u *models.UserInfo) bool { return u.Email == email })
+	if !found {
+		return nil, db.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (s *Store) GetUserByUUID(ctx context.Context, userUUID string) (*models.UserInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, found := s.users[userUUID]
+	if !found {
+		return nil, db.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, userUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, userUUID)
+
+	return nil
+}
+
+func (s *Store) UpdatePassword(ctx context.Context, userUUID string, newPassword string) error {
+	return s.updateUser(func(u *models.UserInfo) bool { return u.UUID == userUUID }, func(u *models.UserInfo) {
+		u.Password = newPassword
+	})
+}
+
+func (s *Store) RehashPassword(ctx context.Context, userUUID string, oldHash string, newHash string) error {
+	return s.updateUser(func(u *models.UserInfo) bool { return u.UUID == userUUID && u.Password == oldHash }, func(u *models.UserInfo) {
+		u.Password = newHash
+	})
+}
+
+func (s *Store) SetEmailVerified(ctx context.Context, userUUID string, verifiedAt time.Time) error {
+	return s.updateUser(func(u *models.UserInfo) bool { return u.UUID == userUUID }, func(u *models.UserInfo) {
+		u.Verified_At = &verifiedAt
+	})
+}
+
+func (s *Store) IncrementTweetsCount(ctx context.Context, userUUID string) error {
+	return s.updateUser(func(u *models.UserInfo) bool { return u.UUID == userUUID }, func(u *models.UserInfo) {
+		u.Metrics.Total_tweets_count++
+	})
+}
+
+// findUser returns the first user matching the filter, the caller must hold the lock
+func (s *Store) findUser(filter func(*models.UserInfo) bool) (models.UserInfo, bool) {
+	for _, user := range s.users {
+		if filter(&user) {
+			return user, true
+		}
+	}
+
+	return models.UserInfo{}, false
+}
+
+func (s *Store) updateUser(filter func(*models.UserInfo) bool, update func(*models.UserInfo)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, found := s.findUser(filter)
+	if !found {
+		return db.ErrNotFound
+	}
+
+	update(&user)
+	s.users[user.UUID] = user
+
+	return nil
+}
+
+func (s *Store) CreateTweet(ctx context.Context, tweet *models.TweetDB) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tweets = append(s.tweets, *tweet)
+
+	return nil
+}
+
+func (s *Store) GetTweetsByUserUUID(ctx context.Context, userUUID string) ([]models.Tweet, error) {
+	return s.findTweets(func(t *models.TweetDB) bool { return t.User_UUID == userUUID }, false, 0), nil
+}
+
+func (s *Store) GetTweetsByAccountID(ctx context.Context, accountID string) ([]models.Tweet, error) {
+	return s.findTweets(func(t *models.TweetDB) bool { return t.Account_ID == accountID }, false, 0), nil
+}
+
+func (s *Store) GetTweetsForAccounts(ctx context.Context, accountIDs []string, limit int) ([]models.Tweet, error) {
+
+	accounts := make(map[string]bool, len(accountIDs))
+	for _, accountID := range accountIDs {
+		accounts[accountID] = true
+	}
+
+	return s.findTweets(func(t *models.TweetDB) bool { return accounts[t.Account_ID] }, true, limit), nil
+}
+
+// findTweets returns the matching tweets in insertion order, or newest on top when newestFirst is set.
+// A limit of 0 means no limit.
+func (s *Store) findTweets(filter func(*models.TweetDB) bool, newestFirst bool, limit int) []models.Tweet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matching []models.TweetDB
+	for i := range s.tweets {
+		if filter(&s.tweets[i]) {
+			matching = append(matching, s.tweets[i])
+		}
+	}
+
+	if newestFirst {
+		sort.SliceStable(matching, func(i, j int) bool {
+			return matching[i].Created_At.After(matching[j].Created_At)
+		})
+	}
+
+	if limit > 0 && len(matching) > limit {
+		matching = matching[:limit]
+	}
+
+	tweets := []models.Tweet{}
+	for _, t := range matching {
+		tweets = append(tweets, models.Tweet{
+			Tweet_UUID: t.Tweet_UUID,
+			Tweet:      t.Tweet,
+			Metrics:    t.Metrics,
+		})
+	}
+
+	return tweets
+}
+
+func (s *Store) Follow(ctx context.Context, follow *models.Followers) (bool, error) {
+
+	// the whole unit runs under the write lock so concurrent follows are serialized
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.follows {
+		if f.Follower_Account_ID == follow.Follower_Account_ID && f.Following_Account_ID == follow.Following_Account_ID {
+			return false, nil
+		}
+	}
+
+	follower, found := s.findUser(func(u *models.UserInfo) bool { return u.Account_ID == follow.Follower_Account_ID })
+	if !found {
+		return false, db.ErrNotFound
+	}
+
+	following, found := s.findUser(func(u *models.UserInfo) bool { return u.Account_ID == follow.Following_Account_ID })
+	if !found {
+		return false, db.ErrNotFound
+	}
+
+	s.follows = append(s.follows, *follow)
+
+	follower.Metrics.Following_count++
+	s.users[follower.UUID] = follower
+
+	// read the followed account again in case it is the follower itself
+	following = s.users[following.UUID]
+	following.Metrics.Followers_count++
+	s.users[following.UUID] = following
+
+	return true, nil
+}
+
+func (s *Store) FollowExists(ctx context.Context, followerAccountID string, followingAccountID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, f := range s.follows {
+		if f.Follower_Account_ID == followerAccountID && f.Following_Account_ID == followingAccountID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *Store) GetFollowingAccountIDs(ctx context.Context, accountID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var followingAccountIDs []string
+	for _, f := range s.follows {
+		if f.Follower_Account_ID == accountID {
+			followingAccountIDs = append(followingAccountIDs, f.Following_Account_ID)
+		}
+	}
+
+	return followingAccountIDs, nil
+}
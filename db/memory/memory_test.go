@@ -0,0 +1,12 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/db/dbtest"
+)
+
+func TestStore(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Store { return New() })
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+var _ db.MetricsStore = (*Store)(nil)
+
+// ForEachUser iterates over a snapshot of the users so that fn can call the store
+func (s *Store) ForEachUser(ctx context.Context, fn func(user *models.UserInfo) error) error {
+	s.mu.RLock()
+	users := make([]models.UserInfo, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	s.mu.RUnlock()
+
+	for i := range users {
+		if err := fn(&users[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachTweet iterates over a snapshot of the tweets so that fn can call the store
+func (s *Store) ForEachTweet(ctx context.Context, fn func(tweet *models.TweetDB) error) error {
+	s.mu.RLock()
+	tweets := append([]models.TweetDB(nil), s.tweets...)
+	s.mu.RUnlock()
+
+	for i := range tweets {
+		if err := fn(&tweets[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) CountUserMetrics(ctx context.Context, user *models.UserInfo) (models.UserMetrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var metrics models.UserMetrics
+
+	for _, f := range s.follows {
+		if f.Following_Account_ID == user.Account_ID {
+			metrics.Followers_count++
+		}
+
+		if f.Follower_Account_ID == user.Account_ID {
+			metrics.Following_count++
+		}
+	}
+
+	for _, t := range s.tweets {
+		if t.User_UUID == user.UUID {
+			metrics.Total_tweets_count++
+			metrics.Total_retweets_count += t.Metrics.Retweets_count
+			metrics.Total_likes_count += t.Metrics.Likes_count
+		}
+	}
+
+	return metrics, nil
+}
+
+func (s *Store) SetUserMetrics(ctx context.Context, userUUID string, old models.UserMetrics, new models.UserMetrics) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, found := s.users[userUUID]
+	if !found || user.Metrics != old {
+		return false, nil
+	}
+
+	user.Metrics = new
+	s.users[userUUID] = user
+
+	return true, nil
+}
+
+func (s *Store) SetTweetMetrics(ctx context.Context, tweetUUID string, old models.TweetMetrics, new models.TweetMetrics) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.tweets {
+		if s.tweets[i].Tweet_UUID == tweetUUID {
+			if s.tweets[i].Metrics != old {
+				return false, nil
+			}
+
+			s.tweets[i].Metrics = new
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.passwordResets[reset.Hash]; found {
+		return db.ErrDuplicate
+	}
+
+	// only the newest reset of the user works
+	for hash, earlier := range s.passwordResets {
+		if earlier.User_UUID == reset.User_UUID && !earlier.Used {
+			earlier.Invalidated = true
+			s.passwordResets[hash] = earlier
+		}
+	}
+
+	s.passwordResets[reset.Hash] = *reset
+
+	return nil
+}
+
+func (s *Store) GetPasswordReset(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reset, found := s.passwordResets[hash]
+	if !found {
+		return nil, db.ErrNotFound
+	}
+
+	return &reset, nil
+}
+
+func (s *Store) UsePasswordReset(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset, found := s.passwordResets[hash]
+	if !found {
+		return nil, db.ErrNotFound
+	}
+
+	if reset.Used || reset.Invalidated {
+		return &reset, db.ErrAlreadyUsed
+	}
+
+	used := reset
+	used.Used = true
+	s.passwordResets[hash] = used
+
+	return &reset, nil
+}
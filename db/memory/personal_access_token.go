@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, existing := range s.accessTokens {
+		if hash == token.Hash || existing.ID == token.ID {
+			return db.ErrDuplicate
+		}
+	}
+
+	s.accessTokens[token.Hash] = copyAccessToken(*token)
+
+	return nil
+}
+
+func (s *Store) GetPersonalAccessToken(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, found := s.accessTokens[hash]
+	if !found {
+		return nil, db.ErrNotFound
+	}
+
+	token = copyAccessToken(token)
+
+	return &token, nil
+}
+
+func (s *Store) ListPersonalAccessTokens(ctx context.Context, userUUID string) ([]models.PersonalAccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tokens []models.PersonalAccessToken
+	for _, token := range s.accessTokens {
+		if token.User_UUID == userUUID {
+			tokens = append(tokens, copyAccessToken(token))
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Created_At.After(tokens[j].Created_At)
+	})
+
+	return tokens, nil
+}
+
+func (s *Store) RevokePersonalAccessToken(ctx context.Context, userUUID string, id string, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.accessTokens {
+		if token.User_UUID == userUUID && token.ID == id {
+
+			if token.Revoked_At == nil {
+				token.Revoked_At = &revokedAt
+				s.accessTokens[hash] = token
+			}
+
+			return nil
+		}
+	}
+
+	return db.ErrNotFound
+}
+
+func (s *Store) RevokePersonalAccessTokens(ctx context.Context, userUUID string, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.accessTokens {
+		if token.User_UUID == userUUID && token.Revoked_At == nil {
+			token.Revoked_At = &revokedAt
+			s.accessTokens[hash] = token
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) SetPersonalAccessTokenLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.accessTokens {
+		if token.ID == id {
+			token.Last_Used_At = &usedAt
+			s.accessTokens[hash] = token
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) DeletePersonalAccessTokens(ctx context.Context, userUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.accessTokens {
+		if token.User_UUID == userUUID {
+			delete(s.accessTokens, hash)
+		}
+	}
+
+	return nil
+}
+
+// copyAccessToken gives the caller its own scopes
+func copyAccessToken(token models.PersonalAccessToken) models.PersonalAccessToken {
+	token.Scopes = append([]string(nil), token.Scopes...)
+	return token
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.refreshTokens[token.Hash]; found {
+		return db.ErrDuplicate
+	}
+
+	s.refreshTokens[token.Hash] = *token
+
+	return nil
+}
+
+func (s *Store) UseRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, found := s.refreshTokens[hash]
+	if !found {
+		return nil, db.ErrNotFound
+	}
+
+	if token.Used {
+		return &token, db.ErrAlreadyUsed
+	}
+
+	used := token
+	used.Used = true
+	s.refreshTokens[hash] = used
+
+	return &token, nil
+}
+
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.refreshTokens {
+		if token.Family_ID == familyID {
+			token.Revoked = true
+			s.refreshTokens[hash] = token
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreateTwoFactor(ctx context.Context, twoFactor *models.TwoFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, found := s.twoFactors[twoFactor.User_UUID]; found && existing.Enabled_At != nil {
+		return db.ErrDuplicate
+	}
+
+	s.twoFactors[twoFactor.User_UUID] = *twoFactor
+
+	return nil
+}
+
+func (s *Store) GetTwoFactor(ctx context.Context, userUUID string) (*models.TwoFactor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	twoFactor, found := s.twoFactors[userUUID]
+	if !found {
+		return nil, db.ErrNotFound
+	}
+
+	// the caller gets its own recovery codes
+	twoFactor.Recovery_Codes = append([]models.RecoveryCode(nil), twoFactor.Recovery_Codes...)
+
+	return &twoFactor, nil
+}
+
+func (s *Store) EnableTwoFactor(ctx context.Context, userUUID string, enabledAt time.Time, recoveryCodes []models.RecoveryCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twoFactor, found := s.twoFactors[userUUID]
+	if !found || twoFactor.Enabled_At != nil {
+		return db.ErrNotFound
+	}
+
+	twoFactor.Enabled_At = &enabledAt
+	twoFactor.Recovery_Codes = append([]models.RecoveryCode(nil), recoveryCodes...)
+	s.twoFactors[userUUID] = twoFactor
+
+	return nil
+}
+
+func (s *Store) UseTwoFactorStep(ctx context.Context, userUUID string, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twoFactor, found := s.twoFactors[userUUID]
+	if !found {
+		return db.ErrNotFound
+	}
+
+	if twoFactor.Last_Step >= step {
+		return db.ErrAlreadyUsed
+	}
+
+	twoFactor.Last_Step = step
+	s.twoFactors[userUUID] = twoFactor
+
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, userUUID string, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twoFactor, found := s.twoFactors[userUUID]
+	if !found {
+		return db.ErrNotFound
+	}
+
+	for i, code := range twoFactor.Recovery_Codes {
+		if code.Hash == hash && !code.Used {
+			twoFactor.Recovery_Codes[i].Used = true
+			return nil
+		}
+	}
+
+	return db.ErrNotFound
+}
+
+func (s *Store) DeleteTwoFactor(ctx context.Context, userUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.twoFactors, userUUID)
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MigrationStatus describes a schema or data migration and when it was applied
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time // zero when the migration is still pending
+}
+
+// Applied reports whether the migration was applied
+func (m MigrationStatus) Applied() bool {
+	return !m.AppliedAt.IsZero()
+}
+
+// Migrator runs the versioned migrations of a backend in order and records the applied ones
+type Migrator interface {
+
+	// Migrate applies every pending migration and returns the ones it applied
+	Migrate(ctx context.Context) ([]MigrationStatus, error)
+
+	// Status lists every known migration, applied or pending
+	Status(ctx context.Context) ([]MigrationStatus, error)
+}
+
+// MigrationError is returned when a migration fails, the migrations before it stay applied
+type MigrationError struct {
+	Version     int
+	Description string
+	Err         error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("db: migration %d (%s) failed: %v", e.Version, e.Description, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
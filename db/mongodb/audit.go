@@ -0,0 +1,12 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	_, err := s.auditEventsCol.InsertOne(ctx, event)
+	return err
+}
@@ -0,0 +1,127 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errAlreadyFollowing aborts the follow transaction when the follow exists already
+var errAlreadyFollowing = errors.New("mongodb: already following")
+
+// Follow runs in a multi-document transaction, which needs a replica set or a sharded cluster.
+// On a standalone server it falls back to compensating writes: a failed counter update undoes
+// the writes before it. That leaves a small window where a crash can leave the counters off,
+// which the metrics reconciliation repairs.
+func (s *Store) Follow(ctx context.Context, follow *models.Followers) (bool, error) {
+
+	if atomic.LoadInt32(&s.noTransactions) == 0 {
+		created, err := s.followInTransaction(ctx, follow)
+		if !isTransactionNotSupported(err) {
+			return created, err
+		}
+
+		atomic.StoreInt32(&s.noTransactions, 1)
+		s.logger.Println("MongoDB does not support transactions, follows use compensating writes.")
+	}
+
+	return s.followWithCompensation(ctx, follow)
+}
+
+func (s *Store) followInTransaction(ctx context.Context, follow *models.Followers) (bool, error) {
+
+	session, err := s.followersCol.Database().Client().StartSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+
+		// the unique index on the follower-following pair rejects concurrent duplicates
+		_, err := s.followersCol.InsertOne(sessCtx, follow)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errAlreadyFollowing
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.incrementCount(sessCtx, follow.Follower_Account_ID, "metrics.following_count", 1); err != nil {
+			return nil, err
+		}
+
+		return nil, s.incrementCount(sessCtx, follow.Following_Account_ID, "metrics.followers_count", 1)
+	})
+
+	if err == errAlreadyFollowing {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Store) followWithCompensation(ctx context.Context, follow *models.Followers) (bool, error) {
+
+	_, err := s.followersCol.InsertOne(ctx, follow)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	// undo uses its own context so that a canceled request still cleans up after itself
+	undoFollow := func() {
+		s.followersCol.DeleteOne(context.Background(), bson.M{"id": follow.ID})
+	}
+
+	if err := s.incrementCount(ctx, follow.Follower_Account_ID, "metrics.following_count", 1); err != nil {
+		undoFollow()
+		return false, err
+	}
+
+	if err := s.incrementCount(ctx, follow.Following_Account_ID, "metrics.followers_count", 1); err != nil {
+		s.incrementCount(context.Background(), follow.Follower_Account_ID, "metrics.following_count", -1)
+		undoFollow()
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Store) incrementCount(ctx context.Context, accountID string, field string, by int) error {
+
+	result, err := s.usersCol.UpdateOne(ctx, bson.M{"account_id": accountID}, bson.M{"$inc": bson.M{field: by}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return db.ErrNotFound
+	}
+
+	return nil
+}
+
+// isTransactionNotSupported reports whether err comes from a server that can't run transactions
+func isTransactionNotSupported(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 20 {
+		return true
+	}
+
+	return err != nil && strings.Contains(err.Error(), "Transaction numbers are only allowed")
+}
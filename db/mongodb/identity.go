@@ -0,0 +1,41 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (s *Store) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+
+	_, err := s.identitiesCol.InsertOne(ctx, identity)
+	if mongo.IsDuplicateKeyError(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+func (s *Store) GetIdentity(ctx context.Context, provider string, subject string) (*models.Identity, error) {
+
+	var identity models.Identity
+
+	err := s.identitiesCol.FindOne(ctx, bson.M{"provider": provider, "subject": subject}).Decode(&identity)
+	if err == mongo.ErrNoDocuments {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (s *Store) DeleteIdentities(ctx context.Context, userUUID string) error {
+	_, err := s.identitiesCol.DeleteMany(ctx, bson.M{"user_uuid": userUUID})
+	return err
+}
@@ -0,0 +1,137 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.MetricsStore = (*Store)(nil)
+
+func (s *Store) ForEachUser(ctx context.Context, fn func(user *models.UserInfo) error) error {
+	return forEach(ctx, s.usersCol, func(cursor *mongo.Cursor) error {
+		var user models.UserInfo
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+
+		return fn(&user)
+	})
+}
+
+func (s *Store) ForEachTweet(ctx context.Context, fn func(tweet *models.TweetDB) error) error {
+	return forEach(ctx, s.tweetsCol, func(cursor *mongo.Cursor) error {
+		var tweet models.TweetDB
+		if err := cursor.Decode(&tweet); err != nil {
+			return err
+		}
+
+		return fn(&tweet)
+	})
+}
+
+func forEach(ctx context.Context, col *mongo.Collection, fn func(cursor *mongo.Cursor) error) error {
+
+	cursor, err := col.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := fn(cursor); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (s *Store) CountUserMetrics(ctx context.Context, user *models.UserInfo) (models.UserMetrics, error) {
+	var metrics models.UserMetrics
+
+	followers, err := s.followersCol.CountDocuments(ctx, bson.M{"following_account_id": user.Account_ID})
+	if err != nil {
+		return metrics, err
+	}
+
+	following, err := s.followersCol.CountDocuments(ctx, bson.M{"follower_account_id": user.Account_ID})
+	if err != nil {
+		return metrics, err
+	}
+
+	// sum the metrics of all the tweets of the user in one pass
+	cursor, err := s.tweetsCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_uuid": user.UUID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      nil,
+			"tweets":   bson.M{"$sum": 1},
+			"retweets": bson.M{"$sum": "$metrics.retweets_count"},
+			"likes":    bson.M{"$sum": "$metrics.likes_count"},
+		}}},
+	})
+	if err != nil {
+		return metrics, err
+	}
+
+	var totals []struct {
+		Tweets   int `bson:"tweets"`
+		Retweets int `bson:"retweets"`
+		Likes    int `bson:"likes"`
+	}
+
+	if err := cursor.All(ctx, &totals); err != nil {
+		return metrics, err
+	}
+
+	metrics.Followers_count = int(followers)
+	metrics.Following_count = int(following)
+
+	if len(totals) > 0 {
+		metrics.Total_tweets_count = totals[0].Tweets
+		metrics.Total_retweets_count = totals[0].Retweets
+		metrics.Total_likes_count = totals[0].Likes
+	}
+
+	return metrics, nil
+}
+
+func (s *Store) SetUserMetrics(ctx context.Context, userUUID string, old models.UserMetrics, new models.UserMetrics) (bool, error) {
+
+	filter := bson.M{
+		"uuid":                         userUUID,
+		"metrics.followers_count":      old.Followers_count,
+		"metrics.following_count":      old.Following_count,
+		"metrics.total_tweets_count":   old.Total_tweets_count,
+		"metrics.total_retweets_count": old.Total_retweets_count,
+		"metrics.total_likes_count":    old.Total_likes_count,
+	}
+
+	result, err := s.usersCol.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"metrics": new}})
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (s *Store) SetTweetMetrics(ctx context.Context, tweetUUID string, old models.TweetMetrics, new models.TweetMetrics) (bool, error) {
+
+	filter := bson.M{
+		"tweet_uuid":               tweetUUID,
+		"metrics.retweets_count":   old.Retweets_count,
+		"metrics.likes_count":      old.Likes_count,
+		"metrics.comments_count":   old.Comments_count,
+		"metrics.characters_count": old.Characters_count,
+	}
+
+	result, err := s.tweetsCol.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"metrics": new}})
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
@@ -0,0 +1,342 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migration is a schema or data change of the database, migrations must be idempotent
+// since a failed run is retried from the first unrecorded migration
+type migration struct {
+	version     int
+	description string
+	up          func(ctx context.Context, database *mongo.Database) error
+}
+
+// migrations are applied in order, never change or reorder the ones that were released, add new ones instead
+var migrations = []migration{
+	{
+		version:     1,
+		description: "unique indexes on Users uuid, email and account_id",
+		up: func(ctx context.Context, database *mongo.Database) error {
+
+			// the duplicates are accounts of their own, they are reported rather than removed
+			if err := checkDuplicateUsers(ctx, database.Collection("Users")); err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, database.Collection("Users"),
+				mongo.IndexModel{Keys: bson.D{{Key: "uuid", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "account_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			)
+		},
+	},
+	{
+		version:     2,
+		description: "remove duplicate follower-following pairs",
+		up:          removeDuplicateFollows,
+	},
+	{
+		version:     3,
+		description: "unique index on Followers follower_account_id and following_account_id",
+		up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("Followers"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "follower_account_id", Value: 1}, {Key: "following_account_id", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+			)
+		},
+	},
+	{
+		version:     4,
+		description: "Tweets indexes for feeds and user timelines",
+		up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("Tweets"),
+				mongo.IndexModel{Keys: bson.D{{Key: "account_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_uuid", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "tweet_uuid", Value: 1}}, Options: options.Index().SetUnique(true)},
+			)
+		},
+	},
+	{
+		version:     5,
+		description: "RefreshTokens indexes, expired tokens are removed by MongoDB",
+		up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("RefreshTokens"),
+				mongo.IndexModel{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "family_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			)
+		},
+	},
+	{
+		version:     6,
+		description: "PasswordResets and AuditEvents indexes, expired resets are removed by MongoDB",
+		up: func(ctx context.Context, database *mongo.Database) error {
+
+			err := createIndexes(ctx, database.Collection("PasswordResets"),
+				mongo.IndexModel{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_uuid", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			)
+			if err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, database.Collection("AuditEvents"),
+				mongo.IndexModel{Keys: bson.D{{Key: "user_uuid", Value: 1}, {Key: "created_at", Value: 1}}},
+			)
+		},
+	},
+	{
+		version:     7,
+		description: "verified_at of Users, the existing accounts count as verified",
+		up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("Users").UpdateMany(ctx,
+				bson.M{"verified_at": bson.M{"$exists": false}},
+				mongo.Pipeline{{{Key: "$set", Value: bson.M{"verified_at": "$created_at"}}}},
+			)
+			return err
+		},
+	},
+	{
+		version:     8,
+		description: "unique user_uuid index of TwoFactors",
+		up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("TwoFactors"),
+				mongo.IndexModel{Keys: bson.D{{Key: "user_uuid", Value: 1}}, Options: options.Index().SetUnique(true)},
+			)
+		},
+	},
+	{
+		version:     9,
+		description: "Identities indexes, unique on provider and subject",
+		up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("Identities"),
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_uuid", Value: 1}}},
+			)
+		},
+	},
+	{
+		version:     10,
+		description: "PersonalAccessTokens indexes",
+		up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("PersonalAccessTokens"),
+				mongo.IndexModel{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_uuid", Value: 1}, {Key: "created_at", Value: -1}}},
+			)
+		},
+	},
+}
+
+const migrationsCollection = "schema_migrations"
+
+// Migrator runs the migrations of the database and records them in the schema_migrations collection
+type Migrator struct {
+	database   *mongo.Database
+	migrations []migration
+}
+
+var _ db.Migrator = (*Migrator)(nil)
+
+func NewMigrator(database *mongo.Database) *Migrator {
+	return &Migrator{
+		database:   database,
+		migrations: migrations,
+	}
+}
+
+type migrationRecord struct {
+	Version     int       `bson:"version"`
+	Description string    `bson:"description"`
+	Applied_At  time.Time `bson:"applied_at"`
+}
+
+func (m *Migrator) Migrate(ctx context.Context) ([]db.MigrationStatus, error) {
+
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var newlyApplied []db.MigrationStatus
+
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.version]; ok {
+			continue
+		}
+
+		if err := mig.up(ctx, m.database); err != nil {
+			return newlyApplied, &db.MigrationError{Version: mig.version, Description: mig.description, Err: err}
+		}
+
+		record := migrationRecord{
+			Version:     mig.version,
+			Description: mig.description,
+			Applied_At:  time.Now().UTC(),
+		}
+
+		// upsert so that two instances migrating at the same time don't fail on each other
+		_, err := m.database.Collection(migrationsCollection).UpdateOne(ctx,
+			bson.M{"version": mig.version},
+			bson.M{"$setOnInsert": record},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return newlyApplied, &db.MigrationError{Version: mig.version, Description: mig.description, Err: err}
+		}
+
+		newlyApplied = append(newlyApplied, db.MigrationStatus{
+			Version:     record.Version,
+			Description: record.Description,
+			AppliedAt:   record.Applied_At,
+		})
+	}
+
+	return newlyApplied, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]db.MigrationStatus, error) {
+
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []db.MigrationStatus
+	for _, mig := range m.migrations {
+		statuses = append(statuses, db.MigrationStatus{
+			Version:     mig.version,
+			Description: mig.description,
+			AppliedAt:   applied[mig.version].Applied_At,
+		})
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) appliedMigrations(ctx context.Context) (map[int]migrationRecord, error) {
+
+	cursor, err := m.database.Collection(migrationsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []migrationRecord
+
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]migrationRecord{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	return applied, nil
+}
+
+func createIndexes(ctx context.Context, col *mongo.Collection, indexes ...mongo.IndexModel) error {
+	_, err := col.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// removeDuplicateFollows keeps the first document of every follower-following pair and deletes the rest
+func removeDuplicateFollows(ctx context.Context, database *mongo.Database) error {
+
+	col := database.Collection("Followers")
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"follower":  "$follower_account_id",
+				"following": "$following_account_id",
+			},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+
+	cursor, err := col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var groups []struct {
+		IDs []interface{} `bson:"ids"`
+	}
+
+	if err := cursor.All(ctx, &groups); err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		_, err := col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkDuplicateUsers fails with the list of the users sharing a uuid, an email or an account_id,
+// which would fail the unique indexes. They have to be merged or deleted before migrating again.
+func checkDuplicateUsers(ctx context.Context, col *mongo.Collection) error {
+
+	var conflicts []string
+
+	for _, field := range []string{"uuid", "email", "account_id"} {
+		pipeline := mongo.Pipeline{
+			{{Key: "$group", Value: bson.M{
+				"_id":   "$" + field,
+				"ids":   bson.M{"$push": "$_id"},
+				"count": bson.M{"$sum": 1},
+			}}},
+			{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		}
+
+		cursor, err := col.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+		if err != nil {
+			return err
+		}
+
+		var groups []struct {
+			Value interface{}   `bson:"_id"`
+			IDs   []interface{} `bson:"ids"`
+		}
+
+		if err := cursor.All(ctx, &groups); err != nil {
+			return err
+		}
+
+		for _, group := range groups {
+			ids := make([]string, len(group.IDs))
+			for i, id := range group.IDs {
+				ids[i] = fmt.Sprint(id)
+			}
+
+			conflicts = append(conflicts, fmt.Sprintf("%s %q is shared by the documents %s", field, fmt.Sprint(group.Value), strings.Join(ids, ", ")))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("the Users collection has duplicates, merge or delete them and migrate again:\n  %s", strings.Join(conflicts, "\n  "))
+	}
+
+	return nil
+}
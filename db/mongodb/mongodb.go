@@ -0,0 +1,263 @@
+package mongodb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	models "github.com/Bruary/twitter-clone/service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store implements db.Store on top of MongoDB
+type Store struct {
+	usersCol     *mongo.Collection
+	tweetsCol    *mongo.Collection
+	followersCol *mongo.Collection
+
+	refreshTokensCol  *mongo.Collection
+	passwordResetsCol *mongo.Collection
+	auditEventsCol    *mongo.Collection
+	twoFactorsCol     *mongo.Collection
+	identitiesCol     *mongo.Collection
+	accessTokensCol   *mongo.Collection
+
+	logger *log.Logger
+
+	noTransactions int32 // set once the server refused a transaction, accessed atomically
+}
+
+var _ db.Store = (*Store)(nil)
+
+// New connects the store to the collections of the given database, the notices of the store go to logger
+func New(database *mongo.Database, logger *log.Logger) *Store {
+	return &Store{
+		usersCol:     database.Collection("Users"),
+		tweetsCol:    database.Collection("Tweets"),
+		followersCol: database.Collection("Followers"),
+
+		refreshTokensCol:  database.Collection("RefreshTokens"),
+		passwordResetsCol: database.Collection("PasswordResets"),
+		auditEventsCol:    database.Collection("AuditEvents"),
+		twoFactorsCol:     database.Collection("TwoFactors"),
+		identitiesCol:     database.Collection("Identities"),
+		accessTokensCol:   database.Collection("PersonalAccessTokens"),
+
+		logger: logger,
+	}
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.usersCol.Database().Client().Ping(ctx, nil)
+}
+
+// Connect establishes a connection to the MongoDB server and returns the configured database,
+// ctx bounds the connection and the first ping
+func Connect(ctx context.Context, cfg config.Mongo) (*mongo.Database, error) {
+
+	// Set client options
+	clientOptions := options.Client().ApplyURI(cfg.URI)
+
+	// Connect to MongoDB
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the connection
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	return client.Database(cfg.Database), nil
+}
+
+// ignore the following fields and return the rest from the db
+var tweetProjection = bson.M{
+	"user_uuid":  0,
+	"email":      0,
+	"created_at": 0,
+	"updated_at": 0,
+}
+
+func (s *Store) CreateUser(ctx context.Context, user *models.UserInfo) error {
+
+	_, err := s.usersCol.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return db.ErrDuplicate
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Store) UserExists(ctx context.Context, email string) (bool, error) {
+	count, err := s.usersCol.CountDocuments(ctx, bson.M{"email": email})
+	if count >= 1 {
+		return true, nil
+	} else {
+		return false, err
+	}
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*models.UserInfo, error) {
+	return s.findUser(ctx, bson.M{"email": email})
+}
+
+func (s *Store) GetUserByUUID(ctx context.Context, userUUID string) (*models.UserInfo, error) {
+	return s.findUser(ctx, bson.M{"uuid": userUUID})
+}
+
+func (s *Store) findUser(ctx context.Context, filter bson.M) (*models.UserInfo, error) {
+	result := s.usersCol.FindOne(ctx, filter)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.ErrNotFound
+	}
+
+	var user models.UserInfo
+
+	if err := result.Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, userUUID string) error {
+	_, err := s.usersCol.DeleteOne(ctx, bson.M{"uuid": userUUID})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Store) UpdatePassword(ctx context.Context, userUUID string, newPassword string) error {
+	return s.updateUser(ctx, bson.M{"uuid": userUUID}, bson.M{"$set": bson.M{"password": newPassword}})
+}
+
+func (s *Store) RehashPassword(ctx context.Context, userUUID string, oldHash string, newHash string) error {
+	return s.updateUser(ctx, bson.M{"uuid": userUUID, "password": oldHash}, bson.M{"$set": bson.M{"password": newHash}})
+}
+
+func (s *Store) SetEmailVerified(ctx context.Context, userUUID string, verifiedAt time.Time) error {
+	return s.updateUser(ctx, bson.M{"uuid": userUUID}, bson.M{"$set": bson.M{"verified_at": verifiedAt}})
+}
+
+func (s *Store) IncrementTweetsCount(ctx context.Context, userUUID string) error {
+	return s.updateUser(ctx, bson.M{"uuid": userUUID}, bson.M{"$inc": bson.M{"metrics.total_tweets_count": 1}})
+}
+
+func (s *Store) updateUser(ctx context.Context, filter bson.M, update bson.M) error {
+	result := s.usersCol.FindOneAndUpdate(ctx, filter, update)
+	if result.Err() == mongo.ErrNoDocuments {
+		return db.ErrNotFound
+	}
+
+	return result.Err()
+}
+
+func (s *Store) CreateTweet(ctx context.Context, tweet *models.TweetDB) error {
+
+	_, err := s.tweetsCol.InsertOne(ctx, tweet)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Store) GetTweetsByUserUUID(ctx context.Context, userUUID string) ([]models.Tweet, error) {
+	return s.findTweets(ctx, bson.M{"user_uuid": userUUID}, options.Find().SetProjection(tweetProjection))
+}
+
+func (s *Store) GetTweetsByAccountID(ctx context.Context, accountID string) ([]models.Tweet, error) {
+	return s.findTweets(ctx, bson.M{"account_id": accountID}, options.Find().SetProjection(tweetProjection))
+}
+
+func (s *Store) GetTweetsForAccounts(ctx context.Context, accountIDs []string, limit int) ([]models.Tweet, error) {
+
+	// get any document that has one of the accountIDs listed in the array
+	filter := bson.M{"account_id": bson.M{"$in": accountIDs}}
+
+	// sort the document according to time/date (newest on top)
+	sortCriteria := bson.M{"created_at": -1}
+
+	return s.findTweets(ctx, filter, options.Find().SetSort(sortCriteria).SetProjection(tweetProjection).SetLimit(int64(limit)))
+}
+
+func (s *Store) findTweets(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Tweet, error) {
+	var tweets []models.Tweet
+
+	cursor, err := s.tweetsCol.Find(ctx, filter, opts)
+	if err != nil {
+		return tweets, err
+	}
+
+	// If there are no tweets then return an empty string
+	if cursor.RemainingBatchLength() == 0 {
+		return []models.Tweet{}, nil
+	}
+
+	// Decode all the tweets from the db to the Tweets struct
+	err2 := cursor.All(ctx, &tweets)
+	if err2 != nil {
+		return tweets, err2
+	}
+
+	return tweets, nil
+}
+
+func (s *Store) FollowExists(ctx context.Context, followerAccountID string, followingAccountID string) (bool, error) {
+
+	result := s.followersCol.FindOne(ctx, bson.M{
+		"follower_account_id":  followerAccountID,
+		"following_account_id": followingAccountID})
+
+	if result.Err() == mongo.ErrNoDocuments {
+		return false, nil
+	}
+
+	return result.Err() == nil, result.Err()
+}
+
+func (s *Store) GetFollowingAccountIDs(ctx context.Context, accountID string) ([]string, error) {
+
+	projection := bson.M{
+		"id":                  0,
+		"follower_account_id": 0,
+	}
+
+	// get all document that has the follower account ID
+	// then remove all fields and keep only the folloing_account_id field
+	cursor, err := s.followersCol.Find(ctx, bson.M{"follower_account_id": accountID}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.Followers
+
+	// fill the result array using cursor.All()
+	err2 := cursor.All(ctx, &result)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	// we only need the value not the key/value pair or object
+	// thus we used a for-loop to get only the value
+	var finalOutput []string
+	for i := 0; i < len(result); i++ {
+		finalOutput = append(finalOutput, result[i].Following_Account_ID)
+	}
+
+	return finalOutput, nil
+}
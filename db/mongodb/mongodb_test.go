@@ -0,0 +1,49 @@
+package mongodb
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/db/dbtest"
+)
+
+// newStore returns a store on a migrated database of its own at TEST_MONGO_URI, dropped once the test is done.
+// The test is skipped when TEST_MONGO_URI is not set.
+func newStore(t *testing.T) *Store {
+	t.Helper()
+
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	database, err := Connect(ctx, config.Mongo{URI: uri, Database: "twitter_test_" + strconv.FormatInt(time.Now().UnixNano(), 36)})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	t.Cleanup(func() {
+		database.Drop(context.Background())
+		database.Client().Disconnect(context.Background())
+	})
+
+	if _, err := NewMigrator(database).Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	return New(database, log.New(ioutil.Discard, "", 0))
+}
+
+func TestStore(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Store { return newStore(t) })
+}
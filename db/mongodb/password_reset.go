@@ -0,0 +1,72 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CreatePasswordReset invalidates the earlier resets before saving the new one,
+// a failure in between leaves the user without a working reset rather than with two
+func (s *Store) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+
+	_, err := s.passwordResetsCol.UpdateMany(ctx,
+		bson.M{"user_uuid": reset.User_UUID, "used": false},
+		bson.M{"$set": bson.M{"invalidated": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.passwordResetsCol.InsertOne(ctx, reset)
+	if mongo.IsDuplicateKeyError(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+func (s *Store) GetPasswordReset(ctx context.Context, hash string) (*models.PasswordReset, error) {
+
+	var reset models.PasswordReset
+
+	err := s.passwordResetsCol.FindOne(ctx, bson.M{"hash": hash}).Decode(&reset)
+	if err == mongo.ErrNoDocuments {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &reset, nil
+}
+
+// UsePasswordReset flips the used flag with a single conditional update, so that only one caller sees it usable
+func (s *Store) UsePasswordReset(ctx context.Context, hash string) (*models.PasswordReset, error) {
+
+	var reset models.PasswordReset
+
+	err := s.passwordResetsCol.FindOneAndUpdate(ctx,
+		bson.M{"hash": hash, "used": false, "invalidated": false},
+		bson.M{"$set": bson.M{"used": true}},
+	).Decode(&reset)
+	if err == nil {
+		return &reset, nil
+	}
+
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// either the reset does not exist or it was used or invalidated already
+	found, err := s.GetPasswordReset(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return found, db.ErrAlreadyUsed
+}
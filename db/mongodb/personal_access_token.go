@@ -0,0 +1,97 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *Store) CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+
+	_, err := s.accessTokensCol.InsertOne(ctx, token)
+	if mongo.IsDuplicateKeyError(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+func (s *Store) GetPersonalAccessToken(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+
+	var token models.PersonalAccessToken
+
+	err := s.accessTokensCol.FindOne(ctx, bson.M{"hash": hash}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (s *Store) ListPersonalAccessTokens(ctx context.Context, userUUID string) ([]models.PersonalAccessToken, error) {
+
+	cursor, err := s.accessTokensCol.Find(ctx, bson.M{"user_uuid": userUUID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []models.PersonalAccessToken
+
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// RevokePersonalAccessToken keeps the revocation time of a token revoked already with $ifNull
+func (s *Store) RevokePersonalAccessToken(ctx context.Context, userUUID string, id string, revokedAt time.Time) error {
+
+	result, err := s.accessTokensCol.UpdateOne(ctx,
+		bson.M{"user_uuid": userUUID, "id": id},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"revoked_at": bson.M{"$ifNull": bson.A{"$revoked_at", revokedAt}}}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return db.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) RevokePersonalAccessTokens(ctx context.Context, userUUID string, revokedAt time.Time) error {
+
+	_, err := s.accessTokensCol.UpdateMany(ctx,
+		bson.M{"user_uuid": userUUID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt}},
+	)
+
+	return err
+}
+
+func (s *Store) SetPersonalAccessTokenLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+
+	_, err := s.accessTokensCol.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+
+	return err
+}
+
+func (s *Store) DeletePersonalAccessTokens(ctx context.Context, userUUID string) error {
+
+	_, err := s.accessTokensCol.DeleteMany(ctx, bson.M{"user_uuid": userUUID})
+
+	return err
+}
@@ -0,0 +1,55 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (s *Store) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+
+	_, err := s.refreshTokensCol.InsertOne(ctx, token)
+	if mongo.IsDuplicateKeyError(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+// UseRefreshToken flips the used flag with a single conditional update, so that only one caller sees it unused
+func (s *Store) UseRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+
+	var token models.RefreshToken
+
+	err := s.refreshTokensCol.FindOneAndUpdate(ctx,
+		bson.M{"hash": hash, "used": false},
+		bson.M{"$set": bson.M{"used": true}},
+	).Decode(&token)
+	if err == nil {
+		return &token, nil
+	}
+
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// either the token does not exist or it was used already
+	err = s.refreshTokensCol.FindOne(ctx, bson.M{"hash": hash}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, db.ErrAlreadyUsed
+}
+
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := s.refreshTokensCol.UpdateMany(ctx, bson.M{"family_id": familyID}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
@@ -0,0 +1,104 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateTwoFactor upserts over the pending enrollment only, an enabled one makes the upsert
+// collide with the unique user_uuid index
+func (s *Store) CreateTwoFactor(ctx context.Context, twoFactor *models.TwoFactor) error {
+
+	_, err := s.twoFactorsCol.ReplaceOne(ctx,
+		bson.M{"user_uuid": twoFactor.User_UUID, "enabled_at": nil},
+		twoFactor,
+		options.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+func (s *Store) GetTwoFactor(ctx context.Context, userUUID string) (*models.TwoFactor, error) {
+
+	var twoFactor models.TwoFactor
+
+	err := s.twoFactorsCol.FindOne(ctx, bson.M{"user_uuid": userUUID}).Decode(&twoFactor)
+	if err == mongo.ErrNoDocuments {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+func (s *Store) EnableTwoFactor(ctx context.Context, userUUID string, enabledAt time.Time, recoveryCodes []models.RecoveryCode) error {
+
+	result, err := s.twoFactorsCol.UpdateOne(ctx,
+		bson.M{"user_uuid": userUUID, "enabled_at": nil},
+		bson.M{"$set": bson.M{"enabled_at": enabledAt, "recovery_codes": recoveryCodes}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return db.ErrNotFound
+	}
+
+	return nil
+}
+
+// UseTwoFactorStep only moves the last step forward, so that two callers can not accept the same code
+func (s *Store) UseTwoFactorStep(ctx context.Context, userUUID string, step int64) error {
+
+	result, err := s.twoFactorsCol.UpdateOne(ctx,
+		bson.M{"user_uuid": userUUID, "last_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"last_step": step}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return db.ErrAlreadyUsed
+	}
+
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, userUUID string, hash string) error {
+
+	result, err := s.twoFactorsCol.UpdateOne(ctx,
+		bson.M{
+			"user_uuid":      userUUID,
+			"recovery_codes": bson.M{"$elemMatch": bson.M{"hash": hash, "used": false}},
+		},
+		bson.M{"$set": bson.M{"recovery_codes.$.used": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return db.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteTwoFactor(ctx context.Context, userUUID string) error {
+	_, err := s.twoFactorsCol.DeleteOne(ctx, bson.M{"user_uuid": userUUID})
+	return err
+}
@@ -0,0 +1,67 @@
+// Package postgres stores the users, tweets and follows in PostgreSQL
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db/sqlstore"
+	"github.com/lib/pq"
+)
+
+// Dialect numbers the placeholders $1, $2... and recognizes the unique_violation errors
+var Dialect = sqlstore.Dialect{
+	Name:   "postgres",
+	Rebind: rebind,
+	IsUniqueViolation: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23505"
+	},
+}
+
+// Open connects to the database and checks that it is reachable
+func Open(ctx context.Context, cfg config.Postgres) (*sql.DB, error) {
+
+	database, err := sql.Open("postgres", cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.PingContext(ctx); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("connecting to PostgreSQL: %w", err)
+	}
+
+	return database, nil
+}
+
+func New(database *sql.DB) *sqlstore.Store {
+	return sqlstore.New(database, Dialect)
+}
+
+func NewMigrator(database *sql.DB) *sqlstore.Migrator {
+	return sqlstore.NewMigrator(database, Dialect, migrations)
+}
+
+// rebind replaces the ? placeholders of query with $1, $2...
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
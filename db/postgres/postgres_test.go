@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/db/dbtest"
+	"github.com/Bruary/twitter-clone/db/sqlstore"
+)
+
+// newStore returns a store on the migrated database of TEST_POSTGRES_URL, the test is skipped when it is not set.
+// The tests create users of their own and leave them behind, so point it at a throwaway database.
+func newStore(t *testing.T) *sqlstore.Store {
+	t.Helper()
+
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	database, err := Open(context.Background(), config.Postgres{URL: url})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if _, err := NewMigrator(database).Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	return New(database)
+}
+
+func TestStore(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Store { return newStore(t) })
+}
@@ -0,0 +1,162 @@
+package sqlite
+
+import "github.com/Bruary/twitter-clone/db/sqlstore"
+
+// migrations are applied in order, never change or reorder the ones that were released, add new ones instead
+var migrations = []sqlstore.Migration{
+	{
+		Version:     1,
+		Description: "users table",
+		Statements: []string{
+			`CREATE TABLE users (
+				uuid                 TEXT PRIMARY KEY,
+				account_id           TEXT NOT NULL UNIQUE,
+				firstname            TEXT NOT NULL,
+				lastname             TEXT NOT NULL,
+				age                  INTEGER NOT NULL,
+				email                TEXT NOT NULL UNIQUE,
+				password             TEXT NOT NULL,
+				followers_count      INTEGER NOT NULL DEFAULT 0,
+				following_count      INTEGER NOT NULL DEFAULT 0,
+				total_tweets_count   INTEGER NOT NULL DEFAULT 0,
+				total_retweets_count INTEGER NOT NULL DEFAULT 0,
+				total_likes_count    INTEGER NOT NULL DEFAULT 0,
+				created_at           TIMESTAMP NOT NULL,
+				updated_at           TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "tweets table with feed and timeline indexes",
+		Statements: []string{
+			`CREATE TABLE tweets (
+				tweet_uuid       TEXT PRIMARY KEY,
+				user_uuid        TEXT NOT NULL,
+				account_id       TEXT NOT NULL,
+				email            TEXT NOT NULL,
+				tweet            TEXT NOT NULL,
+				retweets_count   INTEGER NOT NULL DEFAULT 0,
+				likes_count      INTEGER NOT NULL DEFAULT 0,
+				comments_count   INTEGER NOT NULL DEFAULT 0,
+				characters_count INTEGER NOT NULL DEFAULT 0,
+				created_at       TIMESTAMP NOT NULL,
+				updated_at       TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX tweets_account_id_created_at ON tweets (account_id, created_at DESC)`,
+			`CREATE INDEX tweets_user_uuid ON tweets (user_uuid)`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "followers table with a unique follower-following pair",
+		Statements: []string{
+			`CREATE TABLE followers (
+				id                   TEXT PRIMARY KEY,
+				follower_account_id  TEXT NOT NULL,
+				following_account_id TEXT NOT NULL,
+				UNIQUE (follower_account_id, following_account_id)
+			)`,
+			`CREATE INDEX followers_following_account_id ON followers (following_account_id)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "refresh_tokens table",
+		Statements: []string{
+			`CREATE TABLE refresh_tokens (
+				hash       TEXT PRIMARY KEY,
+				family_id  TEXT NOT NULL,
+				user_uuid  TEXT NOT NULL,
+				account_id TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				used       BOOLEAN NOT NULL DEFAULT FALSE,
+				revoked    BOOLEAN NOT NULL DEFAULT FALSE
+			)`,
+			`CREATE INDEX refresh_tokens_family_id ON refresh_tokens (family_id)`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "password_resets and audit_events tables",
+		Statements: []string{
+			`CREATE TABLE password_resets (
+				hash        TEXT PRIMARY KEY,
+				user_uuid   TEXT NOT NULL,
+				created_at  TIMESTAMP NOT NULL,
+				expires_at  TIMESTAMP NOT NULL,
+				used        BOOLEAN NOT NULL DEFAULT FALSE,
+				invalidated BOOLEAN NOT NULL DEFAULT FALSE
+			)`,
+			`CREATE INDEX password_resets_user_uuid ON password_resets (user_uuid)`,
+			`CREATE TABLE audit_events (
+				id         TEXT PRIMARY KEY,
+				user_uuid  TEXT NOT NULL,
+				type       TEXT NOT NULL,
+				ip         TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX audit_events_user_uuid_created_at ON audit_events (user_uuid, created_at)`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "verified_at column of users, the existing accounts count as verified",
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN verified_at TIMESTAMP`,
+			`UPDATE users SET verified_at = created_at`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "two_factors and recovery_codes tables",
+		Statements: []string{
+			`CREATE TABLE two_factors (
+				user_uuid  TEXT PRIMARY KEY,
+				secret     TEXT NOT NULL,
+				enabled_at TIMESTAMP,
+				last_step  BIGINT NOT NULL DEFAULT 0
+			)`,
+			`CREATE TABLE recovery_codes (
+				user_uuid TEXT NOT NULL,
+				hash      TEXT NOT NULL,
+				used      BOOLEAN NOT NULL DEFAULT FALSE,
+				PRIMARY KEY (user_uuid, hash)
+			)`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "identities table",
+		Statements: []string{
+			`CREATE TABLE identities (
+				provider   TEXT NOT NULL,
+				subject    TEXT NOT NULL,
+				user_uuid  TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (provider, subject)
+			)`,
+			`CREATE INDEX identities_user_uuid ON identities (user_uuid)`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "personal_access_tokens table",
+		Statements: []string{
+			`CREATE TABLE personal_access_tokens (
+				id           TEXT PRIMARY KEY,
+				hash         TEXT NOT NULL UNIQUE,
+				user_uuid    TEXT NOT NULL,
+				account_id   TEXT NOT NULL,
+				name         TEXT NOT NULL,
+				scopes       TEXT NOT NULL,
+				created_at   TIMESTAMP NOT NULL,
+				expires_at   TIMESTAMP,
+				last_used_at TIMESTAMP,
+				revoked_at   TIMESTAMP
+			)`,
+			`CREATE INDEX personal_access_tokens_user_uuid ON personal_access_tokens (user_uuid)`,
+		},
+	},
+}
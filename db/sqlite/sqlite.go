@@ -0,0 +1,52 @@
+// Package sqlite stores the users, tweets and follows in a SQLite file, it needs no server
+// and is meant for local development
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db/sqlstore"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect keeps the ? placeholders and recognizes the unique and primary key constraint errors
+var Dialect = sqlstore.Dialect{
+	Name:   "sqlite",
+	Rebind: func(query string) string { return query },
+	IsUniqueViolation: func(err error) bool {
+		var sqliteErr sqlite3.Error
+		return errors.As(err, &sqliteErr) &&
+			(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+	},
+}
+
+// Open opens the database file, creating it when it does not exist
+func Open(ctx context.Context, cfg config.SQLite) (*sql.DB, error) {
+
+	database, err := sql.Open("sqlite3", "file:"+cfg.Path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, one connection serializes the writes instead of failing them with SQLITE_BUSY
+	database.SetMaxOpenConns(1)
+
+	if err := database.PingContext(ctx); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("opening %s: %w", cfg.Path, err)
+	}
+
+	return database, nil
+}
+
+func New(database *sql.DB) *sqlstore.Store {
+	return sqlstore.New(database, Dialect)
+}
+
+func NewMigrator(database *sql.DB) *sqlstore.Migrator {
+	return sqlstore.NewMigrator(database, Dialect, migrations)
+}
@@ -0,0 +1,33 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/db/dbtest"
+	"github.com/Bruary/twitter-clone/db/sqlstore"
+)
+
+// newStore returns a store on a migrated database file of its own
+func newStore(t *testing.T) *sqlstore.Store {
+	t.Helper()
+
+	database, err := Open(context.Background(), config.SQLite{Path: filepath.Join(t.TempDir(), "twitter.db")})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if _, err := NewMigrator(database).Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	return New(database)
+}
+
+func TestStore(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Store { return newStore(t) })
+}
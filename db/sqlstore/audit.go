@@ -0,0 +1,15 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+
+	_, err := s.exec(ctx, s.db, `INSERT INTO audit_events (id, user_uuid, type, ip, created_at) VALUES (`+placeholders(5)+`)`,
+		event.ID, event.User_UUID, event.Type, event.IP, event.Created_At.UTC())
+
+	return err
+}
@@ -0,0 +1,43 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+
+	_, err := s.exec(ctx, s.db, `INSERT INTO identities (provider, subject, user_uuid, created_at) VALUES (?, ?, ?, ?)`,
+		identity.Provider, identity.Subject, identity.User_UUID, identity.Created_At.UTC())
+	if err != nil && s.dialect.IsUniqueViolation(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+func (s *Store) GetIdentity(ctx context.Context, provider string, subject string) (*models.Identity, error) {
+	identity := models.Identity{Provider: provider, Subject: subject}
+
+	err := s.queryRow(ctx, s.db, `SELECT user_uuid, created_at FROM identities WHERE provider = ? AND subject = ?`, provider, subject).
+		Scan(&identity.User_UUID, &identity.Created_At)
+	if err == sql.ErrNoRows {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	identity.Created_At = identity.Created_At.UTC()
+
+	return &identity, nil
+}
+
+func (s *Store) DeleteIdentities(ctx context.Context, userUUID string) error {
+	_, err := s.exec(ctx, s.db, `DELETE FROM identities WHERE user_uuid = ?`, userUUID)
+	return err
+}
@@ -0,0 +1,145 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+// batchSize is the number of rows read at once by ForEachUser and ForEachTweet,
+// no connection is held while fn runs so fn can use the store
+const batchSize = 500
+
+func (s *Store) ForEachUser(ctx context.Context, fn func(user *models.UserInfo) error) error {
+	lastUUID := ""
+
+	for {
+		rows, err := s.query(ctx, s.db, `SELECT `+userColumns+` FROM users WHERE uuid > ? ORDER BY uuid LIMIT ?`, lastUUID, batchSize)
+		if err != nil {
+			return err
+		}
+
+		var users []*models.UserInfo
+		for rows.Next() {
+			user, err := scanUser(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			users = append(users, user)
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+
+		if len(users) < batchSize {
+			return nil
+		}
+
+		lastUUID = users[len(users)-1].UUID
+	}
+}
+
+func (s *Store) ForEachTweet(ctx context.Context, fn func(tweet *models.TweetDB) error) error {
+	lastUUID := ""
+
+	for {
+		rows, err := s.query(ctx, s.db, `SELECT user_uuid, account_id, email, created_at, updated_at, `+tweetColumns+`
+			FROM tweets WHERE tweet_uuid > ? ORDER BY tweet_uuid LIMIT ?`, lastUUID, batchSize)
+		if err != nil {
+			return err
+		}
+
+		var tweets []*models.TweetDB
+		for rows.Next() {
+			var t models.TweetDB
+
+			err := rows.Scan(&t.User_UUID, &t.Account_ID, &t.Email, &t.Created_At, &t.Updated_At,
+				&t.Tweet_UUID, &t.Tweet, &t.Metrics.Retweets_count, &t.Metrics.Likes_count,
+				&t.Metrics.Comments_count, &t.Metrics.Characters_count)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			tweets = append(tweets, &t)
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, tweet := range tweets {
+			if err := fn(tweet); err != nil {
+				return err
+			}
+		}
+
+		if len(tweets) < batchSize {
+			return nil
+		}
+
+		lastUUID = tweets[len(tweets)-1].Tweet_UUID
+	}
+}
+
+func (s *Store) CountUserMetrics(ctx context.Context, user *models.UserInfo) (models.UserMetrics, error) {
+	var metrics models.UserMetrics
+
+	err := s.queryRow(ctx, s.db, `SELECT
+			(SELECT COUNT(*) FROM followers WHERE following_account_id = ?),
+			(SELECT COUNT(*) FROM followers WHERE follower_account_id = ?)`,
+		user.Account_ID, user.Account_ID).Scan(&metrics.Followers_count, &metrics.Following_count)
+	if err != nil {
+		return metrics, err
+	}
+
+	err = s.queryRow(ctx, s.db, `SELECT COUNT(*), COALESCE(SUM(retweets_count), 0), COALESCE(SUM(likes_count), 0)
+		FROM tweets WHERE user_uuid = ?`, user.UUID).
+		Scan(&metrics.Total_tweets_count, &metrics.Total_retweets_count, &metrics.Total_likes_count)
+
+	return metrics, err
+}
+
+func (s *Store) SetUserMetrics(ctx context.Context, userUUID string, old models.UserMetrics, new models.UserMetrics) (bool, error) {
+
+	result, err := s.exec(ctx, s.db, `UPDATE users
+		SET followers_count = ?, following_count = ?, total_tweets_count = ?, total_retweets_count = ?, total_likes_count = ?
+		WHERE uuid = ? AND followers_count = ? AND following_count = ? AND total_tweets_count = ?
+			AND total_retweets_count = ? AND total_likes_count = ?`,
+		new.Followers_count, new.Following_count, new.Total_tweets_count, new.Total_retweets_count, new.Total_likes_count,
+		userUUID, old.Followers_count, old.Following_count, old.Total_tweets_count, old.Total_retweets_count, old.Total_likes_count)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	return affected == 1, err
+}
+
+func (s *Store) SetTweetMetrics(ctx context.Context, tweetUUID string, old models.TweetMetrics, new models.TweetMetrics) (bool, error) {
+
+	result, err := s.exec(ctx, s.db, `UPDATE tweets
+		SET retweets_count = ?, likes_count = ?, comments_count = ?, characters_count = ?
+		WHERE tweet_uuid = ? AND retweets_count = ? AND likes_count = ? AND comments_count = ? AND characters_count = ?`,
+		new.Retweets_count, new.Likes_count, new.Comments_count, new.Characters_count,
+		tweetUUID, old.Retweets_count, old.Likes_count, old.Comments_count, old.Characters_count)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	return affected == 1, err
+}
@@ -0,0 +1,148 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+)
+
+// Migration is a schema or data change of the database, its statements run in a single transaction
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// Migrator runs the migrations of a SQL backend and records them in the schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+var _ db.Migrator = (*Migrator)(nil)
+
+// NewMigrator returns a migrator for migrations, never change or reorder the ones that were released, add new ones instead
+func NewMigrator(database *sql.DB, dialect Dialect, migrations []Migration) *Migrator {
+	return &Migrator{
+		db:         database,
+		dialect:    dialect,
+		migrations: migrations,
+	}
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version     INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at  TIMESTAMP NOT NULL
+)`
+
+func (m *Migrator) Migrate(ctx context.Context) ([]db.MigrationStatus, error) {
+
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var newlyApplied []db.MigrationStatus
+
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		status := db.MigrationStatus{
+			Version:     mig.Version,
+			Description: mig.Description,
+			AppliedAt:   time.Now().UTC(),
+		}
+
+		skipped, err := m.apply(ctx, mig, status.AppliedAt)
+		if err != nil {
+			return newlyApplied, &db.MigrationError{Version: mig.Version, Description: mig.Description, Err: err}
+		}
+
+		if !skipped {
+			newlyApplied = append(newlyApplied, status)
+		}
+	}
+
+	return newlyApplied, nil
+}
+
+// apply records the migration and runs its statements in one transaction. The record is inserted first
+// so that an instance migrating at the same time waits on it, and skips the migration once it was applied.
+func (m *Migrator) apply(ctx context.Context, mig Migration, appliedAt time.Time) (skipped bool, err error) {
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, m.dialect.Rebind(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`),
+		mig.Version, mig.Description, appliedAt)
+	if err != nil && m.dialect.IsUniqueViolation(err) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, statement := range mig.Statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return false, err
+		}
+	}
+
+	return false, tx.Commit()
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]db.MigrationStatus, error) {
+
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []db.MigrationStatus
+	for _, mig := range m.migrations {
+		statuses = append(statuses, db.MigrationStatus{
+			Version:     mig.Version,
+			Description: mig.Description,
+			AppliedAt:   applied[mig.Version],
+		})
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+
+	if _, err := m.db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt.UTC()
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,86 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+const passwordResetColumns = `hash, user_uuid, created_at, expires_at, used, invalidated`
+
+func (s *Store) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+
+	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		// only the newest reset of the user works
+		_, err := s.exec(ctx, tx, `UPDATE password_resets SET invalidated = ? WHERE user_uuid = ? AND used = ?`,
+			true, reset.User_UUID, false)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(ctx, tx, `INSERT INTO password_resets (`+passwordResetColumns+`) VALUES (`+placeholders(6)+`)`,
+			reset.Hash, reset.User_UUID, reset.Created_At.UTC(), reset.Expires_At.UTC(), reset.Used, reset.Invalidated)
+		return err
+	})
+	if err != nil && s.dialect.IsUniqueViolation(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+func (s *Store) GetPasswordReset(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	return scanPasswordReset(s.queryRow(ctx, s.db, `SELECT `+passwordResetColumns+` FROM password_resets WHERE hash = ?`, hash))
+}
+
+// UsePasswordReset flips the used flag with a conditional update, so that only one caller sees it usable
+func (s *Store) UsePasswordReset(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	var reset *models.PasswordReset
+	var used bool
+
+	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		var err error
+
+		reset, err = scanPasswordReset(s.queryRow(ctx, tx, `SELECT `+passwordResetColumns+` FROM password_resets WHERE hash = ?`, hash))
+		if err != nil {
+			return err
+		}
+
+		err = s.execOne(ctx, tx, `UPDATE password_resets SET used = ? WHERE hash = ? AND used = ? AND invalidated = ?`,
+			true, hash, false, false)
+		if err == db.ErrNotFound {
+			used = true
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if used {
+		return reset, db.ErrAlreadyUsed
+	}
+
+	return reset, nil
+}
+
+func scanPasswordReset(row interface{ Scan(...interface{}) error }) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+
+	err := row.Scan(&reset.Hash, &reset.User_UUID, &reset.Created_At, &reset.Expires_At, &reset.Used, &reset.Invalidated)
+	if err == sql.ErrNoRows {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &reset, nil
+}
@@ -0,0 +1,114 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+const accessTokenColumns = `id, hash, user_uuid, account_id, name, scopes, created_at, expires_at, last_used_at, revoked_at`
+
+// the scopes are saved space separated, no scope holds a space
+func (s *Store) CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+
+	_, err := s.exec(ctx, s.db, `INSERT INTO personal_access_tokens (`+accessTokenColumns+`) VALUES (`+placeholders(10)+`)`,
+		token.ID, token.Hash, token.User_UUID, token.Account_ID, token.Name, strings.Join(token.Scopes, " "),
+		token.Created_At.UTC(), nullTime(token.Expires_At), nullTime(token.Last_Used_At), nullTime(token.Revoked_At))
+	if err != nil && s.dialect.IsUniqueViolation(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+func (s *Store) GetPersonalAccessToken(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	return scanAccessToken(s.queryRow(ctx, s.db, `SELECT `+accessTokenColumns+` FROM personal_access_tokens WHERE hash = ?`, hash))
+}
+
+func (s *Store) ListPersonalAccessTokens(ctx context.Context, userUUID string) ([]models.PersonalAccessToken, error) {
+
+	rows, err := s.query(ctx, s.db, `SELECT `+accessTokenColumns+` FROM personal_access_tokens WHERE user_uuid = ? ORDER BY created_at DESC`, userUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.PersonalAccessToken
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (s *Store) RevokePersonalAccessToken(ctx context.Context, userUUID string, id string, revokedAt time.Time) error {
+	return s.execOne(ctx, s.db, `UPDATE personal_access_tokens SET revoked_at = COALESCE(revoked_at, ?) WHERE user_uuid = ? AND id = ?`,
+		revokedAt.UTC(), userUUID, id)
+}
+
+func (s *Store) RevokePersonalAccessTokens(ctx context.Context, userUUID string, revokedAt time.Time) error {
+	_, err := s.exec(ctx, s.db, `UPDATE personal_access_tokens SET revoked_at = ? WHERE user_uuid = ? AND revoked_at IS NULL`,
+		revokedAt.UTC(), userUUID)
+	return err
+}
+
+func (s *Store) SetPersonalAccessTokenLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := s.exec(ctx, s.db, `UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?`, usedAt.UTC(), id)
+	return err
+}
+
+func (s *Store) DeletePersonalAccessTokens(ctx context.Context, userUUID string) error {
+	_, err := s.exec(ctx, s.db, `DELETE FROM personal_access_tokens WHERE user_uuid = ?`, userUUID)
+	return err
+}
+
+func scanAccessToken(row interface{ Scan(...interface{}) error }) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&token.ID, &token.Hash, &token.User_UUID, &token.Account_ID, &token.Name, &scopes,
+		&token.Created_At, &expiresAt, &lastUsedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	token.Created_At = token.Created_At.UTC()
+	token.Expires_At = timePointer(expiresAt)
+	token.Last_Used_At = timePointer(lastUsedAt)
+	token.Revoked_At = timePointer(revokedAt)
+
+	return &token, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func timePointer(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	utc := t.Time.UTC()
+
+	return &utc
+}
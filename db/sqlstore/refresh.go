@@ -0,0 +1,77 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+const refreshTokenColumns = `hash, family_id, user_uuid, account_id, created_at, expires_at, used, revoked`
+
+func (s *Store) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+
+	_, err := s.exec(ctx, s.db, `INSERT INTO refresh_tokens (`+refreshTokenColumns+`) VALUES (`+placeholders(8)+`)`,
+		token.Hash, token.Family_ID, token.User_UUID, token.Account_ID,
+		token.Created_At.UTC(), token.Expires_At.UTC(), token.Used, token.Revoked)
+	if err != nil && s.dialect.IsUniqueViolation(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+// UseRefreshToken flips the used flag with a conditional update, so that only one caller sees it unused
+func (s *Store) UseRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token *models.RefreshToken
+	var used bool
+
+	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		var err error
+
+		token, err = scanRefreshToken(s.queryRow(ctx, tx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE hash = ?`, hash))
+		if err != nil {
+			return err
+		}
+
+		err = s.execOne(ctx, tx, `UPDATE refresh_tokens SET used = ? WHERE hash = ? AND used = ?`, true, hash, false)
+		if err == db.ErrNotFound {
+			used = true
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if used {
+		return token, db.ErrAlreadyUsed
+	}
+
+	return token, nil
+}
+
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := s.exec(ctx, s.db, `UPDATE refresh_tokens SET revoked = ? WHERE family_id = ?`, true, familyID)
+	return err
+}
+
+func scanRefreshToken(row interface{ Scan(...interface{}) error }) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+
+	err := row.Scan(&token.Hash, &token.Family_ID, &token.User_UUID, &token.Account_ID,
+		&token.Created_At, &token.Expires_At, &token.Used, &token.Revoked)
+	if err == sql.ErrNoRows {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
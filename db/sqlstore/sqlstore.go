@@ -0,0 +1,318 @@
+// Package sqlstore implements the stores on top of database/sql, it is shared by the SQL backends
+// which only differ by their Dialect and their migrations.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+// Dialect holds what differs between the SQL databases
+type Dialect struct {
+	Name string
+
+	// Rebind turns the ? placeholders of a query into the ones the driver expects
+	Rebind func(query string) string
+
+	// IsUniqueViolation reports whether err was caused by a unique constraint
+	IsUniqueViolation func(err error) bool
+}
+
+// Store implements db.Store on top of a SQL database
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+var _ db.Store = (*Store)(nil)
+
+func New(database *sql.DB, dialect Dialect) *Store {
+	return &Store{
+		db:      database,
+		dialect: dialect,
+	}
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// queryer is either the database or a transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) exec(ctx context.Context, q queryer, query string, args ...interface{}) (sql.Result, error) {
+	return q.ExecContext(ctx, s.dialect.Rebind(query), args...)
+}
+
+func (s *Store) query(ctx context.Context, q queryer, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.QueryContext(ctx, s.dialect.Rebind(query), args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, q queryer, query string, args ...interface{}) *sql.Row {
+	return q.QueryRowContext(ctx, s.dialect.Rebind(query), args...)
+}
+
+// execOne runs an update and returns db.ErrNotFound when it did not match any row
+func (s *Store) execOne(ctx context.Context, q queryer, query string, args ...interface{}) error {
+	result, err := s.exec(ctx, q, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return db.ErrNotFound
+	}
+
+	return nil
+}
+
+// inTransaction runs fn in a transaction that is committed when fn succeeds and rolled back otherwise
+func (s *Store) inTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// placeholders returns n comma separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+const userColumns = `uuid, account_id, firstname, lastname, age, email, password,
+	followers_count, following_count, total_tweets_count, total_retweets_count, total_likes_count,
+	created_at, updated_at, verified_at`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.UserInfo, error) {
+	var user models.UserInfo
+	var verifiedAt sql.NullTime
+
+	err := row.Scan(&user.UUID, &user.Account_ID, &user.FirstName, &user.LastName, &user.Age, &user.Email, &user.Password,
+		&user.Metrics.Followers_count, &user.Metrics.Following_count, &user.Metrics.Total_tweets_count,
+		&user.Metrics.Total_retweets_count, &user.Metrics.Total_likes_count,
+		&user.Created_At, &user.Updated_At, &verifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if verifiedAt.Valid {
+		user.Verified_At = &verifiedAt.Time
+	}
+
+	return &user, nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, user *models.UserInfo) error {
+
+	var verifiedAt sql.NullTime
+	if user.Verified_At != nil {
+		verifiedAt = sql.NullTime{Time: user.Verified_At.UTC(), Valid: true}
+	}
+
+	_, err := s.exec(ctx, s.db, `INSERT INTO users (`+userColumns+`) VALUES (`+placeholders(15)+`)`,
+		user.UUID, user.Account_ID, user.FirstName, user.LastName, user.Age, user.Email, user.Password,
+		user.Metrics.Followers_count, user.Metrics.Following_count, user.Metrics.Total_tweets_count,
+		user.Metrics.Total_retweets_count, user.Metrics.Total_likes_count,
+		user.Created_At.UTC(), user.Updated_At.UTC(), verifiedAt)
+	if err != nil && s.dialect.IsUniqueViolation(err) {
+		return db.ErrDuplicate
+	}
+
+	return err
+}
+
+func (s *Store) UserExists(ctx context.Context, email string) (bool, error) {
+	var count int
+
+	err := s.queryRow(ctx, s.db, `SELECT COUNT(*) FROM users WHERE email = ?`, email).Scan(&count)
+
+	return count >= 1, err
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*models.UserInfo, error) {
+	return scanUser(s.queryRow(ctx, s.db, `SELECT `+userColumns+` FROM users WHERE email = ?`, email))
+}
+
+func (s *Store) GetUserByUUID(ctx context.Context, userUUID string) (*models.UserInfo, error) {
+	return scanUser(s.queryRow(ctx, s.db, `SELECT `+userColumns+` FROM users WHERE uuid = ?`, userUUID))
+}
+
+func (s *Store) DeleteUser(ctx context.Context, userUUID string) error {
+	_, err := s.exec(ctx, s.db, `DELETE FROM users WHERE uuid = ?`, userUUID)
+	return err
+}
+
+func (s *Store) UpdatePassword(ctx context.Context, userUUID string, newPassword string) error {
+	return s.execOne(ctx, s.db, `UPDATE users SET password = ? WHERE uuid = ?`, newPassword, userUUID)
+}
+
+func (s *Store) RehashPassword(ctx context.Context, userUUID string, oldHash string, newHash string) error {
+	return s.execOne(ctx, s.db, `UPDATE users SET password = ? WHERE uuid = ? AND password = ?`, newHash, userUUID, oldHash)
+}
+
+func (s *Store) SetEmailVerified(ctx context.Context, userUUID string, verifiedAt time.Time) error {
+	return s.execOne(ctx, s.db, `UPDATE users SET verified_at = ? WHERE uuid = ?`, verifiedAt.UTC(), userUUID)
+}
+
+func (s *Store) IncrementTweetsCount(ctx context.Context, userUUID string) error {
+	return s.execOne(ctx, s.db, `UPDATE users SET total_tweets_count = total_tweets_count + 1 WHERE uuid = ?`, userUUID)
+}
+
+const tweetColumns = `tweet_uuid, tweet, retweets_count, likes_count, comments_count, characters_count`
+
+func (s *Store) CreateTweet(ctx context.Context, tweet *models.TweetDB) error {
+
+	_, err := s.exec(ctx, s.db, `INSERT INTO tweets (user_uuid, account_id, email, created_at, updated_at, `+tweetColumns+`)
+		VALUES (`+placeholders(11)+`)`,
+		tweet.User_UUID, tweet.Account_ID, tweet.Email, tweet.Created_At.UTC(), tweet.Updated_At.UTC(),
+		tweet.Tweet_UUID, tweet.Tweet, tweet.Metrics.Retweets_count, tweet.Metrics.Likes_count,
+		tweet.Metrics.Comments_count, tweet.Metrics.Characters_count)
+
+	return err
+}
+
+func (s *Store) GetTweetsByUserUUID(ctx context.Context, userUUID string) ([]models.Tweet, error) {
+	return s.findTweets(ctx, `SELECT `+tweetColumns+` FROM tweets WHERE user_uuid = ? ORDER BY created_at`, userUUID)
+}
+
+func (s *Store) GetTweetsByAccountID(ctx context.Context, accountID string) ([]models.Tweet, error) {
+	return s.findTweets(ctx, `SELECT `+tweetColumns+` FROM tweets WHERE account_id = ? ORDER BY created_at`, accountID)
+}
+
+func (s *Store) GetTweetsForAccounts(ctx context.Context, accountIDs []string, limit int) ([]models.Tweet, error) {
+
+	if len(accountIDs) == 0 {
+		return []models.Tweet{}, nil
+	}
+
+	args := make([]interface{}, 0, len(accountIDs)+1)
+	for _, accountID := range accountIDs {
+		args = append(args, accountID)
+	}
+	args = append(args, limit)
+
+	// newest on top
+	return s.findTweets(ctx, `SELECT `+tweetColumns+` FROM tweets WHERE account_id IN (`+placeholders(len(accountIDs))+`)
+		ORDER BY created_at DESC LIMIT ?`, args...)
+}
+
+func (s *Store) findTweets(ctx context.Context, query string, args ...interface{}) ([]models.Tweet, error) {
+
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tweets := []models.Tweet{}
+	for rows.Next() {
+		var t models.Tweet
+
+		err := rows.Scan(&t.Tweet_UUID, &t.Tweet, &t.Metrics.Retweets_count, &t.Metrics.Likes_count,
+			&t.Metrics.Comments_count, &t.Metrics.Characters_count)
+		if err != nil {
+			return nil, err
+		}
+
+		tweets = append(tweets, t)
+	}
+
+	return tweets, rows.Err()
+}
+
+// Follow runs in a transaction, the unique constraint on the follower-following pair
+// makes a concurrent duplicate wait for the first one and then do nothing
+func (s *Store) Follow(ctx context.Context, follow *models.Followers) (bool, error) {
+	created := false
+
+	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		result, err := s.exec(ctx, tx, `INSERT INTO followers (id, follower_account_id, following_account_id)
+			VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+			follow.ID, follow.Follower_Account_ID, follow.Following_Account_ID)
+		if err != nil {
+			return err
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil || inserted == 0 {
+			return err
+		}
+
+		err = s.execOne(ctx, tx, `UPDATE users SET following_count = following_count + 1 WHERE account_id = ?`,
+			follow.Follower_Account_ID)
+		if err != nil {
+			return err
+		}
+
+		err = s.execOne(ctx, tx, `UPDATE users SET followers_count = followers_count + 1 WHERE account_id = ?`,
+			follow.Following_Account_ID)
+		if err != nil {
+			return err
+		}
+
+		created = true
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return created, nil
+}
+
+func (s *Store) FollowExists(ctx context.Context, followerAccountID string, followingAccountID string) (bool, error) {
+	var count int
+
+	err := s.queryRow(ctx, s.db, `SELECT COUNT(*) FROM followers WHERE follower_account_id = ? AND following_account_id = ?`,
+		followerAccountID, followingAccountID).Scan(&count)
+
+	return count >= 1, err
+}
+
+func (s *Store) GetFollowingAccountIDs(ctx context.Context, accountID string) ([]string, error) {
+
+	rows, err := s.query(ctx, s.db, `SELECT following_account_id FROM followers WHERE follower_account_id = ?`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var followingAccountIDs []string
+	for rows.Next() {
+		var followingAccountID string
+		if err := rows.Scan(&followingAccountID); err != nil {
+			return nil, err
+		}
+
+		followingAccountIDs = append(followingAccountIDs, followingAccountID)
+	}
+
+	return followingAccountIDs, rows.Err()
+}
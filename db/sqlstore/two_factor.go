@@ -0,0 +1,137 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func (s *Store) CreateTwoFactor(ctx context.Context, twoFactor *models.TwoFactor) error {
+	return s.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		var enabledAt sql.NullTime
+
+		err := s.queryRow(ctx, tx, `SELECT enabled_at FROM two_factors WHERE user_uuid = ?`, twoFactor.User_UUID).Scan(&enabledAt)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if enabledAt.Valid {
+			return db.ErrDuplicate
+		}
+
+		// replace the pending enrollment
+		_, err = s.exec(ctx, tx, `DELETE FROM two_factors WHERE user_uuid = ?`, twoFactor.User_UUID)
+		if err != nil {
+			return err
+		}
+
+		enabledAt = sql.NullTime{}
+		if twoFactor.Enabled_At != nil {
+			enabledAt = sql.NullTime{Time: twoFactor.Enabled_At.UTC(), Valid: true}
+		}
+
+		_, err = s.exec(ctx, tx, `INSERT INTO two_factors (user_uuid, secret, enabled_at, last_step) VALUES (?, ?, ?, ?)`,
+			twoFactor.User_UUID, twoFactor.Secret, enabledAt, twoFactor.Last_Step)
+		return err
+	})
+}
+
+func (s *Store) GetTwoFactor(ctx context.Context, userUUID string) (*models.TwoFactor, error) {
+	twoFactor := models.TwoFactor{User_UUID: userUUID}
+	var enabledAt sql.NullTime
+
+	err := s.queryRow(ctx, s.db, `SELECT secret, enabled_at, last_step FROM two_factors WHERE user_uuid = ?`, userUUID).
+		Scan(&twoFactor.Secret, &enabledAt, &twoFactor.Last_Step)
+	if err == sql.ErrNoRows {
+		return nil, db.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledAt.Valid {
+		twoFactor.Enabled_At = &enabledAt.Time
+	}
+
+	rows, err := s.query(ctx, s.db, `SELECT hash, used FROM recovery_codes WHERE user_uuid = ?`, userUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code models.RecoveryCode
+		if err := rows.Scan(&code.Hash, &code.Used); err != nil {
+			return nil, err
+		}
+
+		twoFactor.Recovery_Codes = append(twoFactor.Recovery_Codes, code)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+func (s *Store) EnableTwoFactor(ctx context.Context, userUUID string, enabledAt time.Time, recoveryCodes []models.RecoveryCode) error {
+	return s.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		err := s.execOne(ctx, tx, `UPDATE two_factors SET enabled_at = ? WHERE user_uuid = ? AND enabled_at IS NULL`,
+			enabledAt.UTC(), userUUID)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(ctx, tx, `DELETE FROM recovery_codes WHERE user_uuid = ?`, userUUID)
+		if err != nil {
+			return err
+		}
+
+		for _, code := range recoveryCodes {
+			_, err = s.exec(ctx, tx, `INSERT INTO recovery_codes (user_uuid, hash, used) VALUES (?, ?, ?)`,
+				userUUID, code.Hash, code.Used)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// UseTwoFactorStep only moves the last step forward, so that two callers can not accept the same code
+func (s *Store) UseTwoFactorStep(ctx context.Context, userUUID string, step int64) error {
+
+	err := s.execOne(ctx, s.db, `UPDATE two_factors SET last_step = ? WHERE user_uuid = ? AND last_step < ?`,
+		step, userUUID, step)
+	if err == db.ErrNotFound {
+		return db.ErrAlreadyUsed
+	}
+
+	return err
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, userUUID string, hash string) error {
+	return s.execOne(ctx, s.db, `UPDATE recovery_codes SET used = ? WHERE user_uuid = ? AND hash = ? AND used = ?`,
+		true, userUUID, hash, false)
+}
+
+func (s *Store) DeleteTwoFactor(ctx context.Context, userUUID string) error {
+	return s.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		_, err := s.exec(ctx, tx, `DELETE FROM recovery_codes WHERE user_uuid = ?`, userUUID)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(ctx, tx, `DELETE FROM two_factors WHERE user_uuid = ?`, userUUID)
+		return err
+	})
+}
@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+// ErrNotFound is returned by the stores when the requested document does not exist
+var ErrNotFound = errors.New("db: document not found")
+
+// ErrDuplicate is returned by the stores when a document breaks a unique constraint,
+// e.g. an email that is already used
+var ErrDuplicate = errors.New("db: duplicate document")
+
+// ErrAlreadyUsed is returned by the stores when a single-use token is used a second time
+var ErrAlreadyUsed = errors.New("db: already used")
+
+// Store is implemented by every storage backend
+type Store interface {
+	UserStore
+	TweetStore
+	FollowStore
+	MetricsStore
+	RefreshTokenStore
+	PasswordResetStore
+	AuditStore
+	TwoFactorStore
+	IdentityStore
+	PersonalAccessTokenStore
+
+	// Ping checks that the database is reachable
+	Ping(ctx context.Context) error
+}
+
+// UserStore holds the users and their metrics
+type UserStore interface {
+
+	// CreateUser returns ErrDuplicate when the email, the UUID or the account ID is already used
+	CreateUser(ctx context.Context, user *models.UserInfo) error
+	UserExists(ctx context.Context, email string) (bool, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.UserInfo, error)
+	GetUserByUUID(ctx context.Context, userUUID string) (*models.UserInfo, error)
+	DeleteUser(ctx context.Context, userUUID string) error
+	UpdatePassword(ctx context.Context, userUUID string, newPassword string) error
+
+	// RehashPassword replaces the password hash of the user with newHash as long as it is still oldHash,
+	// it returns ErrNotFound when the password changed in the meantime
+	RehashPassword(ctx context.Context, userUUID string, oldHash string, newHash string) error
+
+	// SetEmailVerified records when the email of the user was verified, it returns ErrNotFound for an unknown user
+	SetEmailVerified(ctx context.Context, userUUID string, verifiedAt time.Time) error
+	IncrementTweetsCount(ctx context.Context, userUUID string) error
+}
+
+// MetricsStore gives the metrics reconciliation access to every user and tweet, and to the documents their counters count
+type MetricsStore interface {
+
+	// ForEachUser calls fn for every user, it stops at the first error returned by fn
+	ForEachUser(ctx context.Context, fn func(user *models.UserInfo) error) error
+
+	// CountUserMetrics computes the metrics of a user from the follows and the tweets
+	CountUserMetrics(ctx context.Context, user *models.UserInfo) (models.UserMetrics, error)
+
+	// SetUserMetrics replaces the metrics of a user only if they still are the old ones,
+	// updated is false when the metrics changed in the meantime
+	SetUserMetrics(ctx context.Context, userUUID string, old models.UserMetrics, new models.UserMetrics) (updated bool, err error)
+
+	// ForEachTweet calls fn for every tweet, it stops at the first error returned by fn
+	ForEachTweet(ctx context.Context, fn func(tweet *models.TweetDB) error) error
+
+	// SetTweetMetrics replaces the metrics of a tweet only if they still are the old ones,
+	// updated is false when the metrics changed in the meantime
+	SetTweetMetrics(ctx context.Context, tweetUUID string, old models.TweetMetrics, new models.TweetMetrics) (updated bool, err error)
+}
+
+// TweetStore holds the tweets
+type TweetStore interface {
+	CreateTweet(ctx context.Context, tweet *models.TweetDB) error
+	GetTweetsByUserUUID(ctx context.Context, userUUID string) ([]models.Tweet, error)
+	GetTweetsByAccountID(ctx context.Context, accountID string) ([]models.Tweet, error)
+
+	// GetTweetsForAccounts returns the newest tweets (newest on top) posted by any of the given accounts
+	GetTweetsForAccounts(ctx context.Context, accountIDs []string, limit int) ([]models.Tweet, error)
+}
+
+// RefreshTokenStore holds the refresh tokens, keyed by the hash of the token
+type RefreshTokenStore interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+
+	// UseRefreshToken marks the token as used and returns it. It returns ErrNotFound for an unknown token,
+	// and the token along with ErrAlreadyUsed when it was used before. Of two concurrent calls with
+	// the same token only one succeeds.
+	UseRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error)
+
+	// RevokeRefreshTokenFamily revokes every token of the family
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+}
+
+// PasswordResetStore holds the password reset tokens, keyed by the hash of the token
+type PasswordResetStore interface {
+
+	// CreatePasswordReset saves the reset and invalidates the earlier resets of the user that were not used
+	CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error
+
+	// GetPasswordReset returns ErrNotFound for an unknown token
+	GetPasswordReset(ctx context.Context, hash string) (*models.PasswordReset, error)
+
+	// UsePasswordReset marks the reset as used and returns it. It returns ErrNotFound for an unknown token,
+	// and the reset along with ErrAlreadyUsed when it was used or invalidated before. Of two concurrent
+	// calls with the same token only one succeeds.
+	UsePasswordReset(ctx context.Context, hash string) (*models.PasswordReset, error)
+}
+
+// TwoFactorStore holds the TOTP secrets and the recovery codes of the users
+type TwoFactorStore interface {
+
+	// CreateTwoFactor saves a pending two-factor authentication, replacing the pending one of the user.
+	// It returns ErrDuplicate when the user has it enabled already.
+	CreateTwoFactor(ctx context.Context, twoFactor *models.TwoFactor) error
+
+	// GetTwoFactor returns ErrNotFound when the user has none, pending or enabled
+	GetTwoFactor(ctx context.Context, userUUID string) (*models.TwoFactor, error)
+
+	// EnableTwoFactor enables the pending two-factor authentication of the user with the given recovery codes,
+	// it returns ErrNotFound when none is pending
+	EnableTwoFactor(ctx context.Context, userUUID string, enabledAt time.Time, recoveryCodes []models.RecoveryCode) error
+
+	// UseTwoFactorStep records the step of an accepted code. It returns ErrAlreadyUsed when a code
+	// of that step or a later one was accepted already, so that a code can not be replayed.
+	UseTwoFactorStep(ctx context.Context, userUUID string, step int64) error
+
+	// UseRecoveryCode marks the recovery code as used, it returns ErrNotFound when the user has no
+	// such code that is still unused
+	UseRecoveryCode(ctx context.Context, userUUID string, hash string) error
+
+	// DeleteTwoFactor removes the two-factor authentication of the user along with the recovery codes
+	DeleteTwoFactor(ctx context.Context, userUUID string) error
+}
+
+// IdentityStore holds the accounts at the OIDC providers the users sign in with
+type IdentityStore interface {
+
+	// CreateIdentity returns ErrDuplicate when the account at the provider is linked already
+	CreateIdentity(ctx context.Context, identity *models.Identity) error
+
+	// GetIdentity returns ErrNotFound when the account at the provider is not linked to any user
+	GetIdentity(ctx context.Context, provider string, subject string) (*models.Identity, error)
+
+	// DeleteIdentities unlinks every account of the user at the providers
+	DeleteIdentities(ctx context.Context, userUUID string) error
+}
+
+// PersonalAccessTokenStore holds the personal access tokens, keyed by the hash of the token
+type PersonalAccessTokenStore interface {
+
+	// CreatePersonalAccessToken returns ErrDuplicate when the ID or the hash is already used
+	CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error
+
+	// GetPersonalAccessToken returns ErrNotFound for an unknown token
+	GetPersonalAccessToken(ctx context.Context, hash string) (*models.PersonalAccessToken, error)
+
+	// ListPersonalAccessTokens returns every token of the user, the revoked ones included, the newest first
+	ListPersonalAccessTokens(ctx context.Context, userUUID string) ([]models.PersonalAccessToken, error)
+
+	// RevokePersonalAccessToken revokes the token of the user, a token revoked already keeps its revocation time.
+	// It returns ErrNotFound when the user has no such token.
+	RevokePersonalAccessToken(ctx context.Context, userUUID string, id string, revokedAt time.Time) error
+
+	// RevokePersonalAccessTokens revokes every token of the user
+	RevokePersonalAccessTokens(ctx context.Context, userUUID string, revokedAt time.Time) error
+
+	// SetPersonalAccessTokenLastUsed records when the token was last used
+	SetPersonalAccessTokenLastUsed(ctx context.Context, id string, usedAt time.Time) error
+
+	// DeletePersonalAccessTokens removes every token of the user
+	DeletePersonalAccessTokens(ctx context.Context, userUUID string) error
+}
+
+// AuditStore keeps a record of the security events of the accounts
+type AuditStore interface {
+	CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error
+}
+
+// FollowStore holds the follower-following relationships
+type FollowStore interface {
+
+	// Follow saves the follow and increments the following count of the follower and the followers count
+	// of the followed account as one unit, either all of it is saved or none of it is.
+	// It is idempotent: when the follow already exists nothing changes and created is false.
+	// It returns ErrNotFound when either account does not exist.
+	Follow(ctx context.Context, follow *models.Followers) (created bool, err error)
+	FollowExists(ctx context.Context, followerAccountID string, followingAccountID string) (bool, error)
+	GetFollowingAccountIDs(ctx context.Context, accountID string) ([]string, error)
+}
@@ -0,0 +1,360 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+// TimeoutError is returned when a store call did not finish before its deadline
+type TimeoutError struct {
+	Op      string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("db: %s timed out after %s", e.Op, e.Timeout)
+}
+
+// Is makes errors.Is(err, context.DeadlineExceeded) hold for timeouts
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// IsTimeout reports whether err is, or wraps, a TimeoutError
+func IsTimeout(err error) bool {
+	var timeoutErr *TimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+// Timeouts bounds how long a single store call can take, on top of the deadline of the caller's context
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+// TimeoutStore wraps a store so that every call the service makes gets its own deadline,
+// a call that runs out of time returns a *TimeoutError
+type TimeoutStore struct {
+	store    Store
+	timeouts Timeouts
+}
+
+var _ UserStore = (*TimeoutStore)(nil)
+var _ TweetStore = (*TimeoutStore)(nil)
+var _ FollowStore = (*TimeoutStore)(nil)
+var _ RefreshTokenStore = (*TimeoutStore)(nil)
+var _ PasswordResetStore = (*TimeoutStore)(nil)
+var _ AuditStore = (*TimeoutStore)(nil)
+var _ TwoFactorStore = (*TimeoutStore)(nil)
+var _ IdentityStore = (*TimeoutStore)(nil)
+var _ PersonalAccessTokenStore = (*TimeoutStore)(nil)
+
+func NewTimeoutStore(store Store, timeouts Timeouts) *TimeoutStore {
+	return &TimeoutStore{
+		store:    store,
+		timeouts: timeouts,
+	}
+}
+
+// call runs fn with a context bounded by timeout and turns a deadline hit into a *TimeoutError
+func call(ctx context.Context, op string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{Op: op, Timeout: timeout}
+	}
+
+	return err
+}
+
+func (s *TimeoutStore) CreateUser(ctx context.Context, user *models.UserInfo) error {
+	return call(ctx, "CreateUser", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.CreateUser(ctx, user)
+	})
+}
+
+func (s *TimeoutStore) UserExists(ctx context.Context, email string) (exists bool, err error) {
+	err = call(ctx, "UserExists", s.timeouts.Read, func(ctx context.Context) error {
+		exists, err = s.store.UserExists(ctx, email)
+		return err
+	})
+
+	return exists, err
+}
+
+func (s *TimeoutStore) GetUserByEmail(ctx context.Context, email string) (user *models.UserInfo, err error) {
+	err = call(ctx, "GetUserByEmail", s.timeouts.Read, func(ctx context.Context) error {
+		user, err = s.store.GetUserByEmail(ctx, email)
+		return err
+	})
+
+	return user, err
+}
+
+func (s *TimeoutStore) GetUserByUUID(ctx context.Context, userUUID string) (user *models.UserInfo, err error) {
+	err = call(ctx, "GetUserByUUID", s.timeouts.Read, func(ctx context.Context) error {
+		user, err = s.store.GetUserByUUID(ctx, userUUID)
+		return err
+	})
+
+	return user, err
+}
+
+func (s *TimeoutStore) DeleteUser(ctx context.Context, userUUID string) error {
+	return call(ctx, "DeleteUser", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.DeleteUser(ctx, userUUID)
+	})
+}
+
+func (s *TimeoutStore) UpdatePassword(ctx context.Context, userUUID string, newPassword string) error {
+	return call(ctx, "UpdatePassword", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.UpdatePassword(ctx, userUUID, newPassword)
+	})
+}
+
+func (s *TimeoutStore) RehashPassword(ctx context.Context, userUUID string, oldHash string, newHash string) error {
+	return call(ctx, "RehashPassword", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.RehashPassword(ctx, userUUID, oldHash, newHash)
+	})
+}
+
+func (s *TimeoutStore) SetEmailVerified(ctx context.Context, userUUID string, verifiedAt time.Time) error {
+	return call(ctx, "SetEmailVerified", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.SetEmailVerified(ctx, userUUID, verifiedAt)
+	})
+}
+
+func (s *TimeoutStore) IncrementTweetsCount(ctx context.Context, userUUID string) error {
+	return call(ctx, "IncrementTweetsCount", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.IncrementTweetsCount(ctx, userUUID)
+	})
+}
+
+func (s *TimeoutStore) CreateTweet(ctx context.Context, tweet *models.TweetDB) error {
+	return call(ctx, "CreateTweet", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.CreateTweet(ctx, tweet)
+	})
+}
+
+func (s *TimeoutStore) GetTweetsByUserUUID(ctx context.Context, userUUID string) (tweets []models.Tweet, err error) {
+	err = call(ctx, "GetTweetsByUserUUID", s.timeouts.Read, func(ctx context.Context) error {
+		tweets, err = s.store.GetTweetsByUserUUID(ctx, userUUID)
+		return err
+	})
+
+	return tweets, err
+}
+
+func (s *TimeoutStore) GetTweetsByAccountID(ctx context.Context, accountID string) (tweets []models.Tweet, err error) {
+	err = call(ctx, "GetTweetsByAccountID", s.timeouts.Read, func(ctx context.Context) error {
+		tweets, err = s.store.GetTweetsByAccountID(ctx, accountID)
+		return err
+	})
+
+	return tweets, err
+}
+
+func (s *TimeoutStore) GetTweetsForAccounts(ctx context.Context, accountIDs []string, limit int) (tweets []models.Tweet, err error) {
+	err = call(ctx, "GetTweetsForAccounts", s.timeouts.Read, func(ctx context.Context) error {
+		tweets, err = s.store.GetTweetsForAccounts(ctx, accountIDs, limit)
+		return err
+	})
+
+	return tweets, err
+}
+
+func (s *TimeoutStore) Follow(ctx context.Context, follow *models.Followers) (created bool, err error) {
+	err = call(ctx, "Follow", s.timeouts.Write, func(ctx context.Context) error {
+		created, err = s.store.Follow(ctx, follow)
+		return err
+	})
+
+	return created, err
+}
+
+func (s *TimeoutStore) FollowExists(ctx context.Context, followerAccountID string, followingAccountID string) (exists bool, err error) {
+	err = call(ctx, "FollowExists", s.timeouts.Read, func(ctx context.Context) error {
+		exists, err = s.store.FollowExists(ctx, followerAccountID, followingAccountID)
+		return err
+	})
+
+	return exists, err
+}
+
+func (s *TimeoutStore) GetFollowingAccountIDs(ctx context.Context, accountID string) (accountIDs []string, err error) {
+	err = call(ctx, "GetFollowingAccountIDs", s.timeouts.Read, func(ctx context.Context) error {
+		accountIDs, err = s.store.GetFollowingAccountIDs(ctx, accountID)
+		return err
+	})
+
+	return accountIDs, err
+}
+
+func (s *TimeoutStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return call(ctx, "CreateRefreshToken", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.CreateRefreshToken(ctx, token)
+	})
+}
+
+func (s *TimeoutStore) UseRefreshToken(ctx context.Context, hash string) (token *models.RefreshToken, err error) {
+	err = call(ctx, "UseRefreshToken", s.timeouts.Write, func(ctx context.Context) error {
+		token, err = s.store.UseRefreshToken(ctx, hash)
+		return err
+	})
+
+	return token, err
+}
+
+func (s *TimeoutStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return call(ctx, "RevokeRefreshTokenFamily", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.RevokeRefreshTokenFamily(ctx, familyID)
+	})
+}
+
+func (s *TimeoutStore) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	return call(ctx, "CreatePasswordReset", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.CreatePasswordReset(ctx, reset)
+	})
+}
+
+func (s *TimeoutStore) GetPasswordReset(ctx context.Context, hash string) (reset *models.PasswordReset, err error) {
+	err = call(ctx, "GetPasswordReset", s.timeouts.Read, func(ctx context.Context) error {
+		reset, err = s.store.GetPasswordReset(ctx, hash)
+		return err
+	})
+
+	return reset, err
+}
+
+func (s *TimeoutStore) UsePasswordReset(ctx context.Context, hash string) (reset *models.PasswordReset, err error) {
+	err = call(ctx, "UsePasswordReset", s.timeouts.Write, func(ctx context.Context) error {
+		reset, err = s.store.UsePasswordReset(ctx, hash)
+		return err
+	})
+
+	return reset, err
+}
+
+func (s *TimeoutStore) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return call(ctx, "CreateAuditEvent", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.CreateAuditEvent(ctx, event)
+	})
+}
+
+func (s *TimeoutStore) CreateTwoFactor(ctx context.Context, twoFactor *models.TwoFactor) error {
+	return call(ctx, "CreateTwoFactor", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.CreateTwoFactor(ctx, twoFactor)
+	})
+}
+
+func (s *TimeoutStore) GetTwoFactor(ctx context.Context, userUUID string) (twoFactor *models.TwoFactor, err error) {
+	err = call(ctx, "GetTwoFactor", s.timeouts.Read, func(ctx context.Context) error {
+		twoFactor, err = s.store.GetTwoFactor(ctx, userUUID)
+		return err
+	})
+
+	return twoFactor, err
+}
+
+func (s *TimeoutStore) EnableTwoFactor(ctx context.Context, userUUID string, enabledAt time.Time, recoveryCodes []models.RecoveryCode) error {
+	return call(ctx, "EnableTwoFactor", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.EnableTwoFactor(ctx, userUUID, enabledAt, recoveryCodes)
+	})
+}
+
+func (s *TimeoutStore) UseTwoFactorStep(ctx context.Context, userUUID string, step int64) error {
+	return call(ctx, "UseTwoFactorStep", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.UseTwoFactorStep(ctx, userUUID, step)
+	})
+}
+
+func (s *TimeoutStore) UseRecoveryCode(ctx context.Context, userUUID string, hash string) error {
+	return call(ctx, "UseRecoveryCode", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.UseRecoveryCode(ctx, userUUID, hash)
+	})
+}
+
+func (s *TimeoutStore) DeleteTwoFactor(ctx context.Context, userUUID string) error {
+	return call(ctx, "DeleteTwoFactor", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.DeleteTwoFactor(ctx, userUUID)
+	})
+}
+
+func (s *TimeoutStore) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	return call(ctx, "CreateIdentity", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.CreateIdentity(ctx, identity)
+	})
+}
+
+func (s *TimeoutStore) GetIdentity(ctx context.Context, provider string, subject string) (identity *models.Identity, err error) {
+	err = call(ctx, "GetIdentity", s.timeouts.Read, func(ctx context.Context) error {
+		identity, err = s.store.GetIdentity(ctx, provider, subject)
+		return err
+	})
+
+	return identity, err
+}
+
+func (s *TimeoutStore) DeleteIdentities(ctx context.Context, userUUID string) error {
+	return call(ctx, "DeleteIdentities", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.DeleteIdentities(ctx, userUUID)
+	})
+}
+
+func (s *TimeoutStore) CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	return call(ctx, "CreatePersonalAccessToken", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.CreatePersonalAccessToken(ctx, token)
+	})
+}
+
+func (s *TimeoutStore) GetPersonalAccessToken(ctx context.Context, hash string) (token *models.PersonalAccessToken, err error) {
+	err = call(ctx, "GetPersonalAccessToken", s.timeouts.Read, func(ctx context.Context) error {
+		token, err = s.store.GetPersonalAccessToken(ctx, hash)
+		return err
+	})
+
+	return token, err
+}
+
+func (s *TimeoutStore) ListPersonalAccessTokens(ctx context.Context, userUUID string) (tokens []models.PersonalAccessToken, err error) {
+	err = call(ctx, "ListPersonalAccessTokens", s.timeouts.Read, func(ctx context.Context) error {
+		tokens, err = s.store.ListPersonalAccessTokens(ctx, userUUID)
+		return err
+	})
+
+	return tokens, err
+}
+
+func (s *TimeoutStore) RevokePersonalAccessToken(ctx context.Context, userUUID string, id string, revokedAt time.Time) error {
+	return call(ctx, "RevokePersonalAccessToken", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.RevokePersonalAccessToken(ctx, userUUID, id, revokedAt)
+	})
+}
+
+func (s *TimeoutStore) RevokePersonalAccessTokens(ctx context.Context, userUUID string, revokedAt time.Time) error {
+	return call(ctx, "RevokePersonalAccessTokens", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.RevokePersonalAccessTokens(ctx, userUUID, revokedAt)
+	})
+}
+
+func (s *TimeoutStore) SetPersonalAccessTokenLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	return call(ctx, "SetPersonalAccessTokenLastUsed", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.SetPersonalAccessTokenLastUsed(ctx, id, usedAt)
+	})
+}
+
+func (s *TimeoutStore) DeletePersonalAccessTokens(ctx context.Context, userUUID string) error {
+	return call(ctx, "DeletePersonalAccessTokens", s.timeouts.Write, func(ctx context.Context) error {
+		return s.store.DeletePersonalAccessTokens(ctx, userUUID)
+	})
+}
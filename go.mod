@@ -10,6 +10,8 @@ require (
 	github.com/gofiber/fiber/v2 v2.17.0
 	github.com/joho/godotenv v1.4.0
 	github.com/jordan-wright/email v4.0.1-0.20210109023952-943e75fe5223+incompatible
+	github.com/lib/pq v1.10.7
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/satori/go.uuid v1.2.0
 	go.mongodb.org/mongo-driver v1.7.1
 	go.opentelemetry.io/otel v0.2.4-0.20200313034849-fcc4aca8c78d // indirect
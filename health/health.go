@@ -0,0 +1,154 @@
+// Package health reports whether the service is alive and whether it can serve requests
+package health
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+// statuses of a Report and of every CheckResult
+const (
+	StatusOK           = "ok"
+	StatusDegraded     = "degraded" // only optional checks failed, the service is still ready
+	StatusFailing      = "failing"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check is a dependency of the service
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+
+	// Optional is set for the dependencies the service can serve requests without,
+	// their failure degrades the report instead of making the service not ready
+	Optional bool
+}
+
+// CheckResult is the outcome of a single check. The report is public, only the name and the status
+// of the check are sent, its duration and error are for the logs.
+type CheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"-"`
+	Optional   bool    `json:"optional,omitempty"`
+	Error      string  `json:"-"`
+}
+
+// Report is the readiness of the service, it is ready when every check that is not optional passed
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Ready reports whether the service can serve requests
+func (r *Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+// Checker runs the checks of the readiness probe
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+	clock   clock.Clock
+
+	shuttingDown int32 // accessed atomically
+}
+
+// NewChecker returns a checker running every check with the given timeout
+func NewChecker(clk clock.Clock, timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:  checks,
+		timeout: timeout,
+		clock:   clk,
+	}
+}
+
+// ShuttingDown makes the service report not ready from now on,
+// so that no new traffic is sent to it while the running requests finish
+func (c *Checker) ShuttingDown() {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+}
+
+// Ready runs every check concurrently, each one bounded by the timeout of the checker
+func (c *Checker) Ready(ctx context.Context) *Report {
+
+	if atomic.LoadInt32(&c.shuttingDown) == 1 {
+		return &Report{Status: StatusShuttingDown, Checks: []CheckResult{}}
+	}
+
+	report := &Report{
+		Status: StatusOK,
+		Checks: make([]CheckResult, len(c.checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusOK {
+			continue
+		}
+
+		if !result.Optional {
+			report.Status = StatusFailing
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := c.clock.Now()
+	err := check.Check(ctx)
+	elapsed := c.clock.Now().Sub(start)
+
+	result := CheckResult{
+		Name:       check.Name,
+		Status:     StatusOK,
+		Optional:   check.Optional,
+		DurationMs: float64(elapsed.Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Dial returns a check that opens, and closes, a TCP connection to addr,
+// for the dependencies without a ping of their own such as the SMTP server
+func Dial(addr string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+}
@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+func failing(ctx context.Context) error {
+	return errors.New("dial tcp db.internal:5432: connection refused")
+}
+
+func passing(ctx context.Context) error {
+	return nil
+}
+
+func TestReady(t *testing.T) {
+
+	for _, tt := range []struct {
+		name   string
+		checks []Check
+		want   string
+	}{
+		{"every check passed", []Check{{Name: "database", Check: passing}, {Name: "cache", Check: passing, Optional: true}}, StatusOK},
+		{"an optional check failed", []Check{{Name: "database", Check: passing}, {Name: "cache", Check: failing, Optional: true}}, StatusDegraded},
+		{"a required check failed", []Check{{Name: "database", Check: failing}, {Name: "cache", Check: passing, Optional: true}}, StatusFailing},
+	} {
+		report := NewChecker(clock.Real(), time.Second, tt.checks...).Ready(context.Background())
+		if report.Status != tt.want {
+			t.Errorf("%s: Ready = %s, want %s", tt.name, report.Status, tt.want)
+		}
+	}
+
+	checker := NewChecker(clock.Real(), time.Second, Check{Name: "database", Check: passing})
+	checker.ShuttingDown()
+
+	if report := checker.Ready(context.Background()); report.Ready() || report.Status != StatusShuttingDown {
+		t.Errorf("Ready while shutting down = %s, want %s", report.Status, StatusShuttingDown)
+	}
+}
+
+func TestReportHidesTheErrors(t *testing.T) {
+
+	report := NewChecker(clock.Real(), time.Second, Check{Name: "database", Check: failing}).Ready(context.Background())
+
+	if report.Checks[0].Error == "" {
+		t.Fatal("the error of the failed check is not kept for the logs")
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("encoding the report: %v", err)
+	}
+
+	if strings.Contains(string(body), "db.internal") || strings.Contains(string(body), "duration") {
+		t.Errorf("report = %s, want only the statuses and the names of the checks", body)
+	}
+
+	if want := `{"status":"failing","checks":[{"name":"database","status":"failing"}]}`; string(body) != want {
+		t.Errorf("report = %s, want %s", body, want)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+// serve runs the server until it fails or ctx is canceled. It then reports not ready for cfg.ShutdownDelay,
+// stops accepting connections and waits for the running requests for at most cfg.ShutdownTimeout.
+// The requests still running then are canceled with cancelRequests, and serve only returns once they
+// finished, so that the connections are not closed under them.
+func serve(ctx context.Context, app *fiber.App, cfg config.HTTP, checker *health.Checker, cancelRequests context.CancelFunc, logger *log.Logger) error {
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(cfg.Addr)
+	}()
+
+	select {
+	case err := <-listenErr:
+		// the server never started, e.g. the address is already in use
+		return fmt.Errorf("listening on %s: %w", cfg.Addr, err)
+	case <-ctx.Done():
+	}
+
+	logger.Println("Shutting down...")
+
+	checker.ShuttingDown()
+	time.Sleep(cfg.ShutdownDelay)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- app.Shutdown()
+	}()
+
+	select {
+	case err := <-shutdownErr:
+		return err
+	case <-time.After(cfg.ShutdownTimeout):
+	}
+
+	logger.Printf("Requests still running after the %s shutdown timeout, canceling them", cfg.ShutdownTimeout)
+
+	// the stores and the cache give up on a canceled context, the handlers return soon after
+	cancelRequests()
+
+	if err := <-shutdownErr; err != nil {
+		return err
+	}
+
+	return fmt.Errorf("requests still running after the %s shutdown timeout", cfg.ShutdownTimeout)
+}
+
+// closeOnReturn is deferred to close a connection, a close failure is returned
+// unless the function is already returning an error, in which case it is only logged
+func closeOnReturn(name string, close func() error, err *error, logger *log.Logger) {
+
+	closeErr := close()
+	if closeErr == nil {
+		return
+	}
+
+	closeErr = fmt.Errorf("closing %s: %w", name, closeErr)
+
+	if *err != nil {
+		logger.Println(closeErr)
+		return
+	}
+
+	*err = closeErr
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/health"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// freeAddr returns a local address that nothing listens on
+func freeAddr(t *testing.T) string {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func TestServeWaitsForTheRequestsAfterTheShutdownTimeout(t *testing.T) {
+
+	requests, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(middleware.RequestContext(requests, 0))
+
+	started := make(chan struct{})
+	var finished int32
+
+	// a slow handler, it only gives up once its context is canceled
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		close(started)
+		<-c.UserContext().Done()
+
+		// the cleanup of a canceled request still takes a while
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+
+		return c.SendStatus(http.StatusServiceUnavailable)
+	})
+
+	cfg := config.HTTP{Addr: freeAddr(t), ShutdownTimeout: 100 * time.Millisecond}
+	checker := health.NewChecker(clock.Real(), time.Second)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, app, cfg, checker, cancelRequests, log.New(ioutil.Discard, "", 0))
+	}()
+
+	go func() {
+		// the server may not listen yet
+		for {
+			resp, err := http.Get("http://" + cfg.Addr + "/slow")
+			if err == nil {
+				resp.Body.Close()
+				return
+			}
+
+			select {
+			case <-started:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the slow request never started")
+	}
+
+	stop()
+
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("serve = nil, want the shutdown timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the shutdown timeout")
+	}
+
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("serve returned while the slow request was still running")
+	}
+
+	if requests.Err() == nil {
+		t.Error("the running requests were not canceled")
+	}
+}
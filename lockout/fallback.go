@@ -0,0 +1,118 @@
+package lockout
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type fallbackStore struct {
+	primary  Store
+	fallback Store
+	logger   *log.Logger
+}
+
+// NewFallback returns a store using primary, typically Redis, and fallback, typically in-process,
+// for the calls primary fails. The sign ins stay guarded by the failures seen by this instance
+// while primary is unreachable, instead of being refused or left unguarded. The failures of primary are logged to logger.
+func NewFallback(primary Store, fallback Store, logger *log.Logger) Store {
+	return &fallbackStore{
+		primary:  primary,
+		fallback: fallback,
+		logger:   logger,
+	}
+}
+
+func (f *fallbackStore) Reserve(ctx context.Context, key string, at time.Time, window time.Duration) (Attempts, error) {
+
+	before, err := f.primary.Reserve(ctx, key, at, window)
+	if f.failed(ctx, err) {
+		return f.fallback.Reserve(ctx, key, at, window)
+	}
+
+	return before, err
+}
+
+func (f *fallbackStore) Release(ctx context.Context, key string, at time.Time, before Attempts) error {
+
+	err := f.primary.Release(ctx, key, at, before)
+	if f.failed(ctx, err) {
+		return f.fallback.Release(ctx, key, at, before)
+	}
+
+	return err
+}
+
+func (f *fallbackStore) Attempts(ctx context.Context, key string) (Attempts, error) {
+
+	attempts, err := f.primary.Attempts(ctx, key)
+	if f.failed(ctx, err) {
+		return f.fallback.Attempts(ctx, key)
+	}
+
+	return attempts, err
+}
+
+func (f *fallbackStore) Reset(ctx context.Context, key string) error {
+
+	// the fallback may hold failures from while primary was unreachable
+	err := f.primary.Reset(ctx, key)
+	err2 := f.fallback.Reset(ctx, key)
+
+	if f.failed(ctx, err) {
+		return err2
+	}
+
+	return err
+}
+
+func (f *fallbackStore) Lock(ctx context.Context, key string, until time.Time) error {
+
+	err := f.primary.Lock(ctx, key, until)
+	if f.failed(ctx, err) {
+		return f.fallback.Lock(ctx, key, until)
+	}
+
+	return err
+}
+
+func (f *fallbackStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+
+	// a lock taken while primary was unreachable holds until it ends
+	until, err := f.fallback.LockedUntil(ctx, key)
+	if err != nil || !until.IsZero() {
+		return until, err
+	}
+
+	until, err = f.primary.LockedUntil(ctx, key)
+	if f.failed(ctx, err) {
+		return time.Time{}, nil
+	}
+
+	return until, err
+}
+
+func (f *fallbackStore) Unlock(ctx context.Context, key string) error {
+
+	err := f.primary.Unlock(ctx, key)
+	err2 := f.fallback.Unlock(ctx, key)
+
+	if f.failed(ctx, err) {
+		return err2
+	}
+
+	return err
+}
+
+// failed reports whether primary failed and the call should go to the fallback,
+// a canceled request is no reason to
+func (f *fallbackStore) failed(ctx context.Context, err error) bool {
+
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	f.logger.Println("lockout: primary store failed, using the fallback:", err)
+
+	return true
+}
@@ -0,0 +1,209 @@
+// Package lockout counts the failed sign ins per account and per IP, to slow down password guessing
+// and then to stop it for a while
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+// Attempts are the recent failures of a key
+type Attempts struct {
+	Failures int
+	Last     time.Time // the last failure, zero when there is none
+}
+
+// Store keeps the failures and the locks of the keys, an account or an IP
+type Store interface {
+
+	// Reserve counts an attempt of the key at the given time as a failure before it is tried and returns
+	// the failures before it, so that concurrent attempts each see the ones reserved before them.
+	// The failures are forgotten once window passed without a new one.
+	Reserve(ctx context.Context, key string, at time.Time, window time.Duration) (Attempts, error)
+
+	// Release uncounts the attempt reserved at the given time once it did not fail,
+	// before holds the failures Reserve returned for it
+	Release(ctx context.Context, key string, at time.Time, before Attempts) error
+
+	// Attempts returns the failures of the key, none when they were forgotten
+	Attempts(ctx context.Context, key string) (Attempts, error)
+
+	// Reset forgets the failures of the key
+	Reset(ctx context.Context, key string) error
+
+	// Lock locks the key until the given time and forgets its failures
+	Lock(ctx context.Context, key string, until time.Time) error
+
+	// LockedUntil returns when the lock of the key ends, the zero time when it is not locked
+	LockedUntil(ctx context.Context, key string) (time.Time, error)
+
+	// Unlock lifts the lock of the key and forgets its failures
+	Unlock(ctx context.Context, key string) error
+}
+
+// Policy is how many failures are tolerated and how the sign ins are slowed down until then
+type Policy struct {
+	Window           time.Duration // the failures are forgotten once it passed without a new one
+	AccountThreshold int           // failures locking the account
+	IPThreshold      int           // failures refusing the sign ins from the IP until they are forgotten
+	LockDuration     time.Duration
+	BaseDelay        time.Duration // the wait after the first failure of an account, it doubles with every failure
+	MaxDelay         time.Duration
+}
+
+// Delay is how long an account waits before the next attempt after the given failures
+func (p Policy) Delay(failures int) time.Duration {
+
+	if failures <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+
+	return delay
+}
+
+type memoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]memoryAttempts
+	locks    map[string]time.Time
+	clock    clock.Clock
+}
+
+type memoryAttempts struct {
+	Attempts
+	expiresAt time.Time
+}
+
+// NewMemory returns an in-process store for a single instance
+func NewMemory(clk clock.Clock) Store {
+	return &memoryStore{
+		attempts: map[string]memoryAttempts{},
+		locks:    map[string]time.Time{},
+		clock:    clk,
+	}
+}
+
+func (m *memoryStore) Reserve(ctx context.Context, key string, at time.Time, window time.Duration) (Attempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeExpired()
+
+	attempts := m.attempts[key]
+	before := attempts.Attempts
+
+	attempts.Failures++
+	attempts.Last = at
+	attempts.expiresAt = at.Add(window)
+	m.attempts[key] = attempts
+
+	return before, nil
+}
+
+func (m *memoryStore) Release(ctx context.Context, key string, at time.Time, before Attempts) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, found := m.attempts[key]
+	if !found {
+		// forgotten, reset or locked since
+		return nil
+	}
+
+	attempts.Failures--
+	if attempts.Failures <= 0 {
+		delete(m.attempts, key)
+		return nil
+	}
+
+	// unless a later attempt was reserved meanwhile
+	if attempts.Last.Equal(at) {
+		attempts.Last = before.Last
+	}
+
+	m.attempts[key] = attempts
+
+	return nil
+}
+
+func (m *memoryStore) Attempts(ctx context.Context, key string) (Attempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, found := m.attempts[key]
+	if !found || !m.clock.Now().Before(attempts.expiresAt) {
+		return Attempts{}, nil
+	}
+
+	return attempts.Attempts, nil
+}
+
+func (m *memoryStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+
+	return nil
+}
+
+func (m *memoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeExpired()
+	delete(m.attempts, key)
+	m.locks[key] = until
+
+	return nil
+}
+
+func (m *memoryStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, found := m.locks[key]
+	if !found || !m.clock.Now().Before(until) {
+		return time.Time{}, nil
+	}
+
+	return until, nil
+}
+
+func (m *memoryStore) Unlock(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+	delete(m.locks, key)
+
+	return nil
+}
+
+// removeExpired drops the forgotten failures and the lifted locks, the caller must hold the lock
+func (m *memoryStore) removeExpired() {
+	now := m.clock.Now()
+
+	for key, attempts := range m.attempts {
+		if !now.Before(attempts.expiresAt) {
+			delete(m.attempts, key)
+		}
+	}
+
+	for key, until := range m.locks {
+		if !now.Before(until) {
+			delete(m.locks, key)
+		}
+	}
+}
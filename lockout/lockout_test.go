@@ -0,0 +1,124 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+var start = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func TestReserveReturnsTheFailuresBefore(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(start)
+	store := NewMemory(clk)
+
+	for i := 0; i < 3; i++ {
+		at := clk.Now()
+
+		before, err := store.Reserve(ctx, "key", at, time.Minute)
+		if err != nil {
+			t.Fatalf("Reserve: %v", err)
+		}
+
+		if before.Failures != i || (i > 0 && !before.Last.Equal(at.Add(-time.Second))) {
+			t.Errorf("Reserve #%d = %+v, want %d failures, the last a second ago", i+1, before, i)
+		}
+
+		clk.Advance(time.Second)
+	}
+
+	// forgotten once the window passed without a new one
+	clk.Advance(time.Minute)
+
+	if before, _ := store.Reserve(ctx, "key", clk.Now(), time.Minute); before.Failures != 0 {
+		t.Errorf("Reserve after the window = %+v, want no failures", before)
+	}
+}
+
+func TestReleaseUncountsTheAttempt(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(start)
+	store := NewMemory(clk)
+
+	first := clk.Now()
+	store.Reserve(ctx, "key", first, time.Hour)
+
+	clk.Advance(time.Second)
+	second := clk.Now()
+	before, _ := store.Reserve(ctx, "key", second, time.Hour)
+
+	if err := store.Release(ctx, "key", second, before); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	attempts, _ := store.Attempts(ctx, "key")
+	if attempts.Failures != 1 || !attempts.Last.Equal(first) {
+		t.Errorf("Attempts after Release = %+v, want the first failure only", attempts)
+	}
+
+	// a later attempt reserved meanwhile keeps its time
+	clk.Advance(time.Second)
+	third := clk.Now()
+	before, _ = store.Reserve(ctx, "key", third, time.Hour)
+
+	clk.Advance(time.Second)
+	store.Reserve(ctx, "key", clk.Now(), time.Hour)
+
+	store.Release(ctx, "key", third, before)
+
+	attempts, _ = store.Attempts(ctx, "key")
+	if attempts.Failures != 2 || !attempts.Last.Equal(clk.Now()) {
+		t.Errorf("Attempts after releasing an earlier attempt = %+v, want 2 failures, the last now", attempts)
+	}
+
+	// the failures forgotten meanwhile stay forgotten
+	store.Reset(ctx, "key")
+	store.Release(ctx, "key", clk.Now(), Attempts{Failures: 1, Last: first})
+
+	if attempts, _ := store.Attempts(ctx, "key"); attempts.Failures != 0 {
+		t.Errorf("Attempts after releasing a reset key = %+v, want none", attempts)
+	}
+}
+
+func TestLock(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(start)
+	store := NewMemory(clk)
+
+	store.Reserve(ctx, "key", clk.Now(), time.Hour)
+
+	if err := store.Lock(ctx, "key", clk.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	if until, _ := store.LockedUntil(ctx, "key"); !until.Equal(start.Add(time.Minute)) {
+		t.Errorf("LockedUntil = %v, want %v", until, start.Add(time.Minute))
+	}
+
+	if attempts, _ := store.Attempts(ctx, "key"); attempts.Failures != 0 {
+		t.Errorf("Attempts of a locked key = %+v, want them forgotten", attempts)
+	}
+
+	clk.Advance(time.Minute)
+
+	if until, _ := store.LockedUntil(ctx, "key"); !until.IsZero() {
+		t.Errorf("LockedUntil once the lock ended = %v, want none", until)
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+
+	policy := Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	for failures, want := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := policy.Delay(failures); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package lockout
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type redisStore struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+// NewRedis returns a store shared by every instance, the keys expire with the failures and the locks they hold.
+// Every call is bounded by timeout unless it is 0.
+func NewRedis(client *redis.Client, timeout time.Duration) Store {
+	return &redisStore{
+		client:  client,
+		timeout: timeout,
+	}
+}
+
+func attemptsKey(key string) string {
+	return "LOGIN_FAILURES:" + key
+}
+
+func lockKey(key string) string {
+	return "LOGIN_LOCK:" + key
+}
+
+// reserveScript counts an attempt and returns the failures and the last failure, in unix nanoseconds, before it
+var reserveScript = redis.NewScript(`
+local last = redis.call("HGET", KEYS[1], "last")
+local failures = redis.call("HINCRBY", KEYS[1], "failures", 1)
+redis.call("HSET", KEYS[1], "last", ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return {failures - 1, last or ""}
+`)
+
+// releaseScript uncounts the attempt reserved at ARGV[1] and puts the last failure back to ARGV[2],
+// unless a later attempt was reserved meanwhile
+var releaseScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+local failures = redis.call("HINCRBY", KEYS[1], "failures", -1)
+if failures <= 0 then
+	redis.call("DEL", KEYS[1])
+	return 0
+end
+if redis.call("HGET", KEYS[1], "last") == ARGV[1] then
+	if ARGV[2] == "" then
+		redis.call("HDEL", KEYS[1], "last")
+	else
+		redis.call("HSET", KEYS[1], "last", ARGV[2])
+	end
+end
+return failures
+`)
+
+func (r *redisStore) Reserve(ctx context.Context, key string, at time.Time, window time.Duration) (Attempts, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	values, err := reserveScript.Run(ctx, r.client, []string{attemptsKey(key)}, at.UnixNano(), window.Milliseconds()).Slice()
+	if err != nil {
+		return Attempts{}, err
+	}
+
+	var before Attempts
+
+	failures, _ := values[0].(int64)
+	before.Failures = int(failures)
+
+	if last, _ := values[1].(string); last != "" {
+		nanos, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return Attempts{}, err
+		}
+
+		before.Last = time.Unix(0, nanos)
+	}
+
+	return before, nil
+}
+
+func (r *redisStore) Release(ctx context.Context, key string, at time.Time, before Attempts) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	last := ""
+	if !before.Last.IsZero() {
+		last = strconv.FormatInt(before.Last.UnixNano(), 10)
+	}
+
+	return releaseScript.Run(ctx, r.client, []string{attemptsKey(key)}, at.UnixNano(), last).Err()
+}
+
+func (r *redisStore) Attempts(ctx context.Context, key string) (Attempts, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	values, err := r.client.HMGet(ctx, attemptsKey(key), "failures", "last").Result()
+	if err != nil {
+		return Attempts{}, err
+	}
+
+	failures, ok := values[0].(string)
+	if !ok {
+		return Attempts{}, nil
+	}
+
+	var attempts Attempts
+
+	attempts.Failures, err = strconv.Atoi(failures)
+	if err != nil {
+		return Attempts{}, err
+	}
+
+	if last, ok := values[1].(string); ok {
+		nanos, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return Attempts{}, err
+		}
+
+		attempts.Last = time.Unix(0, nanos)
+	}
+
+	return attempts, nil
+}
+
+func (r *redisStore) Reset(ctx context.Context, key string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.client.Del(ctx, attemptsKey(key)).Err()
+}
+
+func (r *redisStore) Lock(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		// lifted already
+		return nil
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, lockKey(key), until.UnixNano(), ttl)
+		pipe.Del(ctx, attemptsKey(key))
+		return nil
+	})
+
+	return err
+}
+
+func (r *redisStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	nanos, err := r.client.Get(ctx, lockKey(key)).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, nanos), nil
+}
+
+func (r *redisStore) Unlock(ctx context.Context, key string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.client.Del(ctx, lockKey(key), attemptsKey(key)).Err()
+}
+
+func (r *redisStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, r.timeout)
+}
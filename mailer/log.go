@@ -0,0 +1,21 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+type logMailer struct {
+	logger *log.Logger
+}
+
+// NewLog returns a mailer that writes the emails to logger instead of sending them, for local development
+func NewLog(logger *log.Logger) Mailer {
+	return logMailer{logger: logger}
+}
+
+func (m logMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Printf("Email to %s: %s\n%s\n", strings.Join(msg.To, ", "), msg.Subject, msg.Text)
+	return nil
+}
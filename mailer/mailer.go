@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/jordan-wright/email"
+)
+
+// Message is an email to be sent to one or more recipients
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// New returns the SMTP mailer described by the config, or the log mailer when no SMTP host is configured
+func New(cfg config.SMTP, logger *log.Logger) Mailer {
+	if cfg.Host == "" {
+		return NewLog(logger)
+	}
+
+	return NewSMTP(cfg.Host, cfg.Port, cfg.From, cfg.Username, cfg.Password)
+}
+
+// NewSMTP returns a mailer that sends the emails through the SMTP server at host:port
+func NewSMTP(host string, port int, from string, username string, password string) Mailer {
+	return &smtpMailer{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		from: from,
+		auth: smtp.PlainAuth("", username, password, host),
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+
+	// draft the email
+	e := email.Email{
+		To:      msg.To,
+		From:    m.from,
+		Subject: msg.Subject,
+		Text:    []byte(msg.Text),
+	}
+
+	return e.Send(m.addr, m.auth)
+}
@@ -8,44 +8,221 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
-
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/config"
 	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/health"
+	"github.com/Bruary/twitter-clone/mailer"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/pat"
+	"github.com/Bruary/twitter-clone/reconcile"
 	"github.com/Bruary/twitter-clone/service/models"
 	"github.com/Bruary/twitter-clone/service/twitter"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/joho/godotenv"
 )
 
-func init() {
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the service and blocks until it is stopped by SIGINT or SIGTERM,
+// the connections are closed in the reverse order they were opened
+func run() (err error) {
+
+	configPath := flag.String("config", ".env", "path to the config file, the environment takes precedence over it")
+	flag.Usage = usage
+	flag.Parse()
+
+	// Load the config, the config file is optional unless its path was given explicitly
+	cfg, err := config.Load(*configPath, isFlagSet("config"))
+	if err != nil {
+		return err
+	}
+
+	// the errors and status of the service, the command output stays on stdout
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+
+	// canceled on the first SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// restore the default behavior once the shutdown started, so that a second signal kills the process
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
 	// Connect to the db
-	db.SetUpDBConnection()
+	connectCtx, cancelConnect := context.WithTimeout(ctx, cfg.DB.ConnectTimeout)
+	backend, migrator, closeBackend, err := openBackend(connectCtx, cfg, logger)
+	cancelConnect()
+	if err != nil {
+		return err
+	}
+	defer closeOnReturn("the database", closeBackend, &err, logger)
+
+	switch flag.Arg(0) {
+	case "":
+		// no command, serve the API
+	case "migrate":
+		return runMigrate(ctx, migrator, flag.Args()[1:])
+	case "reconcile":
+		return runReconcile(ctx, backend, flag.Args()[1:])
+	default:
+		return fmt.Errorf("unknown command %q, run with -h to list the commands", flag.Arg(0))
+	}
+
+	if cfg.DB.MigrateOnStart {
+		applied, err := migrator.Migrate(ctx)
+		if err != nil {
+			return err
+		}
+
+		printMigrations(applied)
+	}
 
 	// Connect to cache
-	db.SetUpCacheConnection()
+	connectCtx, cancelConnect = context.WithTimeout(ctx, cfg.DB.ConnectTimeout)
+	appCache := openCache(connectCtx, cfg, logger)
+	cancelConnect()
+	defer closeOnReturn("the cache", appCache.close, &err, logger)
+
+	// every store call gets its own deadline on top of the deadline of the request
+	store := db.NewTimeoutStore(backend, db.Timeouts{
+		Read:  cfg.DB.ReadTimeout,
+		Write: cfg.DB.WriteTimeout,
+	})
+
+	tokens, err := openKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	revocations := openRevocations(cfg, appCache, logger)
+
+	passwordPolicy, err := openPasswordPolicy(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	svc := twitter.NewTwitter(twitter.Options{
+		Config:         cfg,
+		Users:          store,
+		Tweets:         store,
+		Follows:        store,
+		RefreshTokens:  store,
+		PasswordResets: store,
+		Audit:          store,
+		TwoFactors:     store,
+		Identities:     store,
+		AccessTokens:   store,
+		Revocations:    revocations,
+		Lockout:        openLockout(cfg, appCache, logger),
+		PasswordPolicy: passwordPolicy,
+		OIDCProviders:  openOIDCProviders(cfg),
+		Cache:          appCache,
+		Mailer:         mailer.New(cfg.SMTP, logger),
+		Tokens:         tokens,
+		Logger:         logger,
+	})
 
-	// Load the .env file
-	errEnv := godotenv.Load(".env")
-	if errEnv != nil {
-		log.Fatalf("Error loading .env file")
+	checks := []health.Check{
+		{Name: "database", Check: backend.Ping},
+		{Name: "cache", Check: appCache.Ping, Optional: true},
 	}
 
-	fmt.Println(".env loaded!")
+	if cfg.SMTP.Host != "" {
+		checks = append(checks, health.Check{
+			Name:  "smtp",
+			Check: health.Dial(net.JoinHostPort(cfg.SMTP.Host, strconv.Itoa(cfg.SMTP.Port))),
+		})
+	}
 
-}
+	checker := health.NewChecker(clock.Real(), cfg.HTTP.HealthCheckTimeout, checks...)
 
-func main() {
+	// background work is canceled once the server stopped and waited for before the connections are closed
+	background, cancelBackground := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	defer func() {
+		cancelBackground()
+		workers.Wait()
+	}()
 
-	svc := twitter.NewTwitter()
+	// recompute the metrics in the background
+	if cfg.Reconcile.Interval > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			reconcile.Schedule(background, backend, cfg.Reconcile.Interval, cfg.Reconcile.Fix, os.Stdout)
+		}()
+	}
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		// idle keep-alive connections would otherwise hold the shutdown until its timeout
+		IdleTimeout: cfg.HTTP.IdleTimeout,
+	})
 
 	app.Use(cors.New())
 
+	// canceled when the running requests outlive the shutdown timeout
+	requests, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	// every route goes after it, fiber does not reset the user context of its pooled contexts
+	app.Use(middleware.RequestContext(requests, cfg.HTTP.RequestTimeout))
+
+	// liveness, the process is up and serving
+	app.Get("/healthz", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": health.StatusOK})
+	})
+
+	// hits, misses and errors of the cache, and whether it runs degraded without redis,
+	// for the admins only like the admin routes
+	if cfg.Admin.APIKey != "" {
+		app.Get("/debug/cache", middleware.Admin(cfg.Admin.APIKey), func(c *fiber.Ctx) error {
+			return c.JSON(appCache.Status())
+		})
+	}
+
+	// readiness, the dependencies are reachable and the service is not shutting down
+	app.Get("/readyz", func(c *fiber.Ctx) error {
+
+		report := checker.Ready(c.UserContext())
+		if !report.Ready() {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+
+		// the errors name hosts and drivers, they are logged instead of sent to whoever asks
+		for _, result := range report.Checks {
+			if result.Error != "" {
+				logger.Printf("Readiness check %s failed after %.1fms: %s", result.Name, result.DurationMs, result.Error)
+			}
+		}
+
+		return c.JSON(report)
+	})
+
+	// the public keys verifying our tokens, for the services validating them on their own
+	app.Get("/.well-known/jwks.json", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(tokens.JWKS())
+	})
+
 	api := app.Group("/api") // api/
 
 	v1 := api.Group("/v1") // api/v1/
@@ -71,6 +248,25 @@ func main() {
 		return nil
 	})
 
+	auth.Post("/refresh", func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.RefreshRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the refresh logic
+		resp := svc.Refresh(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
 	auth.Post("/signup", func(c *fiber.Ctx) error {
 
 		c.Context().SetContentType("application/jsons")
@@ -139,7 +335,187 @@ func main() {
 		return nil
 	})
 
-	user := v1.Group("/user") // api/v1/user/
+	auth.Get("/verifyEmail", func(c *fiber.Ctx) error {
+		c.Context().SetContentType("application/jsons")
+
+		// run the verify email logic
+		resp := svc.VerifyEmail(c)
+
+		if err := MarshalResponseAndSetBody(resp, c); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	// the routes needing an account, the claims of the token are in c.Locals
+	accessTokens := pat.NewVerifier(store, store, revocations, clock.Real(), logger)
+	requireAuth := middleware.Auth(tokens, revocations, accessTokens, logger, "")
+
+	// the routes the personal access tokens with the scope can reach too
+	requireScope := func(scope string) fiber.Handler {
+		return middleware.Auth(tokens, revocations, accessTokens, logger, scope)
+	}
+
+	auth.Post("/verifyEmail/resend", requireAuth, func(c *fiber.Ctx) error {
+		c.Context().SetContentType("application/jsons")
+
+		req := models.BaseRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the resend verification email logic
+		resp := svc.ResendVerificationEmail(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	auth.Post("/logout", requireAuth, func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.LogoutRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the logout logic
+		resp := svc.Logout(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	auth.Post("/logout/all", requireAuth, func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.BaseRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the logout everywhere logic
+		resp := svc.LogoutAll(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	auth.Post("/2fa/enroll", requireAuth, func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.BaseRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the two-factor enrollment logic
+		resp := svc.EnrollTwoFactor(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	auth.Post("/2fa/confirm", requireAuth, func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.TwoFactorConfirmRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the two-factor confirmation logic
+		resp := svc.ConfirmTwoFactor(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	auth.Post("/2fa/signin", func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.TwoFactorSignInRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the two-factor sign in logic
+		resp := svc.TwoFactorSignIn(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	auth.Post("/2fa/disable", requireAuth, func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.TwoFactorDisableRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the disable two-factor logic
+		resp := svc.DisableTwoFactor(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	auth.Get("/oidc/:provider/login", func(c *fiber.Ctx) error {
+		c.Context().SetContentType("application/jsons")
+
+		// run the OIDC login logic
+		resp := svc.OIDCLogin(c)
+
+		if err := MarshalResponseAndSetBody(resp, c); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	auth.Get("/oidc/:provider/callback", func(c *fiber.Ctx) error {
+		c.Context().SetContentType("application/jsons")
+
+		// run the OIDC callback logic
+		resp := svc.OIDCCallback(c)
+
+		if err := MarshalResponseAndSetBody(resp, c); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	user := v1.Group("/user", requireAuth) // api/v1/user/
 	user.Delete("/delete", func(c *fiber.Ctx) error {
 
 		c.Context().SetContentType("application/jsons")
@@ -159,9 +535,85 @@ func main() {
 		return nil
 	})
 
+	user.Post("/changePassword", func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.ChangePasswordRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the change password logic
+		resp := svc.ChangePassword(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	user.Post("/tokens/create", func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.CreatePersonalAccessTokenRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the create personal access token logic
+		resp := svc.CreatePersonalAccessToken(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	user.Post("/tokens/list", func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.BaseRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the list personal access tokens logic
+		resp := svc.ListPersonalAccessTokens(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
+	user.Post("/tokens/revoke", func(c *fiber.Ctx) error {
+
+		c.Context().SetContentType("application/jsons")
+
+		req := models.RevokePersonalAccessTokenRequest{}
+		if err := UnmarshalRequest(&req, c); err != nil {
+			return err
+		}
+
+		// run the revoke personal access token logic
+		resp := svc.RevokePersonalAccessToken(c, req)
+
+		if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+			return err2
+		}
+
+		return nil
+	})
+
 	tweet := v1.Group("/tweets") // api/v1/tweet/
 
-	tweet.Post("/create", func(c *fiber.Ctx) error {
+	tweet.Post("/create", requireScope(models.ScopeTweetsWrite), func(c *fiber.Ctx) error {
 
 		c.Context().SetContentType("application/jsons")
 
@@ -180,7 +632,7 @@ func main() {
 		return nil
 	})
 
-	tweet.Post("/get", func(c *fiber.Ctx) error {
+	tweet.Post("/get", requireScope(models.ScopeTweetsRead), func(c *fiber.Ctx) error {
 
 		c.Context().SetContentType("application/jsons")
 
@@ -199,7 +651,7 @@ func main() {
 		return nil
 	})
 
-	v1.Post("/follow", func(c *fiber.Ctx) error {
+	v1.Post("/follow", requireScope(models.ScopeFollowsWrite), func(c *fiber.Ctx) error {
 
 		c.Context().SetContentType("application/jsons")
 
@@ -218,7 +670,7 @@ func main() {
 		return nil
 	})
 
-	v1.Post("/feed", func(c *fiber.Ctx) error {
+	v1.Post("/feed", requireScope(models.ScopeFeedRead), func(c *fiber.Ctx) error {
 
 		c.Context().SetContentType("application/jsons")
 
@@ -237,7 +689,51 @@ func main() {
 		return nil
 	})
 
-	app.Listen(":4000")
+	// the admin routes only exist with an admin API key
+	if cfg.Admin.APIKey != "" {
+
+		admin := v1.Group("/admin", middleware.Admin(cfg.Admin.APIKey)) // api/v1/admin/
+
+		admin.Post("/users/verifyEmail", func(c *fiber.Ctx) error {
+
+			c.Context().SetContentType("application/jsons")
+
+			req := models.AdminVerifyEmailRequest{}
+			if err := UnmarshalRequest(&req, c); err != nil {
+				return err
+			}
+
+			// run the admin verify email logic
+			resp := svc.AdminVerifyEmail(c, req)
+
+			if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+				return err2
+			}
+
+			return nil
+		})
+
+		admin.Post("/users/unlock", func(c *fiber.Ctx) error {
+
+			c.Context().SetContentType("application/jsons")
+
+			req := models.AdminUnlockUserRequest{}
+			if err := UnmarshalRequest(&req, c); err != nil {
+				return err
+			}
+
+			// run the admin unlock logic
+			resp := svc.AdminUnlockUser(c, req)
+
+			if err2 := MarshalResponseAndSetBody(resp, c); err2 != nil {
+				return err2
+			}
+
+			return nil
+		})
+	}
+
+	return serve(ctx, app, cfg.HTTP, checker, cancelRequests, logger)
 }
 
 func MarshalResponseAndSetBody(resp interface{}, c *fiber.Ctx) error {
@@ -253,6 +749,11 @@ func MarshalResponseAndSetBody(resp interface{}, c *fiber.Ctx) error {
 
 func UnmarshalRequest(reqStruct interface{}, c *fiber.Ctx) error {
 
+	// the routes reading their token from the Authorization header may have no body
+	if len(c.Body()) == 0 {
+		return nil
+	}
+
 	err := json.Unmarshal(c.Body(), reqStruct)
 	if err != nil {
 		c.SendString("Unmarshaling failed.")
@@ -261,3 +762,15 @@ func UnmarshalRequest(reqStruct interface{}, c *fiber.Ctx) error {
 
 	return nil
 }
+
+// isFlagSet reports whether the flag was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminKeyHeader carries the admin API key
+const AdminKeyHeader = "X-Admin-Key"
+
+// Admin lets through the requests carrying the admin API key in the X-Admin-Key header,
+// the others are answered with a 401
+func Admin(apiKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		key := c.Get(AdminKeyHeader)
+
+		if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(&models.BaseResponse{
+				Success:      false,
+				ResponseType: "INVALID_ADMIN_KEY",
+				Msg:          "The " + AdminKeyHeader + " header is missing or wrong.",
+			})
+		}
+
+		return c.Next()
+	}
+}
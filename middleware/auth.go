@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/Bruary/twitter-clone/pat"
+	"github.com/Bruary/twitter-clone/revocation"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+)
+
+// claimsKey is the key of the verified claims in c.Locals
+const claimsKey = "claims"
+
+// bodyTokenWarning is sent to the clients still passing the token in the body
+const bodyTokenWarning = `299 - "the token field of the body is deprecated, send the token in the Authorization header"`
+
+// Auth verifies the access token of the request once and stores its claims in c.Locals, where the handlers
+// read them with Claims. It answers 401 when the token is missing or invalid, so only the
+// routes needing an account go through it.
+//
+// The token is read from the "Authorization: Bearer" header. The token field of the JSON body
+// is still accepted for the older clients, those responses carry a Deprecation header.
+//
+// The tokens revoked by a logout, a password change or the deletion of the account are rejected,
+// the request fails with a 503 when the revocations can not be checked.
+//
+// A personal access token only gets through when it has the scope of the route, the routes with an
+// empty scope only take the tokens of a sign in. The claims of a personal access token carry its scopes.
+//
+// The failed checks are logged to logger.
+func Auth(tokens token.Signer, revocations revocation.Store, accessTokens *pat.Verifier, logger *log.Logger, scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		tokenString := bearerToken(c)
+		if tokenString == "" {
+			tokenString = bodyToken(c)
+
+			if tokenString != "" {
+				c.Set("Deprecation", "true")
+				c.Set(fiber.HeaderWarning, bodyTokenWarning)
+			}
+		}
+
+		if tokenString == "" {
+			return unauthorized(c, "MISSING_TOKEN", "The Authorization header is missing, expected a Bearer token.")
+		}
+
+		if pat.Is(tokenString) {
+			return personalAccessToken(c, accessTokens, logger, scope, tokenString)
+		}
+
+		// the reset and verification links are signed with the same key, only access tokens get through
+		claims, err := tokens.ParseToken(tokenString, models.PurposeAccess)
+		if errors.Is(err, token.ErrExpired) {
+			return unauthorized(c, "TOKEN_EXPIRED", "The token expired, refresh it or sign in again.")
+		}
+
+		if err != nil {
+			return unauthorized(c, "INVALID_TOKEN", "Invalid token.")
+		}
+
+		revoked, err2 := revocations.IsRevoked(c.UserContext(), claims.Id, claims.User_UUID, claims.IssuedAtTime())
+		if err2 != nil {
+			logger.Println("Checking the token revocations failed:", err2)
+
+			return c.Status(fiber.StatusServiceUnavailable).JSON(&models.BaseResponse{
+				Success:      false,
+				ResponseType: "REVOCATION_CHECK_FAILED",
+				Msg:          "Could not check whether the token was revoked, please try again.",
+			})
+		}
+
+		if revoked {
+			return unauthorized(c, "TOKEN_REVOKED", "The token was revoked, sign in again.")
+		}
+
+		c.Locals(claimsKey, claims)
+
+		return c.Next()
+	}
+}
+
+// personalAccessToken lets the request through when the personal access token is valid and has the scope
+func personalAccessToken(c *fiber.Ctx, accessTokens *pat.Verifier, logger *log.Logger, scope string, tokenString string) error {
+
+	accessToken, err := accessTokens.Verify(c.UserContext(), tokenString)
+	if errors.Is(err, pat.ErrExpired) {
+		return unauthorized(c, "TOKEN_EXPIRED", "The personal access token expired, create a new one.")
+	}
+
+	if errors.Is(err, pat.ErrInvalid) {
+		return unauthorized(c, "INVALID_TOKEN", "Invalid token.")
+	}
+
+	if err != nil {
+		logger.Println("Checking the personal access token failed:", err)
+
+		return c.Status(fiber.StatusServiceUnavailable).JSON(&models.BaseResponse{
+			Success:      false,
+			ResponseType: "TOKEN_CHECK_FAILED",
+			Msg:          "Could not check the personal access token, please try again.",
+		})
+	}
+
+	if scope == "" {
+		return c.Status(fiber.StatusForbidden).JSON(&models.BaseResponse{
+			Success:      false,
+			ResponseType: "SIGN_IN_REQUIRED",
+			Msg:          "Personal access tokens can not be used here, send the token of a sign in.",
+		})
+	}
+
+	if !pat.HasScope(accessToken, scope) {
+		return c.Status(fiber.StatusForbidden).JSON(&models.BaseResponse{
+			Success:      false,
+			ResponseType: "INSUFFICIENT_SCOPE",
+			Msg:          "The personal access token lacks the " + scope + " scope.",
+		})
+	}
+
+	c.Locals(claimsKey, &models.Claims{
+		User_UUID:  accessToken.User_UUID,
+		Account_ID: accessToken.Account_ID,
+		Purpose:    models.PurposeAccess,
+		StandardClaims: jwt.StandardClaims{
+			Id:       accessToken.ID,
+			IssuedAt: accessToken.Created_At.Unix(),
+		},
+		Scopes: accessToken.Scopes,
+	})
+
+	return c.Next()
+}
+
+// Claims returns the claims verified by Auth, or nil on a route without it
+func Claims(c *fiber.Ctx) *models.Claims {
+	claims, _ := c.Locals(claimsKey).(*models.Claims)
+	return claims
+}
+
+func bearerToken(c *fiber.Ctx) string {
+	header := c.Get(fiber.HeaderAuthorization)
+
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(header[len("Bearer "):])
+	}
+
+	return ""
+}
+
+// bodyToken returns the deprecated token field of the JSON body
+func bodyToken(c *fiber.Ctx) string {
+	var req models.BaseRequest
+
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return ""
+	}
+
+	return req.Token
+}
+
+func unauthorized(c *fiber.Ctx, responseType string, msg string) error {
+
+	c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+
+	return c.Status(fiber.StatusUnauthorized).JSON(&models.BaseResponse{
+		Success:      false,
+		ResponseType: responseType,
+		Msg:          msg,
+	})
+}
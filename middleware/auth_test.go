@@ -0,0 +1,342 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/db/memory"
+	"github.com/Bruary/twitter-clone/pat"
+	"github.com/Bruary/twitter-clone/revocation"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+)
+
+// flakyRevocations fails to check the revocations while down is set
+type flakyRevocations struct {
+	revocation.Store
+	down bool
+}
+
+func (f *flakyRevocations) IsRevoked(ctx context.Context, tokenID string, userUUID string, issuedAt time.Time) (bool, error) {
+
+	if f.down {
+		return false, errors.New("down")
+	}
+
+	return f.Store.IsRevoked(ctx, tokenID, userUUID, issuedAt)
+}
+
+// flakyAccessTokens fails to read the personal access tokens while down is set
+type flakyAccessTokens struct {
+	db.PersonalAccessTokenStore
+	down bool
+}
+
+func (f *flakyAccessTokens) GetPersonalAccessToken(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+
+	if f.down {
+		return nil, errors.New("down")
+	}
+
+	return f.PersonalAccessTokenStore.GetPersonalAccessToken(ctx, hash)
+}
+
+// seenClaims is what the routes of authTest answer with, Scopes is left out of the JSON of models.Claims
+type seenClaims struct {
+	User_UUID  string
+	Account_ID string
+	Purpose    models.TokenPurpose
+	Scopes     []string
+}
+
+// authTest serves /account, which takes only the tokens of a sign in, and /tweets, which takes
+// the personal access tokens with the tweets:read scope too. Both answer with the claims.
+type authTest struct {
+	app          *fiber.App
+	tokens       *token.Keyring
+	store        *memory.Store
+	revocations  *flakyRevocations
+	accessTokens *flakyAccessTokens
+	clock        *clock.Fake
+	user         *models.UserInfo
+}
+
+func newAuthTest(t *testing.T) *authTest {
+
+	clk := clock.NewFake(time.Now())
+
+	keyring, err := token.NewKeyring([]token.Key{{ID: "test", Algorithm: token.HS256, Secret: []byte("test secret")}}, "test", token.Settings{
+		Issuer:   "twitter-clone",
+		Audience: "twitter-clone",
+		Clock:    clk,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	store := memory.New()
+
+	user := &models.UserInfo{UUID: "user-uuid", Account_ID: "account-id", Email: "user@example.com", Created_At: clk.Now()}
+	if err := store.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	at := &authTest{
+		app:          fiber.New(),
+		tokens:       keyring,
+		store:        store,
+		revocations:  &flakyRevocations{Store: revocation.NewMemory(clk, time.Hour)},
+		accessTokens: &flakyAccessTokens{PersonalAccessTokenStore: store},
+		clock:        clk,
+		user:         user,
+	}
+
+	logger := log.New(ioutil.Discard, "", 0)
+	verifier := pat.NewVerifier(at.accessTokens, store, at.revocations, clk, logger)
+
+	claims := func(c *fiber.Ctx) error {
+		claims := Claims(c)
+		return c.JSON(seenClaims{User_UUID: claims.User_UUID, Account_ID: claims.Account_ID, Purpose: claims.Purpose, Scopes: claims.Scopes})
+	}
+
+	at.app.Post("/account", Auth(keyring, at.revocations, verifier, logger, ""), claims)
+	at.app.Post("/tweets", Auth(keyring, at.revocations, verifier, logger, models.ScopeTweetsRead), claims)
+
+	return at
+}
+
+// signed returns a token of the user with the purpose, expiring after ttl
+func (at *authTest) signed(t *testing.T, purpose models.TokenPurpose, ttl time.Duration) string {
+
+	now := at.clock.Now()
+
+	tokenString, err := at.tokens.Sign(&models.Claims{
+		User_UUID:    at.user.UUID,
+		Account_ID:   at.user.Account_ID,
+		Purpose:      purpose,
+		Issued_At_Ms: now.UnixNano() / int64(time.Millisecond),
+		StandardClaims: jwt.StandardClaims{
+			Id:        "token-id",
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	return tokenString
+}
+
+// accessToken returns a personal access token of the user with the scopes, expiring at expiresAt unless it is nil
+func (at *authTest) accessToken(t *testing.T, expiresAt *time.Time, scopes ...string) string {
+
+	tokenString, hash, err := pat.New()
+	if err != nil {
+		t.Fatalf("pat.New: %v", err)
+	}
+
+	err = at.store.CreatePersonalAccessToken(context.Background(), &models.PersonalAccessToken{
+		ID:         "pat-" + hash[:8],
+		User_UUID:  at.user.UUID,
+		Account_ID: at.user.Account_ID,
+		Name:       "bot",
+		Hash:       hash,
+		Scopes:     scopes,
+		Created_At: at.clock.Now(),
+		Expires_At: expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("CreatePersonalAccessToken: %v", err)
+	}
+
+	return tokenString
+}
+
+// send posts the body to path with the Authorization header, none when it is empty
+func (at *authTest) send(t *testing.T, path string, authorization string, body string) *http.Response {
+
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := at.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+
+	return resp
+}
+
+func decode(t *testing.T, resp *http.Response, v interface{}) {
+
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("decoding the response: %v", err)
+	}
+}
+
+func TestAuthReadsTheToken(t *testing.T) {
+
+	for _, tt := range []struct {
+		name          string
+		authorization func(token string) string
+		body          func(token string) string
+		deprecated    bool
+	}{
+		{"bearer header", func(token string) string { return "Bearer " + token }, func(string) string { return "{}" }, false},
+		{"bearer in lower case", func(token string) string { return "bearer " + token }, func(string) string { return "{}" }, false},
+		{"body", func(string) string { return "" }, func(token string) string { return `{"token":"` + token + `"}` }, true},
+
+		// the header wins, the body is not even looked at
+		{"header and body", func(token string) string { return "Bearer " + token }, func(string) string { return `{"token":"not a token"}` }, false},
+		{"other scheme and body", func(string) string { return "Basic dXNlcjpwYXNz" }, func(token string) string { return `{"token":"` + token + `"}` }, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+
+			at := newAuthTest(t)
+			tokenString := at.signed(t, models.PurposeAccess, time.Hour)
+
+			resp := at.send(t, "/account", tt.authorization(tokenString), tt.body(tokenString))
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+
+			// the tokens of a sign in may do everything
+			var claims seenClaims
+			if decode(t, resp, &claims); claims.User_UUID != at.user.UUID || claims.Scopes != nil {
+				t.Errorf("claims of the user %q with the scopes %q, want %q without scopes", claims.User_UUID, claims.Scopes, at.user.UUID)
+			}
+
+			deprecation, warning := resp.Header.Get("Deprecation"), resp.Header.Get("Warning")
+
+			if tt.deprecated && (deprecation != "true" || warning != bodyTokenWarning) {
+				t.Errorf("Deprecation %q and Warning %q, want true and the body token warning", deprecation, warning)
+			}
+
+			if !tt.deprecated && (deprecation != "" || warning != "") {
+				t.Errorf("Deprecation %q and Warning %q, want none for a token in the header", deprecation, warning)
+			}
+		})
+	}
+}
+
+func TestAuthRejectsTheToken(t *testing.T) {
+
+	for _, tt := range []struct {
+		name         string
+		path         string
+		token        func(t *testing.T, at *authTest) string
+		status       int
+		responseType string
+	}{
+		{"no token", "/account", func(t *testing.T, at *authTest) string {
+			return ""
+		}, http.StatusUnauthorized, "MISSING_TOKEN"},
+		{"garbage", "/account", func(t *testing.T, at *authTest) string {
+			return "not a token"
+		}, http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"expired", "/account", func(t *testing.T, at *authTest) string {
+			tokenString := at.signed(t, models.PurposeAccess, time.Minute)
+			at.clock.Advance(2 * time.Minute)
+			return tokenString
+		}, http.StatusUnauthorized, "TOKEN_EXPIRED"},
+
+		// the verification links are signed with the same key
+		{"email verification token", "/account", func(t *testing.T, at *authTest) string {
+			return at.signed(t, models.PurposeEmailVerify, time.Hour)
+		}, http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"revoked", "/account", func(t *testing.T, at *authTest) string {
+			tokenString := at.signed(t, models.PurposeAccess, time.Hour)
+			at.clock.Advance(time.Millisecond)
+			at.revocations.RevokeUser(context.Background(), at.user.UUID, at.clock.Now())
+			return tokenString
+		}, http.StatusUnauthorized, "TOKEN_REVOKED"},
+		{"revocations down", "/account", func(t *testing.T, at *authTest) string {
+			at.revocations.down = true
+			return at.signed(t, models.PurposeAccess, time.Hour)
+		}, http.StatusServiceUnavailable, "REVOCATION_CHECK_FAILED"},
+
+		{"personal access token where a sign in is required", "/account", func(t *testing.T, at *authTest) string {
+			return at.accessToken(t, nil, models.ScopeTweetsRead)
+		}, http.StatusForbidden, "SIGN_IN_REQUIRED"},
+		{"personal access token without the scope", "/tweets", func(t *testing.T, at *authTest) string {
+			return at.accessToken(t, nil, models.ScopeTweetsWrite, models.ScopeFeedRead)
+		}, http.StatusForbidden, "INSUFFICIENT_SCOPE"},
+		{"unknown personal access token", "/tweets", func(t *testing.T, at *authTest) string {
+			tokenString, _, _ := pat.New()
+			return tokenString
+		}, http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"expired personal access token", "/tweets", func(t *testing.T, at *authTest) string {
+			expiresAt := at.clock.Now().Add(time.Hour)
+			tokenString := at.accessToken(t, &expiresAt, models.ScopeTweetsRead)
+			at.clock.Advance(time.Hour)
+			return tokenString
+		}, http.StatusUnauthorized, "TOKEN_EXPIRED"},
+		{"personal access tokens down", "/tweets", func(t *testing.T, at *authTest) string {
+			tokenString := at.accessToken(t, nil, models.ScopeTweetsRead)
+			at.accessTokens.down = true
+			return tokenString
+		}, http.StatusServiceUnavailable, "TOKEN_CHECK_FAILED"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+
+			at := newAuthTest(t)
+
+			authorization := ""
+			if tokenString := tt.token(t, at); tokenString != "" {
+				authorization = "Bearer " + tokenString
+			}
+
+			resp := at.send(t, tt.path, authorization, "{}")
+
+			var body models.BaseResponse
+			if decode(t, resp, &body); resp.StatusCode != tt.status || body.ResponseType != tt.responseType {
+				t.Errorf("response = %d %s, want %d %s", resp.StatusCode, body.ResponseType, tt.status, tt.responseType)
+			}
+
+			// only the 401s ask for a token
+			if challenge := resp.Header.Get("WWW-Authenticate"); (tt.status == http.StatusUnauthorized) != (challenge == "Bearer") {
+				t.Errorf("WWW-Authenticate = %q on a %d", challenge, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestAuthLetsAPersonalAccessTokenWithTheScopeThrough(t *testing.T) {
+
+	at := newAuthTest(t)
+	tokenString := at.accessToken(t, nil, models.ScopeTweetsWrite, models.ScopeTweetsRead)
+
+	resp := at.send(t, "/tweets", "Bearer "+tokenString, "{}")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var claims seenClaims
+	decode(t, resp, &claims)
+
+	if claims.User_UUID != at.user.UUID || claims.Account_ID != at.user.Account_ID || claims.Purpose != models.PurposeAccess {
+		t.Errorf("claims = %+v, want the access claims of the user", claims)
+	}
+
+	if strings.Join(claims.Scopes, " ") != models.ScopeTweetsWrite+" "+models.ScopeTweetsRead {
+		t.Errorf("claims with the scopes %q, want the scopes of the token", claims.Scopes)
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestContext gives every request a context, available through c.UserContext(), that the handlers
+// pass to the stores and the cache. It is canceled when the request finishes, when the timeout
+// runs out or when base is canceled, which a shutdown does once the running requests ran out of time.
+//
+// fasthttp does not report client disconnects while a handler runs, so the timeout is what
+// bounds the work of a request whose client went away.
+func RequestContext(base context.Context, timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		// not derived from c.Context(), the fasthttp request context is pooled and done on shutdown
+		var ctx context.Context
+		var cancel context.CancelFunc
+
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(base, timeout)
+		} else {
+			ctx, cancel = context.WithCancel(base)
+		}
+		defer cancel()
+
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
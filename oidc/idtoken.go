@@ -0,0 +1,324 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// IDToken is who the provider says signed in
+type IDToken struct {
+	Subject       string // the user at the provider, it never changes unlike the email
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+}
+
+// the algorithms the ID tokens may be signed with, RS256 is the one every provider supports
+var signingAlgorithms = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// keysRefetchInterval is the least time between two fetches of the keys for an unknown key ID,
+// so that tokens with made up key IDs do not make us hammer the provider
+const keysRefetchInterval = time.Minute
+
+type idTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce"`
+	Email           string   `json:"email"`
+	EmailVerified   boolish  `json:"email_verified"`
+	GivenName       string   `json:"given_name"`
+	FamilyName      string   `json:"family_name"`
+	Name            string   `json:"name"`
+}
+
+// Valid is left to verify, which checks the claims against our clock
+func (idTokenClaims) Valid() error {
+	return nil
+}
+
+// audience is a single audience or a list of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+
+	*a = list
+
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// boolish is a boolean some providers send as a string
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = boolish(value)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+
+	*b = text == "true"
+
+	return nil
+}
+
+// verify checks the signature of the ID token with the keys of the provider, then its claims
+func (p *Provider) verify(ctx context.Context, raw string, nonce string) (*IDToken, error) {
+
+	claims := &idTokenClaims{}
+
+	parser := &jwt.Parser{ValidMethods: signingAlgorithms, SkipClaimsValidation: true}
+
+	_, err := parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.get(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	now := p.now().Unix()
+
+	if claims.Issuer != p.discovery.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+
+	if !claims.Audience.contains(p.config.ClientID) {
+		return nil, fmt.Errorf("%w: not meant for us", ErrInvalidIDToken)
+	}
+
+	// a token meant for several clients must have been requested by us
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%w: authorized party %q is not us", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+
+	if claims.ExpiresAt == 0 || now >= claims.ExpiresAt {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+
+	// the nonce ties the token to the login that asked for it, a token replayed from another login is refused
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: unexpected nonce", ErrInvalidIDToken)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	return &IDToken{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Name:          claims.Name,
+	}, nil
+}
+
+// keySet holds the public keys of the provider by key ID, they are fetched again when a token
+// names one we do not know, which is how the providers rotate their keys
+type keySet struct {
+	uri      string
+	provider *Provider
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+func newKeySet(uri string, provider *Provider) *keySet {
+	return &keySet{
+		uri:      uri,
+		provider: provider,
+	}
+}
+
+func (k *keySet) get(ctx context.Context, kid string) (interface{}, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key, found := k.lookup(kid); found {
+		return key, nil
+	}
+
+	if k.keys != nil && k.provider.now().Sub(k.fetchedAt) < keysRefetchInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	if err := k.fetch(ctx); err != nil {
+		return nil, err
+	}
+
+	if key, found := k.lookup(kid); found {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookup finds the key, a token without a key ID is only accepted when the provider has a single key
+func (k *keySet) lookup(kid string) (interface{}, bool) {
+
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+
+	key, found := k.keys[kid]
+
+	return key, found
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *keySet) fetch(ctx context.Context) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.uri, nil)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	status, err := k.provider.do(req, &set)
+	if err != nil {
+		return fmt.Errorf("fetching the keys: %w", err)
+	}
+
+	if status != http.StatusOK {
+		return fmt.Errorf("fetching the keys: status %d", status)
+	}
+
+	keys := map[string]interface{}{}
+
+	for _, key := range set.Keys {
+
+		// the encryption keys and the key types we do not support are skipped
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		publicKey, err := key.publicKey()
+		if err != nil {
+			continue
+		}
+
+		keys[key.Kid] = publicKey
+	}
+
+	k.keys = keys
+	k.fetchedAt = k.provider.now()
+
+	return nil
+}
+
+func (key jwk) publicKey() (interface{}, error) {
+
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(key.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+		}
+
+		x, err := decodeBigInt(key.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(key.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", key.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
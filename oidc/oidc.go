@@ -0,0 +1,219 @@
+// Package oidc signs users in with an OpenID Connect provider, with the authorization code flow
+// and PKCE. The provider is found through its discovery document and its ID tokens are verified
+// against its published keys.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+// ErrInvalidIDToken is returned for an ID token that is not signed by the provider, not meant for us, or expired
+var ErrInvalidIDToken = errors.New("oidc: invalid ID token")
+
+// Config is a provider and the client we are registered as with it
+type Config struct {
+	Name         string // the provider in our routes, like google
+	Issuer       string // the discovery document is under it
+	ClientID     string
+	ClientSecret string
+	Scopes       []string // openid is always asked for
+	RedirectURL  string   // our callback, as registered with the provider
+}
+
+// Provider is an OpenID Connect provider, it is safe for concurrent use
+type Provider struct {
+	config Config
+	client *http.Client
+	clock  clock.Clock
+
+	mu        sync.Mutex
+	discovery *discovery // nil until fetched
+	keys      *keySet
+}
+
+// discovery is the part of the discovery document we use
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProvider returns the provider of config, its discovery document is fetched on first use
+// so that an unreachable provider does not keep the service from starting
+func NewProvider(config Config, client *http.Client, clk clock.Clock) *Provider {
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if clk == nil {
+		clk = clock.Real()
+	}
+
+	return &Provider{
+		config: config,
+		client: client,
+		clock:  clk,
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL is where the user is sent to sign in with the provider
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, codeChallenge string) (string, error) {
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := []string{"openid"}
+	for _, scope := range p.config.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the code of the callback for the tokens and returns the verified ID token
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*IDToken, error) {
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	status, err := p.do(req, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchanging the code: %w", err)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: exchanging the code: %d %s %s", status, tokens.Error, tokens.ErrorDescription)
+	}
+
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("oidc: the token response has no ID token")
+	}
+
+	return p.verify(ctx, tokens.IDToken, nonce)
+}
+
+// discover fetches the discovery document once, a failure is retried on the next call
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d discovery
+
+	status, err := p.do(req, &d)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: fetching the discovery document of %s: %w", p.config.Name, err)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: fetching the discovery document of %s: status %d", p.config.Name, status)
+	}
+
+	// the document must be the one of the issuer we were configured with, not one it points to
+	if d.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: the discovery document of %s is for issuer %q, expected %q", p.config.Name, d.Issuer, p.config.Issuer)
+	}
+
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: the discovery document of %s misses an endpoint", p.config.Name)
+	}
+
+	p.discovery = &d
+	p.keys = newKeySet(d.JWKSURI, p)
+
+	return p.discovery, nil
+}
+
+// do sends the request and decodes the JSON body of the response into dst, whatever its status
+func (p *Provider) do(req *http.Request, dst interface{}) (int, error) {
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// the documents we read are small, a larger body is not one of them
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if err := json.Unmarshal(body, dst); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, err
+	}
+
+	return resp.StatusCode, nil
+}
+
+// now is the time of the clock, it is what the ID tokens are checked against
+func (p *Provider) now() time.Time {
+	return p.clock.Now()
+}
@@ -0,0 +1,274 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/oidc"
+	"github.com/Bruary/twitter-clone/oidc/oidctest"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	clientID     = "twitter-clone"
+	clientSecret = "client secret"
+)
+
+// login is a login started with the provider, up to the user being sent to authURL
+type login struct {
+	provider     *oidc.Provider
+	fake         *oidctest.Provider
+	clock        *clock.Fake
+	authURL      string
+	nonce        string
+	codeVerifier string
+}
+
+func startLogin(t *testing.T) *login {
+
+	clk := clock.NewFake(time.Now())
+	fake := oidctest.NewProvider(t, clientID, clientSecret, clk)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       fake.Issuer(),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"email"},
+		RedirectURL:  "http://localhost/api/v1/auth/oidc/test/callback",
+	}, fake.Server.Client(), clk)
+
+	states := oidc.NewStates([]byte("a state secret of at least 32 characters"), 10*time.Minute, clk)
+
+	state, err := states.New("test")
+	if err != nil {
+		t.Fatalf("New state: %v", err)
+	}
+
+	l := &login{
+		provider:     provider,
+		fake:         fake,
+		clock:        clk,
+		nonce:        states.Nonce(state),
+		codeVerifier: states.CodeVerifier(state),
+	}
+
+	l.authURL, err = provider.AuthCodeURL(context.Background(), state, l.nonce, oidc.CodeChallenge(l.codeVerifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	return l
+}
+
+// finish signs in at the provider with the claims and exchanges the code
+func (l *login) finish(t *testing.T, claims jwt.MapClaims) (*oidc.IDToken, error) {
+	code := l.fake.Login(t, l.authURL, claims)
+	return l.provider.Exchange(context.Background(), code, l.codeVerifier, l.nonce)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+
+	l := startLogin(t)
+
+	parsed, err := url.Parse(l.authURL)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", l.authURL, err)
+	}
+
+	query := parsed.Query()
+
+	for key, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             clientID,
+		"scope":                 "openid email",
+		"nonce":                 l.nonce,
+		"code_challenge":        oidc.CodeChallenge(l.codeVerifier),
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	if parsed.Scheme+"://"+parsed.Host+parsed.Path != l.fake.Issuer()+"/authorize" {
+		t.Errorf("AuthCodeURL = %s, want the authorization endpoint of the discovery document", l.authURL)
+	}
+}
+
+func TestExchange(t *testing.T) {
+
+	l := startLogin(t)
+
+	claims := l.fake.Claims(t, l.authURL, "subject")
+	claims["email"] = "user@example.com"
+	claims["email_verified"] = "true"
+	claims["given_name"] = "Given"
+	claims["family_name"] = "Family"
+
+	idToken, err := l.finish(t, claims)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	want := oidc.IDToken{Subject: "subject", Email: "user@example.com", EmailVerified: true, GivenName: "Given", FamilyName: "Family"}
+	if *idToken != want {
+		t.Errorf("Exchange = %+v, want %+v", *idToken, want)
+	}
+}
+
+func TestExchangeRefusesInvalidIDTokens(t *testing.T) {
+
+	for _, tt := range []struct {
+		name   string
+		change func(l *login, claims jwt.MapClaims)
+	}{
+		{"nonce of another login", func(l *login, claims jwt.MapClaims) {
+			claims["nonce"] = "another nonce"
+		}},
+		{"no nonce", func(l *login, claims jwt.MapClaims) {
+			delete(claims, "nonce")
+		}},
+		{"another audience", func(l *login, claims jwt.MapClaims) {
+			claims["aud"] = "another client"
+		}},
+		{"several audiences without azp", func(l *login, claims jwt.MapClaims) {
+			claims["aud"] = []string{clientID, "another client"}
+		}},
+		{"several audiences for another azp", func(l *login, claims jwt.MapClaims) {
+			claims["aud"] = []string{clientID, "another client"}
+			claims["azp"] = "another client"
+		}},
+		{"another issuer", func(l *login, claims jwt.MapClaims) {
+			claims["iss"] = "https://issuer.example.com"
+		}},
+		{"expired", func(l *login, claims jwt.MapClaims) {
+			claims["exp"] = l.clock.Now().Add(-time.Second).Unix()
+		}},
+		{"expiring now", func(l *login, claims jwt.MapClaims) {
+			claims["exp"] = l.clock.Now().Unix()
+		}},
+		{"no expiry", func(l *login, claims jwt.MapClaims) {
+			delete(claims, "exp")
+		}},
+		{"no subject", func(l *login, claims jwt.MapClaims) {
+			delete(claims, "sub")
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+
+			l := startLogin(t)
+
+			claims := l.fake.Claims(t, l.authURL, "subject")
+			tt.change(l, claims)
+
+			if _, err := l.finish(t, claims); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("Exchange = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestExchangeAcceptsSeveralAudiencesForUs(t *testing.T) {
+
+	l := startLogin(t)
+
+	claims := l.fake.Claims(t, l.authURL, "subject")
+	claims["aud"] = []string{clientID, "another client"}
+	claims["azp"] = clientID
+
+	if _, err := l.finish(t, claims); err != nil {
+		t.Errorf("Exchange: %v", err)
+	}
+}
+
+func TestExchangeRefusesExpiredTokensByTheClock(t *testing.T) {
+
+	l := startLogin(t)
+
+	code := l.fake.Login(t, l.authURL, l.fake.Claims(t, l.authURL, "subject"))
+
+	// the user took longer than the token lives to come back
+	l.clock.Advance(time.Hour)
+
+	if _, err := l.provider.Exchange(context.Background(), code, l.codeVerifier, l.nonce); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Exchange of an expired token = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestExchangeRefusesTokensOfAnotherKey(t *testing.T) {
+
+	l := startLogin(t)
+
+	// signed by another provider under the same key ID
+	other := oidctest.NewProvider(t, clientID, clientSecret, l.clock)
+	code := l.fake.Issue(t, oidc.CodeChallenge(l.codeVerifier), other.Sign(t, l.fake.Claims(t, l.authURL, "subject")))
+
+	if _, err := l.provider.Exchange(context.Background(), code, l.codeVerifier, l.nonce); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Exchange of a token of another key = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestExchangeSendsTheCodeVerifier(t *testing.T) {
+
+	l := startLogin(t)
+
+	code := l.fake.Login(t, l.authURL, l.fake.Claims(t, l.authURL, "subject"))
+
+	// the provider only hands the tokens to whoever has the verifier of the challenge
+	_, err := l.provider.Exchange(context.Background(), code, "another verifier", l.nonce)
+	if err == nil || errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Exchange with another verifier = %v, want the provider to refuse the code", err)
+	}
+
+	// a code works once
+	if _, err := l.provider.Exchange(context.Background(), code, l.codeVerifier, l.nonce); err == nil {
+		t.Error("Exchange of a spent code succeeded")
+	}
+}
+
+func TestDiscoveryOfAnotherIssuer(t *testing.T) {
+
+	clk := clock.NewFake(time.Now())
+	fake := oidctest.NewProvider(t, clientID, clientSecret, clk)
+
+	// configured with an issuer the discovery document does not claim
+	provider := oidc.NewProvider(oidc.Config{Name: "test", Issuer: fake.Issuer() + "/", ClientID: clientID}, fake.Server.Client(), clk)
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); err == nil {
+		t.Error("AuthCodeURL succeeded with the discovery document of another issuer")
+	}
+}
+
+func TestStates(t *testing.T) {
+
+	clk := clock.NewFake(time.Now())
+	states := oidc.NewStates([]byte("a state secret of at least 32 characters"), 10*time.Minute, clk)
+
+	state, err := states.New("test")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := states.Check(state, "test"); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+
+	if err := states.Check(state, "another"); err != oidc.ErrInvalidState {
+		t.Errorf("Check for another provider = %v, want ErrInvalidState", err)
+	}
+
+	if err := states.Check(state+"x", "test"); err != oidc.ErrInvalidState {
+		t.Errorf("Check of a tampered state = %v, want ErrInvalidState", err)
+	}
+
+	clk.Advance(10 * time.Minute)
+
+	if err := states.Check(state, "test"); err != oidc.ErrInvalidState {
+		t.Errorf("Check of an expired state = %v, want ErrInvalidState", err)
+	}
+}
@@ -0,0 +1,212 @@
+// Package oidctest is a fake OpenID Connect provider for the tests, it serves the discovery document,
+// the keys and the token endpoint over HTTP and stands in for the user signing in
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// KeyID is the key ID of the tokens the provider signs
+const KeyID = "test-key"
+
+// Provider is a fake OpenID Connect provider, its codes are exchanged once against
+// the verifier of their PKCE challenge
+type Provider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key   *rsa.PrivateKey
+	clock clock.Clock
+
+	mu    sync.Mutex
+	codes map[string]login
+}
+
+// login is a sign in at the provider waiting for its code to be exchanged
+type login struct {
+	codeChallenge string
+	idToken       string
+}
+
+// NewProvider starts a provider for the client, the ID tokens are issued at the time of clk.
+// It is closed at the end of the test.
+func NewProvider(t *testing.T, clientID string, clientSecret string, clk clock.Clock) *Provider {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating the key of the provider: %v", err)
+	}
+
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		clock:        clk,
+		codes:        map[string]login{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/keys", p.keys)
+	mux.HandleFunc("/token", p.token)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+
+	return p
+}
+
+// Issuer is the issuer of the provider, its discovery document is under it
+func (p *Provider) Issuer() string {
+	return p.Server.URL
+}
+
+// Claims returns the claims of a valid ID token for the login at authURL: the issuer, the client as
+// the audience, the nonce of authURL and an expiry in an hour, along with the subject
+func (p *Provider) Claims(t *testing.T, authURL string, subject string) jwt.MapClaims {
+
+	query := authQuery(t, authURL)
+	now := p.clock.Now()
+
+	return jwt.MapClaims{
+		"iss":   p.Issuer(),
+		"sub":   subject,
+		"aud":   p.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+}
+
+// Login stands in for the user signing in at the provider from authURL and returns the code
+// the provider sends back to the callback, it is exchanged for an ID token carrying the claims
+func (p *Provider) Login(t *testing.T, authURL string, claims jwt.MapClaims) string {
+
+	query := authQuery(t, authURL)
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("the login %s has no S256 code challenge", authURL)
+	}
+
+	return p.Issue(t, query.Get("code_challenge"), p.Sign(t, claims))
+}
+
+// Sign returns an ID token of the claims signed with the key of the provider
+func (p *Provider) Sign(t *testing.T, claims jwt.MapClaims) string {
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = KeyID
+
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatalf("signing the ID token: %v", err)
+	}
+
+	return signed
+}
+
+// Issue returns a code exchanged for the ID token against the verifier of codeChallenge
+func (p *Provider) Issue(t *testing.T, codeChallenge string, idToken string) string {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("creating the code: %v", err)
+	}
+
+	code := base64.RawURLEncoding.EncodeToString(b)
+
+	p.mu.Lock()
+	p.codes[code] = login{codeChallenge: codeChallenge, idToken: idToken}
+	p.mu.Unlock()
+
+	return code
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.Issuer(),
+		"authorization_endpoint": p.Issuer() + "/authorize",
+		"token_endpoint":         p.Issuer() + "/token",
+		"jwks_uri":               p.Issuer() + "/keys",
+	})
+}
+
+func (p *Provider) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != url.QueryEscape(p.ClientID) || clientSecret != url.QueryEscape(p.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// a code is exchanged once, whatever the outcome
+	p.mu.Lock()
+	login, found := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if !found {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown code"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != login.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code verifier does not match"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access token",
+		"token_type":   "Bearer",
+		"id_token":     login.idToken,
+	})
+}
+
+func authQuery(t *testing.T, authURL string) url.Values {
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parsing the login %s: %v", authURL, err)
+	}
+
+	return parsed.Query()
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
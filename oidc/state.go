@@ -0,0 +1,101 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+// ErrInvalidState is returned for a callback that does not belong to a login we started, or came too late
+var ErrInvalidState = errors.New("oidc: invalid state")
+
+// States makes the state of the logins and derives their nonce and PKCE verifier from it. Nothing is
+// kept on our side: the state carries its provider and start time under an HMAC, so that any instance
+// can finish a login another one started.
+type States struct {
+	secret []byte
+	ttl    time.Duration
+	clock  clock.Clock
+}
+
+// NewStates returns the states of the logins, which must be finished within ttl
+func NewStates(secret []byte, ttl time.Duration, clk clock.Clock) *States {
+
+	if clk == nil {
+		clk = clock.Real()
+	}
+
+	return &States{
+		secret: secret,
+		ttl:    ttl,
+		clock:  clk,
+	}
+}
+
+// New returns the state of a new login with the provider
+func (s *States) New(provider string) (string, error) {
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(random) + "." + strconv.FormatInt(s.clock.Now().Unix(), 10)
+
+	return payload + "." + s.mac("state", provider, payload), nil
+}
+
+// Check makes sure the state was made by us for the provider and is not too old
+func (s *States) Check(state string, provider string) error {
+
+	dot := strings.LastIndex(state, ".")
+	if dot < 0 {
+		return ErrInvalidState
+	}
+
+	payload, mac := state[:dot], state[dot+1:]
+
+	if !hmac.Equal([]byte(mac), []byte(s.mac("state", provider, payload))) {
+		return ErrInvalidState
+	}
+
+	startedAt, err := strconv.ParseInt(payload[strings.LastIndex(payload, ".")+1:], 10, 64)
+	if err != nil {
+		return ErrInvalidState
+	}
+
+	if !s.clock.Now().Before(time.Unix(startedAt, 0).Add(s.ttl)) {
+		return ErrInvalidState
+	}
+
+	return nil
+}
+
+// Nonce is the nonce of the login, the ID token must carry it
+func (s *States) Nonce(state string) string {
+	return s.mac("nonce", "", state)
+}
+
+// CodeVerifier is the PKCE verifier of the login, only its challenge is sent along with the user
+func (s *States) CodeVerifier(state string) string {
+	return s.mac("pkce", "", state)
+}
+
+// CodeChallenge is the S256 challenge of the PKCE verifier
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (s *States) mac(purpose string, provider string, value string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(purpose + "\x00" + provider + "\x00" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
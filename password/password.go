@@ -0,0 +1,211 @@
+// Package password hashes the passwords of the users. The hashes describe themselves, the algorithm and
+// its parameters are part of them, so that the hashes of older settings keep verifying and can be upgraded.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// the supported algorithms
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// ErrUnknownHash is returned for a hash of no supported algorithm
+var ErrUnknownHash = errors.New("password: unknown hash format")
+
+// ErrTooLong is returned when hashing a password longer than the algorithm takes
+var ErrTooLong = errors.New("password: too long for the algorithm")
+
+// BcryptMaxBytes is the longest password bcrypt hashes, it silently ignores the bytes after
+const BcryptMaxBytes = 72
+
+// MaxBytes is the longest password the algorithm hashes in full, 0 when it takes any length
+func MaxBytes(algorithm string) int {
+
+	if algorithm == Bcrypt {
+		return BcryptMaxBytes
+	}
+
+	return 0
+}
+
+// Params are the algorithm new hashes are made with and its parameters
+type Params struct {
+	Algorithm  string // Bcrypt or Argon2id
+	BcryptCost int
+
+	Argon2Time    uint32 // passes over the memory
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Hasher hashes new passwords with its params and verifies the hashes of any params
+type Hasher interface {
+
+	// Hash returns the hash of the password with the params of the hasher
+	Hash(password string) (string, error)
+
+	// Verify reports whether the password matches the hash, whatever params made it
+	Verify(password string, hash string) (bool, error)
+
+	// NeedsRehash reports whether the hash was made with other params than the ones of the hasher,
+	// the password should then be hashed again once it is known to be right
+	NeedsRehash(hash string) bool
+}
+
+type hasher struct {
+	params Params
+}
+
+// New returns a hasher making the hashes of params
+func New(params Params) (Hasher, error) {
+
+	switch params.Algorithm {
+	case Bcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("password: the bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+
+	case Argon2id:
+		if params.Argon2Time == 0 || params.Argon2Memory == 0 || params.Argon2Threads == 0 {
+			return nil, fmt.Errorf("password: the argon2id time, memory and threads must be positive")
+		}
+
+	default:
+		return nil, fmt.Errorf("password: unsupported algorithm %q, expected %s or %s", params.Algorithm, Bcrypt, Argon2id)
+	}
+
+	return &hasher{params: params}, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+
+	if h.params.Algorithm == Bcrypt {
+
+		// two passwords sharing their first 72 bytes would match the same hash
+		if len(password) > BcryptMaxBytes {
+			return "", ErrTooLong
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.params.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return argon2Hash{
+		time:    h.params.Argon2Time,
+		memory:  h.params.Argon2Memory,
+		threads: h.params.Argon2Threads,
+		salt:    salt,
+		key:     argon2.IDKey([]byte(password), salt, h.params.Argon2Time, h.params.Argon2Memory, h.params.Argon2Threads, argon2KeyLength),
+	}.String(), nil
+}
+
+func (h *hasher) Verify(password string, hash string) (bool, error) {
+
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	parsed, err := parseArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), parsed.salt, parsed.time, parsed.memory, parsed.threads, uint32(len(parsed.key)))
+
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1, nil
+}
+
+func (h *hasher) NeedsRehash(hash string) bool {
+
+	if isBcrypt(hash) {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || h.params.Algorithm != Bcrypt || cost != h.params.BcryptCost
+	}
+
+	parsed, err := parseArgon2(hash)
+	if err != nil || h.params.Algorithm != Argon2id {
+		return true
+	}
+
+	return parsed.time != h.params.Argon2Time || parsed.memory != h.params.Argon2Memory ||
+		parsed.threads != h.params.Argon2Threads || len(parsed.key) != argon2KeyLength
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// argon2Hash is an argon2id hash in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+type argon2Hash struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+var b64 = base64.RawStdEncoding
+
+func (a argon2Hash) String() string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.memory, a.time, a.threads, b64.EncodeToString(a.salt), b64.EncodeToString(a.key))
+}
+
+func parseArgon2(hash string) (argon2Hash, error) {
+
+	var parsed argon2Hash
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != Argon2id {
+		return parsed, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return parsed, ErrUnknownHash
+	}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parsed.memory, &parsed.time, &parsed.threads)
+	if err != nil || parsed.time == 0 || parsed.threads == 0 {
+		return parsed, ErrUnknownHash
+	}
+
+	parsed.salt, err = b64.DecodeString(parts[4])
+	if err != nil {
+		return parsed, ErrUnknownHash
+	}
+
+	parsed.key, err = b64.DecodeString(parts[5])
+	if err != nil || len(parsed.key) == 0 {
+		return parsed, ErrUnknownHash
+	}
+
+	return parsed, nil
+}
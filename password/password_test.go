@@ -0,0 +1,199 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	bcryptParams = Params{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+	argon2Params = Params{Algorithm: Argon2id, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1}
+)
+
+func newHasher(t *testing.T, params Params) Hasher {
+
+	hasher, err := New(params)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return hasher
+}
+
+func TestNewChecksTheParams(t *testing.T) {
+
+	for _, params := range []Params{
+		{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost - 1},
+		{Algorithm: Bcrypt, BcryptCost: bcrypt.MaxCost + 1},
+		{Algorithm: Argon2id, Argon2Memory: 64, Argon2Threads: 1},
+		{Algorithm: Argon2id, Argon2Time: 1, Argon2Threads: 1},
+		{Algorithm: Argon2id, Argon2Time: 1, Argon2Memory: 64},
+		{Algorithm: "scrypt"},
+	} {
+		if _, err := New(params); err == nil {
+			t.Errorf("New(%+v) succeeded", params)
+		}
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+
+	for _, params := range []Params{bcryptParams, argon2Params} {
+		t.Run(params.Algorithm, func(t *testing.T) {
+
+			hasher := newHasher(t, params)
+
+			hash, err := hasher.Hash("right password")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			if hash == "right password" || hasher.NeedsRehash(hash) {
+				t.Errorf("Hash = %q, want a hash of the params of the hasher", hash)
+			}
+
+			// salted
+			if other, _ := hasher.Hash("right password"); other == hash {
+				t.Error("two hashes of the same password are equal")
+			}
+
+			if ok, err := hasher.Verify("right password", hash); !ok || err != nil {
+				t.Errorf("Verify of the right password = %v, %v, want true", ok, err)
+			}
+
+			if ok, err := hasher.Verify("wrong password", hash); ok || err != nil {
+				t.Errorf("Verify of a wrong password = %v, %v, want false", ok, err)
+			}
+		})
+	}
+}
+
+func TestBcryptTakesAtMost72Bytes(t *testing.T) {
+
+	hasher := newHasher(t, bcryptParams)
+
+	exactly := strings.Repeat("a", BcryptMaxBytes)
+
+	hash, err := hasher.Hash(exactly)
+	if err != nil {
+		t.Fatalf("Hash of %d bytes: %v", len(exactly), err)
+	}
+
+	if ok, _ := hasher.Verify(exactly, hash); !ok {
+		t.Errorf("Verify of %d bytes failed", len(exactly))
+	}
+
+	// bcrypt would have ignored the last byte, so both passwords would have matched
+	if _, err := hasher.Hash(exactly + "b"); err != ErrTooLong {
+		t.Errorf("Hash of %d bytes = %v, want ErrTooLong", len(exactly)+1, err)
+	}
+
+	// bytes, not characters: 36 characters of 2 bytes fit, one more does not
+	if _, err := hasher.Hash(strings.Repeat("é", 36)); err != nil {
+		t.Errorf("Hash of 36 two-byte characters: %v", err)
+	}
+
+	if _, err := hasher.Hash(strings.Repeat("é", 36) + "a"); err != ErrTooLong {
+		t.Errorf("Hash of 73 bytes in 37 characters = %v, want ErrTooLong", err)
+	}
+
+	// argon2id takes any length, and tells the passwords past 72 bytes apart
+	argon2 := newHasher(t, argon2Params)
+
+	long, err := argon2.Hash(exactly + "b")
+	if err != nil {
+		t.Fatalf("argon2id Hash of %d bytes: %v", len(exactly)+1, err)
+	}
+
+	if ok, _ := argon2.Verify(exactly+"c", long); ok {
+		t.Error("argon2id Verify of a password sharing the first 72 bytes succeeded")
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+
+	if got := MaxBytes(Bcrypt); got != 72 {
+		t.Errorf("MaxBytes(bcrypt) = %d, want 72", got)
+	}
+
+	if got := MaxBytes(Argon2id); got != 0 {
+		t.Errorf("MaxBytes(argon2id) = %d, want no limit", got)
+	}
+}
+
+func TestVerifyTheHashesOfOtherParams(t *testing.T) {
+
+	bcryptHash, _ := newHasher(t, bcryptParams).Hash("right password")
+	argon2Hash, _ := newHasher(t, argon2Params).Hash("right password")
+
+	// the hasher of one algorithm verifies the hashes of the other, so that the algorithm can be switched
+	for _, params := range []Params{bcryptParams, argon2Params} {
+
+		hasher := newHasher(t, params)
+
+		for _, hash := range []string{bcryptHash, argon2Hash} {
+			if ok, err := hasher.Verify("right password", hash); !ok || err != nil {
+				t.Errorf("%s Verify of %s = %v, %v, want true", params.Algorithm, hash, ok, err)
+			}
+		}
+	}
+}
+
+func TestVerifyRefusesUnknownHashes(t *testing.T) {
+
+	hasher := newHasher(t, argon2Params)
+
+	for _, hash := range []string{
+		"right password",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$a2V5",
+	} {
+		if ok, err := hasher.Verify("right password", hash); ok || err != ErrUnknownHash {
+			t.Errorf("Verify of %q = %v, %v, want ErrUnknownHash", hash, ok, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+
+	bcryptHash, _ := newHasher(t, bcryptParams).Hash("right password")
+	argon2Hash, _ := newHasher(t, argon2Params).Hash("right password")
+
+	costlier := bcryptParams
+	costlier.BcryptCost++
+
+	moreMemory := argon2Params
+	moreMemory.Argon2Memory *= 2
+
+	moreTime := argon2Params
+	moreTime.Argon2Time++
+
+	moreThreads := argon2Params
+	moreThreads.Argon2Threads++
+
+	for _, tt := range []struct {
+		name   string
+		params Params
+		hash   string
+		want   bool
+	}{
+		{"same bcrypt cost", bcryptParams, bcryptHash, false},
+		{"higher bcrypt cost", costlier, bcryptHash, true},
+		{"bcrypt to argon2id", argon2Params, bcryptHash, true},
+		{"same argon2id params", argon2Params, argon2Hash, false},
+		{"argon2id to bcrypt", bcryptParams, argon2Hash, true},
+		{"more argon2id memory", moreMemory, argon2Hash, true},
+		{"more argon2id passes", moreTime, argon2Hash, true},
+		{"more argon2id threads", moreThreads, argon2Hash, true},
+		{"unknown hash", argon2Params, "right password", true},
+	} {
+		if got := newHasher(t, tt.params).NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
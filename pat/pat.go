@@ -0,0 +1,155 @@
+// Package pat makes and checks the personal access tokens, the long lived tokens the users create for
+// their bots and integrations. A token is random, only its hash is saved, and it carries a prefix that
+// tells it apart from the JWTs and makes a leaked token easy to scan for.
+package pat
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/revocation"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+// Prefix starts every personal access token
+const Prefix = "tcpat_"
+
+// ErrInvalid is returned for a token that is unknown or revoked
+var ErrInvalid = errors.New("pat: invalid personal access token")
+
+// ErrExpired is returned for a token past its expiry
+var ErrExpired = errors.New("pat: expired personal access token")
+
+// lastUsedInterval is how stale the last use of a token may get, so that a busy bot
+// does not cost a write on every request
+const lastUsedInterval = time.Minute
+
+// New returns a new token and its hash, the token is only shown to the user once
+func New() (token string, hash string, err error) {
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = Prefix + base64.RawURLEncoding.EncodeToString(b)
+
+	return token, Hash(token), nil
+}
+
+// Hash is the key of the token in the db
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Is reports whether the token looks like a personal access token rather than a JWT
+func Is(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// Verifier checks the personal access tokens against the store, the revocations of their user
+// and whether their user still exists
+type Verifier struct {
+	store       db.PersonalAccessTokenStore
+	users       db.UserStore
+	revocations revocation.Store
+	clock       clock.Clock
+	logger      *log.Logger
+}
+
+// NewVerifier returns a verifier using the wall clock and the standard logger when clk or logger are nil
+func NewVerifier(store db.PersonalAccessTokenStore, users db.UserStore, revocations revocation.Store, clk clock.Clock, logger *log.Logger) *Verifier {
+
+	if clk == nil {
+		clk = clock.Real()
+	}
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &Verifier{
+		store:       store,
+		users:       users,
+		revocations: revocations,
+		clock:       clk,
+		logger:      logger,
+	}
+}
+
+// Verify returns the token, ErrInvalid or ErrExpired when it can not be used, and records its use
+func (v *Verifier) Verify(ctx context.Context, token string) (*models.PersonalAccessToken, error) {
+
+	accessToken, err := v.store.GetPersonalAccessToken(ctx, Hash(token))
+	if err == db.ErrNotFound {
+		return nil, ErrInvalid
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := v.clock.Now()
+
+	if accessToken.Revoked_At != nil {
+		return nil, ErrInvalid
+	}
+
+	if accessToken.Expires_At != nil && !now.Before(*accessToken.Expires_At) {
+		return nil, ErrExpired
+	}
+
+	// logging out everywhere revokes the tokens created before, like the ones of a sign in
+	revoked, err := v.revocations.IsRevoked(ctx, "", accessToken.User_UUID, accessToken.Created_At)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, ErrInvalid
+	}
+
+	// the tokens of a deleted account are deleted along with it, this holds should that have failed
+	_, err = v.users.GetUserByUUID(ctx, accessToken.User_UUID)
+	if err == db.ErrNotFound {
+		return nil, ErrInvalid
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the request goes on when the last use can not be recorded
+	if accessToken.Last_Used_At == nil || now.Sub(*accessToken.Last_Used_At) >= lastUsedInterval {
+
+		err := v.store.SetPersonalAccessTokenLastUsed(ctx, accessToken.ID, now)
+		if err != nil {
+			v.logger.Println("Recording the use of the personal access token "+accessToken.ID+" failed:", err)
+		}
+
+		accessToken.Last_Used_At = &now
+	}
+
+	return accessToken, nil
+}
+
+// HasScope reports whether the token may do what the scope allows
+func HasScope(accessToken *models.PersonalAccessToken, scope string) bool {
+	for _, s := range accessToken.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
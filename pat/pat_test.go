@@ -0,0 +1,142 @@
+package pat
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/db/memory"
+	"github.com/Bruary/twitter-clone/revocation"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+type verifierTest struct {
+	verifier    *Verifier
+	store       *memory.Store
+	revocations revocation.Store
+	clock       *clock.Fake
+	user        *models.UserInfo
+}
+
+func newVerifierTest(t *testing.T) *verifierTest {
+
+	clk := clock.NewFake(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))
+	store := memory.New()
+	revocations := revocation.NewMemory(clk, 24*time.Hour)
+
+	user := &models.UserInfo{UUID: "user", Account_ID: "account", Email: "user@example.com", Created_At: clk.Now()}
+	if err := store.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	return &verifierTest{
+		verifier:    NewVerifier(store, store, revocations, clk, log.New(ioutil.Discard, "", 0)),
+		store:       store,
+		revocations: revocations,
+		clock:       clk,
+		user:        user,
+	}
+}
+
+// create saves a new token of the user and returns it
+func (vt *verifierTest) create(t *testing.T, expiresAt *time.Time) string {
+
+	token, hash, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = vt.store.CreatePersonalAccessToken(context.Background(), &models.PersonalAccessToken{
+		ID:         hash[:16],
+		User_UUID:  vt.user.UUID,
+		Account_ID: vt.user.Account_ID,
+		Name:       "bot",
+		Hash:       hash,
+		Scopes:     []string{models.ScopeTweetsWrite},
+		Created_At: vt.clock.Now(),
+		Expires_At: expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("CreatePersonalAccessToken: %v", err)
+	}
+
+	return token
+}
+
+func TestVerify(t *testing.T) {
+
+	ctx := context.Background()
+	vt := newVerifierTest(t)
+
+	token := vt.create(t, nil)
+
+	accessToken, err := vt.verifier.Verify(ctx, token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if accessToken.User_UUID != vt.user.UUID || accessToken.Last_Used_At == nil || !HasScope(accessToken, models.ScopeTweetsWrite) {
+		t.Errorf("Verify = %+v, want the token of the user with its use recorded", accessToken)
+	}
+
+	if _, err := vt.verifier.Verify(ctx, Prefix+"unknown"); err != ErrInvalid {
+		t.Errorf("Verify of an unknown token = %v, want ErrInvalid", err)
+	}
+}
+
+func TestVerifyRefusesExpiredTokens(t *testing.T) {
+
+	vt := newVerifierTest(t)
+
+	expiresAt := vt.clock.Now().Add(time.Hour)
+	token := vt.create(t, &expiresAt)
+
+	vt.clock.Advance(time.Hour)
+
+	if _, err := vt.verifier.Verify(context.Background(), token); err != ErrExpired {
+		t.Errorf("Verify of an expired token = %v, want ErrExpired", err)
+	}
+}
+
+func TestVerifyChecksTheRevocationsOfTheUser(t *testing.T) {
+
+	ctx := context.Background()
+	vt := newVerifierTest(t)
+
+	before := vt.create(t, nil)
+
+	vt.clock.Advance(time.Millisecond)
+	if err := vt.revocations.RevokeUser(ctx, vt.user.UUID, vt.clock.Now()); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+
+	if _, err := vt.verifier.Verify(ctx, before); err != ErrInvalid {
+		t.Errorf("Verify of a token created before the user was revoked = %v, want ErrInvalid", err)
+	}
+
+	after := vt.create(t, nil)
+
+	if _, err := vt.verifier.Verify(ctx, after); err != nil {
+		t.Errorf("Verify of a token created since the user was revoked: %v", err)
+	}
+}
+
+func TestVerifyRefusesTheTokensOfADeletedUser(t *testing.T) {
+
+	ctx := context.Background()
+	vt := newVerifierTest(t)
+
+	token := vt.create(t, nil)
+
+	// the tokens were left behind
+	if err := vt.store.DeleteUser(ctx, vt.user.UUID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if _, err := vt.verifier.Verify(ctx, token); err != ErrInvalid {
+		t.Errorf("Verify of a token of a deleted user = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,169 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+// UserDrift is a user whose stored metrics differ from the ones computed from the follows and the tweets
+type UserDrift struct {
+	UserUUID  string
+	AccountID string
+	Stored    models.UserMetrics
+	Actual    models.UserMetrics
+	Fixed     bool
+}
+
+// TweetDrift is a tweet whose stored metrics differ from the ones computed from the tweet
+type TweetDrift struct {
+	TweetUUID string
+	AccountID string
+	Stored    models.TweetMetrics
+	Actual    models.TweetMetrics
+	Fixed     bool
+}
+
+// Report is the outcome of a reconciliation run
+type Report struct {
+	UsersChecked  int
+	TweetsChecked int
+	Users         []UserDrift
+	Tweets        []TweetDrift
+}
+
+// Run recomputes every counter from the documents they count and reports the ones that drifted.
+// With fix set the drifted counters are overwritten, unless they changed while they were being
+// recomputed, in which case they are left for the next run.
+//
+// Only the characters count of a tweet can be recomputed, there is nothing to count
+// retweets, likes and comments from.
+func Run(ctx context.Context, store db.MetricsStore, fix bool) (*Report, error) {
+	report := &Report{}
+
+	err := store.ForEachUser(ctx, func(user *models.UserInfo) error {
+		report.UsersChecked++
+
+		actual, err := store.CountUserMetrics(ctx, user)
+		if err != nil {
+			return fmt.Errorf("counting the metrics of %s: %w", user.Account_ID, err)
+		}
+
+		if actual == user.Metrics {
+			return nil
+		}
+
+		drift := UserDrift{
+			UserUUID:  user.UUID,
+			AccountID: user.Account_ID,
+			Stored:    user.Metrics,
+			Actual:    actual,
+		}
+
+		if fix {
+			drift.Fixed, err = store.SetUserMetrics(ctx, user.UUID, user.Metrics, actual)
+			if err != nil {
+				return fmt.Errorf("fixing the metrics of %s: %w", user.Account_ID, err)
+			}
+		}
+
+		report.Users = append(report.Users, drift)
+
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	err = store.ForEachTweet(ctx, func(tweet *models.TweetDB) error {
+		report.TweetsChecked++
+
+		actual := tweet.Metrics
+		actual.Characters_count = len(tweet.Tweet)
+
+		if actual == tweet.Metrics {
+			return nil
+		}
+
+		drift := TweetDrift{
+			TweetUUID: tweet.Tweet_UUID,
+			AccountID: tweet.Account_ID,
+			Stored:    tweet.Metrics,
+			Actual:    actual,
+		}
+
+		if fix {
+			var err error
+
+			drift.Fixed, err = store.SetTweetMetrics(ctx, tweet.Tweet_UUID, tweet.Metrics, actual)
+			if err != nil {
+				return fmt.Errorf("fixing the metrics of tweet %s: %w", tweet.Tweet_UUID, err)
+			}
+		}
+
+		report.Tweets = append(report.Tweets, drift)
+
+		return nil
+	})
+
+	return report, err
+}
+
+// Print writes the drift of every account and tweet followed by a summary
+func (r *Report) Print(w io.Writer) {
+	for _, d := range r.Users {
+		fmt.Fprintf(w, "account %s: followers %d -> %d, following %d -> %d, tweets %d -> %d, retweets %d -> %d, likes %d -> %d%s\n",
+			d.AccountID,
+			d.Stored.Followers_count, d.Actual.Followers_count,
+			d.Stored.Following_count, d.Actual.Following_count,
+			d.Stored.Total_tweets_count, d.Actual.Total_tweets_count,
+			d.Stored.Total_retweets_count, d.Actual.Total_retweets_count,
+			d.Stored.Total_likes_count, d.Actual.Total_likes_count,
+			fixedSuffix(d.Fixed))
+	}
+
+	for _, d := range r.Tweets {
+		fmt.Fprintf(w, "tweet %s of account %s: characters %d -> %d%s\n",
+			d.TweetUUID, d.AccountID, d.Stored.Characters_count, d.Actual.Characters_count, fixedSuffix(d.Fixed))
+	}
+
+	fmt.Fprintf(w, "Checked %d accounts and %d tweets, %d accounts and %d tweets drifted.\n",
+		r.UsersChecked, r.TweetsChecked, len(r.Users), len(r.Tweets))
+}
+
+func fixedSuffix(fixed bool) string {
+	if fixed {
+		return " (fixed)"
+	}
+
+	return ""
+}
+
+// Schedule runs the reconciliation every interval until ctx is canceled and prints the reports to w.
+// It blocks, run it in its own goroutine.
+func Schedule(ctx context.Context, store db.MetricsStore, interval time.Duration, fix bool, w io.Writer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := Run(ctx, store, fix)
+		if err != nil {
+			fmt.Fprintln(w, "Metrics reconciliation failed:", err)
+			continue
+		}
+
+		if len(report.Users) > 0 || len(report.Tweets) > 0 {
+			report.Print(w)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package revocation
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type fallbackStore struct {
+	primary  Store
+	fallback Store
+	logger   *log.Logger
+
+	mu            sync.Mutex
+	pendingTokens map[string]time.Time // the revocations primary failed, replayed once it answers again
+	pendingUsers  map[string]time.Time
+}
+
+// NewFallback returns a store writing the revocations to primary, typically Redis, and to fallback,
+// typically in-process, and checking both. While primary is unreachable the tokens are checked against
+// the revocations made by this instance instead of failing every request, the revocations made meanwhile
+// are written to primary once it answers again. The failures of primary are logged to logger.
+func NewFallback(primary Store, fallback Store, logger *log.Logger) Store {
+	return &fallbackStore{
+		primary:       primary,
+		fallback:      fallback,
+		logger:        logger,
+		pendingTokens: map[string]time.Time{},
+		pendingUsers:  map[string]time.Time{},
+	}
+}
+
+func (f *fallbackStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+
+	err := f.primary.RevokeToken(ctx, tokenID, expiresAt)
+	err2 := f.fallback.RevokeToken(ctx, tokenID, expiresAt)
+
+	if f.failed(ctx, err) {
+		f.mu.Lock()
+		f.pendingTokens[tokenID] = expiresAt
+		f.mu.Unlock()
+
+		return err2
+	}
+
+	if err == nil {
+		f.replay(ctx)
+	}
+
+	return err
+}
+
+func (f *fallbackStore) RevokeUser(ctx context.Context, userUUID string, issuedBefore time.Time) error {
+
+	err := f.primary.RevokeUser(ctx, userUUID, issuedBefore)
+	err2 := f.fallback.RevokeUser(ctx, userUUID, issuedBefore)
+
+	if f.failed(ctx, err) {
+		f.mu.Lock()
+		if pending, found := f.pendingUsers[userUUID]; !found || issuedBefore.After(pending) {
+			f.pendingUsers[userUUID] = issuedBefore
+		}
+		f.mu.Unlock()
+
+		return err2
+	}
+
+	if err == nil {
+		f.replay(ctx)
+	}
+
+	return err
+}
+
+func (f *fallbackStore) IsRevoked(ctx context.Context, tokenID string, userUUID string, issuedAt time.Time) (bool, error) {
+
+	// the revocations made while primary was unreachable are only in the fallback until they are replayed
+	revoked, err := f.fallback.IsRevoked(ctx, tokenID, userUUID, issuedAt)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	revoked, err = f.primary.IsRevoked(ctx, tokenID, userUUID, issuedAt)
+	if f.failed(ctx, err) {
+		return false, nil
+	}
+
+	if err == nil {
+		f.replay(ctx)
+	}
+
+	return revoked, err
+}
+
+// replay writes the revocations primary failed to it, it stops at the first failure
+// and leaves the rest for the next call
+func (f *fallbackStore) replay(ctx context.Context) {
+
+	f.mu.Lock()
+	if len(f.pendingTokens) == 0 && len(f.pendingUsers) == 0 {
+		f.mu.Unlock()
+		return
+	}
+
+	tokens := make(map[string]time.Time, len(f.pendingTokens))
+	for tokenID, expiresAt := range f.pendingTokens {
+		tokens[tokenID] = expiresAt
+	}
+
+	users := make(map[string]time.Time, len(f.pendingUsers))
+	for userUUID, issuedBefore := range f.pendingUsers {
+		users[userUUID] = issuedBefore
+	}
+	f.mu.Unlock()
+
+	for tokenID, expiresAt := range tokens {
+		if err := f.primary.RevokeToken(ctx, tokenID, expiresAt); err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		delete(f.pendingTokens, tokenID)
+		f.mu.Unlock()
+	}
+
+	for userUUID, issuedBefore := range users {
+		if err := f.primary.RevokeUser(ctx, userUUID, issuedBefore); err != nil {
+			return
+		}
+
+		// a later revocation made in the meantime is replayed on its own
+		f.mu.Lock()
+		if f.pendingUsers[userUUID].Equal(issuedBefore) {
+			delete(f.pendingUsers, userUUID)
+		}
+		f.mu.Unlock()
+	}
+
+	f.logger.Println("revocation: primary store is back, replayed the revocations made while it was unreachable")
+}
+
+// failed reports whether primary failed and the call should go to the fallback,
+// a canceled request is no reason to
+func (f *fallbackStore) failed(ctx context.Context, err error) bool {
+
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	f.logger.Println("revocation: primary store failed, using the fallback:", err)
+
+	return true
+}
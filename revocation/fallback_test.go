@@ -0,0 +1,102 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+// flaky is a store failing every call while down is set
+type flaky struct {
+	Store
+	down bool
+}
+
+var errDown = errors.New("down")
+
+func (f *flaky) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if f.down {
+		return errDown
+	}
+
+	return f.Store.RevokeToken(ctx, tokenID, expiresAt)
+}
+
+func (f *flaky) RevokeUser(ctx context.Context, userUUID string, issuedBefore time.Time) error {
+	if f.down {
+		return errDown
+	}
+
+	return f.Store.RevokeUser(ctx, userUUID, issuedBefore)
+}
+
+func (f *flaky) IsRevoked(ctx context.Context, tokenID string, userUUID string, issuedAt time.Time) (bool, error) {
+	if f.down {
+		return false, errDown
+	}
+
+	return f.Store.IsRevoked(ctx, tokenID, userUUID, issuedAt)
+}
+
+func TestFallbackChecksTheLocalRevocationsWhilePrimaryIsDown(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(start)
+	primary := &flaky{Store: NewMemory(clk, time.Hour)}
+	store := NewFallback(primary, NewMemory(clk, time.Hour), log.New(ioutil.Discard, "", 0))
+
+	primary.down = true
+
+	if err := store.RevokeUser(ctx, "user", start); err != nil {
+		t.Fatalf("RevokeUser while primary is down: %v", err)
+	}
+
+	if err := store.RevokeToken(ctx, "token", start.Add(time.Minute)); err != nil {
+		t.Fatalf("RevokeToken while primary is down: %v", err)
+	}
+
+	revoked, err := store.IsRevoked(ctx, "", "user", start.Add(-time.Second))
+	if err != nil || !revoked {
+		t.Errorf("IsRevoked of a user revoked while primary is down = %v, %v, want revoked", revoked, err)
+	}
+
+	revoked, err = store.IsRevoked(ctx, "", "other", start.Add(-time.Second))
+	if err != nil || revoked {
+		t.Errorf("IsRevoked while primary is down = %v, %v, want the token to work", revoked, err)
+	}
+
+	// once primary is back it gets the revocations made meanwhile
+	primary.down = false
+
+	if _, err := store.IsRevoked(ctx, "", "other", start); err != nil {
+		t.Fatalf("IsRevoked: %v", err)
+	}
+
+	if revoked, _ := primary.IsRevoked(ctx, "", "user", start.Add(-time.Second)); !revoked {
+		t.Error("the revocation of the user was not replayed to primary")
+	}
+
+	if revoked, _ := primary.IsRevoked(ctx, "token", "someone", start); !revoked {
+		t.Error("the revocation of the token was not replayed to primary")
+	}
+}
+
+func TestFallbackChecksPrimary(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(start)
+	primary := &flaky{Store: NewMemory(clk, time.Hour)}
+	store := NewFallback(primary, NewMemory(clk, time.Hour), log.New(ioutil.Discard, "", 0))
+
+	// revoked by another instance
+	primary.RevokeUser(ctx, "user", start)
+
+	if revoked, err := store.IsRevoked(ctx, "", "user", start.Add(-time.Second)); err != nil || !revoked {
+		t.Errorf("IsRevoked of a user revoked in primary = %v, %v, want revoked", revoked, err)
+	}
+}
@@ -0,0 +1,97 @@
+package revocation
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type redisStore struct {
+	client  *redis.Client
+	timeout time.Duration
+	ttl     time.Duration
+}
+
+// NewRedis returns a store shared by every instance, the keys expire with the tokens they revoke
+// and the revocations of a user are kept for ttl, which must be the lifetime of the longest lived token.
+// Every call is bounded by timeout unless it is 0.
+func NewRedis(client *redis.Client, timeout time.Duration, ttl time.Duration) Store {
+	return &redisStore{
+		client:  client,
+		timeout: timeout,
+		ttl:     ttl,
+	}
+}
+
+func tokenKey(tokenID string) string {
+	return "REVOKED_TOKEN:" + tokenID
+}
+
+func userKey(userUUID string) string {
+	return "REVOKED_USER:" + userUUID
+}
+
+// revokeUserScript sets the revocation of a user, in unix milliseconds, unless the one it holds reaches further
+var revokeUserScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current and tonumber(current) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+func (r *redisStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// expired already
+		return nil
+	}
+
+	return r.client.Set(ctx, tokenKey(tokenID), 1, ttl).Err()
+}
+
+func (r *redisStore) RevokeUser(ctx context.Context, userUUID string, issuedBefore time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return revokeUserScript.Run(ctx, r.client, []string{userKey(userUUID)}, unixMilli(issuedBefore), r.ttl.Milliseconds()).Err()
+}
+
+func (r *redisStore) IsRevoked(ctx context.Context, tokenID string, userUUID string, issuedAt time.Time) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	values, err := r.client.MGet(ctx, tokenKey(tokenID), userKey(userUUID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if values[0] != nil && tokenID != "" {
+		return true, nil
+	}
+
+	if issuedBefore, ok := values[1].(string); ok {
+		before, err := strconv.ParseInt(issuedBefore, 10, 64)
+		if err != nil {
+			return false, err
+		}
+
+		return unixMilli(issuedAt) < before, nil
+	}
+
+	return false, nil
+}
+
+func (r *redisStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, r.timeout)
+}
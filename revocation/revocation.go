@@ -0,0 +1,119 @@
+// Package revocation keeps track of the tokens revoked before they expire, by logging out,
+// by logging out everywhere, or by a change of password or the deletion of the account
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+// Store records the revoked tokens until they expire on their own
+type Store interface {
+
+	// RevokeToken revokes the token with the given ID until it expires
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+
+	// RevokeUser revokes every token of the user issued before issuedBefore, to the millisecond,
+	// so that the tokens issued right after it still work. An earlier revocation of the user
+	// reaching further keeps its time.
+	RevokeUser(ctx context.Context, userUUID string, issuedBefore time.Time) error
+
+	// IsRevoked reports whether the token was revoked, by its ID or with every token of the user.
+	// An empty tokenID only checks the user.
+	IsRevoked(ctx context.Context, tokenID string, userUUID string, issuedAt time.Time) (bool, error)
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time // token ID to its expiry
+	users  map[string]userRevocation
+	clock  clock.Clock
+	ttl    time.Duration
+}
+
+type userRevocation struct {
+	issuedBefore int64 // unix milliseconds
+	expiresAt    time.Time
+}
+
+// NewMemory returns an in-process store for a single instance, the revocations of a user are kept
+// for ttl, which must be the lifetime of the longest lived token
+func NewMemory(clk clock.Clock, ttl time.Duration) Store {
+	return &memoryStore{
+		tokens: map[string]time.Time{},
+		users:  map[string]userRevocation{},
+		clock:  clk,
+		ttl:    ttl,
+	}
+}
+
+func (m *memoryStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeExpired()
+	m.tokens[tokenID] = expiresAt
+
+	return nil
+}
+
+func (m *memoryStore) RevokeUser(ctx context.Context, userUUID string, issuedBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeExpired()
+
+	if user, found := m.users[userUUID]; found && user.issuedBefore >= unixMilli(issuedBefore) {
+		return nil
+	}
+
+	m.users[userUUID] = userRevocation{
+		issuedBefore: unixMilli(issuedBefore),
+		expiresAt:    m.clock.Now().Add(m.ttl),
+	}
+
+	return nil
+}
+
+func (m *memoryStore) IsRevoked(ctx context.Context, tokenID string, userUUID string, issuedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+
+	if expiresAt, found := m.tokens[tokenID]; found && tokenID != "" && now.Before(expiresAt) {
+		return true, nil
+	}
+
+	user, found := m.users[userUUID]
+	if found && now.Before(user.expiresAt) && unixMilli(issuedAt) < user.issuedBefore {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// removeExpired drops the revocations that outlived their tokens, the caller must hold the lock
+func (m *memoryStore) removeExpired() {
+	now := m.clock.Now()
+
+	for tokenID, expiresAt := range m.tokens {
+		if !now.Before(expiresAt) {
+			delete(m.tokens, tokenID)
+		}
+	}
+
+	for userUUID, user := range m.users {
+		if !now.Before(user.expiresAt) {
+			delete(m.users, userUUID)
+		}
+	}
+}
+
+// unixMilli is the precision the revocations of the users are kept with
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,83 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+)
+
+var start = time.Date(2026, 10, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+
+func TestRevokeUserKeepsTheTokensIssuedRightAfter(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(start)
+	store := NewMemory(clk, time.Hour)
+
+	if err := store.RevokeUser(ctx, "user", clk.Now()); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"earlier second", start.Add(-time.Second), true},
+		{"same second, before", start.Add(-100 * time.Millisecond), true},
+		{"same millisecond", start, false},
+		{"same second, after", start.Add(100 * time.Millisecond), false},
+		{"next second", start.Add(time.Second), false},
+	} {
+		revoked, err := store.IsRevoked(ctx, "", "user", tt.issuedAt)
+		if err != nil || revoked != tt.revoked {
+			t.Errorf("%s: IsRevoked = %v, %v, want %v", tt.name, revoked, err, tt.revoked)
+		}
+	}
+
+	if revoked, _ := store.IsRevoked(ctx, "", "other", start.Add(-time.Second)); revoked {
+		t.Error("the tokens of another user are revoked")
+	}
+}
+
+func TestRevokeUserKeepsTheLatestRevocation(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(start)
+	store := NewMemory(clk, time.Hour)
+
+	store.RevokeUser(ctx, "user", start)
+	store.RevokeUser(ctx, "user", start.Add(-time.Minute))
+
+	if revoked, _ := store.IsRevoked(ctx, "", "user", start.Add(-time.Second)); !revoked {
+		t.Error("an earlier revocation undid a later one")
+	}
+}
+
+func TestRevocationsExpire(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(start)
+	store := NewMemory(clk, time.Hour)
+
+	store.RevokeToken(ctx, "token", start.Add(time.Minute))
+	store.RevokeUser(ctx, "user", start)
+
+	if revoked, _ := store.IsRevoked(ctx, "token", "someone", start); !revoked {
+		t.Error("the revoked token is not revoked")
+	}
+
+	clk.Advance(time.Minute)
+
+	if revoked, _ := store.IsRevoked(ctx, "token", "someone", start); revoked {
+		t.Error("the revocation of the token outlived the token")
+	}
+
+	clk.Advance(time.Hour)
+
+	if revoked, _ := store.IsRevoked(ctx, "", "user", start.Add(-time.Second)); revoked {
+		t.Error("the revocation of the user outlived its ttl")
+	}
+}
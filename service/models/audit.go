@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// the types of the audit events
+const (
+	AuditPasswordResetRequested = "password_reset_requested"
+	AuditPasswordResetRejected  = "password_reset_rejected" // an expired, used or invalidated reset token was presented
+	AuditPasswordResetCompleted = "password_reset_completed"
+	AuditSessionsRevoked        = "sessions_revoked"
+	AuditEmailVerified          = "email_verified"
+	AuditEmailVerifiedByAdmin   = "email_verified_by_admin"
+	AuditTwoFactorEnabled       = "two_factor_enabled"
+	AuditTwoFactorDisabled      = "two_factor_disabled"
+	AuditRecoveryCodeUsed       = "recovery_code_used"
+	AuditPasswordChanged        = "password_changed"
+	AuditAccountLocked          = "account_locked"
+	AuditAccountUnlockedByAdmin = "account_unlocked_by_admin"
+	AuditIdentityLinked         = "identity_linked" // an OIDC provider account was linked to the account
+	AuditAccessTokenCreated     = "personal_access_token_created"
+	AuditAccessTokenRevoked     = "personal_access_token_revoked"
+)
+
+// AuditEvent records a security event of an account
+type AuditEvent struct {
+	ID         string
+	User_UUID  string
+	Type       string
+	IP         string // the address the request came from
+	Created_At time.Time
+}
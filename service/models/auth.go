@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	"github.com/Bruary/twitter-clone/validate"
+)
+
 type SignInRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -7,7 +13,46 @@ type SignInRequest struct {
 
 type SignInResponse struct {
 	BaseResponse
-	Token string `json:"token,omitempty"`
+	Token        string `json:"token,omitempty"`         // short lived access token
+	ExpiresIn    int    `json:"expires_in,omitempty"`    // lifetime of the access token in seconds
+	RefreshToken string `json:"refresh_token,omitempty"` // exchanged for new tokens at /auth/refresh
+
+	// ChallengeToken replaces the tokens when the account has two-factor authentication,
+	// it is sent to /auth/2fa/signin along with a code
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"` // optional, its family is revoked along with the access token
+}
+
+// RefreshToken is saved server-side, only the hash of the token handed to the client is kept.
+// Every refresh replaces the token with a new one of the same family, presenting a token
+// that was already used revokes the whole family.
+type RefreshToken struct {
+	Hash       string
+	Family_ID  string
+	User_UUID  string
+	Account_ID string
+	Created_At time.Time
+	Expires_At time.Time
+	Used       bool
+	Revoked    bool
+}
+
+// PasswordReset is saved server-side, only the hash of the token sent in the email is kept.
+// A reset works once, and requesting a new one invalidates the earlier ones.
+type PasswordReset struct {
+	Hash        string
+	User_UUID   string
+	Created_At  time.Time
+	Expires_At  time.Time
+	Used        bool
+	Invalidated bool
 }
 
 type ResetPasswordRequest struct {
@@ -18,3 +63,15 @@ type SetNewPasswordRequest struct {
 	BaseRequest
 	Password string `json:"password"`
 }
+
+// PasswordResponse is the response of the routes setting a password, Violations tells why it was refused
+type PasswordResponse struct {
+	BaseResponse
+	Violations []validate.PasswordViolation `json:"violations,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	BaseRequest
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
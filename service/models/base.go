@@ -1,10 +1,14 @@
 package models
 
 import (
+	"time"
+
 	"github.com/dgrijalva/jwt-go"
 )
 
 type BaseRequest struct {
+	// the reset token on the password routes, on the routes needing an account it is
+	// the deprecated fallback of the Authorization header
 	Token string `json:"token"`
 }
 
@@ -14,8 +18,37 @@ type BaseResponse struct {
 	Msg          string `json:"Msg,omitempty"`
 }
 
+// TokenPurpose tells what a token was issued for, every endpoint only accepts the tokens of its purpose
+type TokenPurpose string
+
+const (
+	PurposeAccess        TokenPurpose = "access"         // the tokens of the routes needing an account
+	PurposeRefresh       TokenPurpose = "refresh"        // the refresh tokens are opaque, no JWT is accepted in their place
+	PurposePasswordReset TokenPurpose = "password-reset" // the reset links are opaque too, no JWT is accepted in their place
+	PurposeEmailVerify   TokenPurpose = "email-verify"   // the links verifying the email of an account
+	PurposeTwoFactor     TokenPurpose = "2fa-challenge"  // the second step of the sign in, once the password checked out
+)
+
 type Claims struct {
 	User_UUID  string
 	Account_ID string
+	Purpose    TokenPurpose `json:"purpose"`
 	jwt.StandardClaims
+
+	// Issued_At_Ms is the issue time in milliseconds, iat only has seconds which is too coarse
+	// to tell a token issued right after a revocation from one issued right before it
+	Issued_At_Ms int64 `json:"iat_ms,omitempty"`
+
+	// Scopes are what a personal access token may do, they are nil for the tokens of a sign in
+	// which may do everything
+	Scopes []string `json:"-"`
+}
+
+// IssuedAtTime is when the token was issued, to the millisecond when the token has iat_ms
+func (c *Claims) IssuedAtTime() time.Time {
+	if c.Issued_At_Ms != 0 {
+		return time.Unix(0, c.Issued_At_Ms*int64(time.Millisecond))
+	}
+
+	return time.Unix(c.IssuedAt, 0)
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Identity links the account of a user at an OIDC provider to the user,
+// the provider and the subject identify it since the email at the provider can change
+type Identity struct {
+	Provider   string    `bson:"provider"`
+	Subject    string    `bson:"subject"`
+	User_UUID  string    `bson:"user_uuid"`
+	Created_At time.Time `bson:"created_at"`
+}
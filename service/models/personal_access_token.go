@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// the scopes of the personal access tokens, a token only reaches the routes of its scopes
+const (
+	ScopeTweetsRead   = "tweets:read"
+	ScopeTweetsWrite  = "tweets:write"
+	ScopeFeedRead     = "feed:read"
+	ScopeFollowsWrite = "follows:write"
+)
+
+// Scopes lists every scope a personal access token can have
+var Scopes = []string{ScopeTweetsRead, ScopeTweetsWrite, ScopeFeedRead, ScopeFollowsWrite}
+
+// PersonalAccessToken is a long lived token a user creates for a bot or an integration,
+// only the hash of the token is kept
+type PersonalAccessToken struct {
+	ID           string     `json:"id" bson:"id"`
+	User_UUID    string     `json:"-" bson:"user_uuid"`
+	Account_ID   string     `json:"-" bson:"account_id"`
+	Name         string     `json:"name" bson:"name"`
+	Hash         string     `json:"-" bson:"hash"`
+	Scopes       []string   `json:"scopes" bson:"scopes"`
+	Created_At   time.Time  `json:"created_at" bson:"created_at"`
+	Expires_At   *time.Time `json:"expires_at" bson:"expires_at"` // nil when it never expires
+	Last_Used_At *time.Time `json:"last_used_at" bson:"last_used_at"`
+	Revoked_At   *time.Time `json:"revoked_at" bson:"revoked_at"`
+}
+
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 for a token that never expires
+}
+
+type RevokePersonalAccessTokenRequest struct {
+	ID string `json:"id"`
+}
+
+type PersonalAccessTokenResponse struct {
+	BaseResponse
+	Token               string               `json:"token,omitempty"` // only shown once
+	PersonalAccessToken *PersonalAccessToken `json:"personal_access_token,omitempty"`
+}
+
+type PersonalAccessTokensResponse struct {
+	BaseResponse
+	PersonalAccessTokens []PersonalAccessToken `json:"personal_access_tokens,omitempty"`
+}
@@ -29,7 +29,7 @@ type TweetMetrics struct {
 
 type CreateTweetRequest struct {
 	Tweet string `json:"tweet"`
-	Token string `json:"token"`
+	Token string `json:"token"` // Deprecated: send the token in the Authorization header
 }
 
 type GetTweetsResponse struct {
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// TwoFactor is the TOTP two-factor authentication of a user. It is pending until the first code
+// is confirmed, only then Enabled_At is set and the sign in asks for a code.
+type TwoFactor struct {
+	User_UUID      string         `bson:"user_uuid"`
+	Secret         string         `bson:"secret"`
+	Enabled_At     *time.Time     `bson:"enabled_at"`
+	Last_Step      int64          `bson:"last_step"` // the step of the last accepted code, a code works once
+	Recovery_Codes []RecoveryCode `bson:"recovery_codes"`
+}
+
+// RecoveryCode signs in in place of a code when the authenticator is lost, it works once.
+// Only its hash is saved.
+type RecoveryCode struct {
+	Hash string `bson:"hash"`
+	Used bool   `bson:"used"`
+}
+
+type TwoFactorEnrollResponse struct {
+	BaseResponse
+	Secret     string `json:"secret,omitempty"`
+	OTPAuthURI string `json:"otpauth_uri,omitempty"` // shown as a QR code for the authenticator apps
+}
+
+type TwoFactorConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorConfirmResponse struct {
+	BaseResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"` // shown once, only their hashes are saved
+}
+
+// TwoFactorSignInRequest is the second step of the sign in, with either a code or a recovery code
+type TwoFactorSignInRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// TwoFactorDisableRequest asks for the password again along with a code or a recovery code
+type TwoFactorDisableRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
@@ -22,6 +22,9 @@ type UserInfo struct {
 	Metrics    UserMetrics `json:"-"`
 	Created_At time.Time   `json:"created_at" bson:"created_at"`
 	Updated_At time.Time   `json:"updates_at" bson:"updated_at"`
+
+	// Verified_At is nil until the email of the account is verified
+	Verified_At *time.Time `json:"verified_at" bson:"verified_at"`
 }
 
 type UserMetrics struct {
@@ -33,12 +36,20 @@ type UserMetrics struct {
 }
 
 type DeleteUserRequest struct {
-	Token string `json:"token"`
+	Token string `json:"token"` // Deprecated: send the token in the Authorization header
+}
+
+type AdminVerifyEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type AdminUnlockUserRequest struct {
+	Email string `json:"email"`
 }
 
 type FollowRequest struct {
 	Following_Account_ID string `json:"following_account_id" bson:"following_account_id"`
-	Token                string `json:"token"`
+	Token                string `json:"token"` // Deprecated: send the token in the Authorization header
 }
 
 type Followers struct {
@@ -6,8 +6,11 @@ import (
 )
 
 type Service interface {
-	CreateUser(*fiber.Ctx, models.CreateUserRequest) *models.BaseResponse
+	CreateUser(*fiber.Ctx, models.CreateUserRequest) *models.PasswordResponse
 	SignIn(*fiber.Ctx, models.SignInRequest) *models.SignInResponse
+	Refresh(*fiber.Ctx, models.RefreshRequest) *models.SignInResponse
+	Logout(*fiber.Ctx, models.LogoutRequest) *models.BaseResponse
+	LogoutAll(*fiber.Ctx, models.BaseRequest) *models.BaseResponse
 	DeleteUser(*fiber.Ctx, models.DeleteUserRequest) *models.BaseResponse
 	CreateTweet(*fiber.Ctx, models.CreateTweetRequest) *models.BaseResponse
 	GetTweets(*fiber.Ctx, models.BaseRequest) *models.GetTweetsResponse
@@ -15,5 +18,19 @@ type Service interface {
 	Feed(*fiber.Ctx, models.BaseRequest) *models.FeedResponse
 	ResetPassword(*fiber.Ctx, models.ResetPasswordRequest) *models.BaseResponse
 	NewPassword(*fiber.Ctx) *models.BaseResponse
-	SetNewPassword(*fiber.Ctx, models.SetNewPasswordRequest) *models.BaseResponse
+	SetNewPassword(*fiber.Ctx, models.SetNewPasswordRequest) *models.PasswordResponse
+	ChangePassword(*fiber.Ctx, models.ChangePasswordRequest) *models.PasswordResponse
+	VerifyEmail(*fiber.Ctx) *models.BaseResponse
+	ResendVerificationEmail(*fiber.Ctx, models.BaseRequest) *models.BaseResponse
+	AdminVerifyEmail(*fiber.Ctx, models.AdminVerifyEmailRequest) *models.BaseResponse
+	AdminUnlockUser(*fiber.Ctx, models.AdminUnlockUserRequest) *models.BaseResponse
+	EnrollTwoFactor(*fiber.Ctx, models.BaseRequest) *models.TwoFactorEnrollResponse
+	ConfirmTwoFactor(*fiber.Ctx, models.TwoFactorConfirmRequest) *models.TwoFactorConfirmResponse
+	TwoFactorSignIn(*fiber.Ctx, models.TwoFactorSignInRequest) *models.SignInResponse
+	DisableTwoFactor(*fiber.Ctx, models.TwoFactorDisableRequest) *models.BaseResponse
+	OIDCLogin(*fiber.Ctx) *models.BaseResponse
+	OIDCCallback(*fiber.Ctx) *models.SignInResponse
+	CreatePersonalAccessToken(*fiber.Ctx, models.CreatePersonalAccessTokenRequest) *models.PersonalAccessTokenResponse
+	ListPersonalAccessTokens(*fiber.Ctx, models.BaseRequest) *models.PersonalAccessTokensResponse
+	RevokePersonalAccessToken(*fiber.Ctx, models.RevokePersonalAccessTokenRequest) *models.BaseResponse
 }
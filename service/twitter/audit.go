@@ -0,0 +1,21 @@
+package twitter
+
+import (
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// recordAudit records a security event of the user, a failure to record it is logged but does not fail the request
+func (s *twitterClone) recordAudit(c *fiber.Ctx, userUUID string, eventType string) {
+
+	err := s.audit.CreateAuditEvent(c.UserContext(), &models.AuditEvent{
+		ID:         s.ids.NewUUID(),
+		User_UUID:  userUUID,
+		Type:       eventType,
+		IP:         c.IP(),
+		Created_At: s.clock.Now(),
+	})
+	if err != nil {
+		s.logger.Println("Recording the audit event "+eventType+" of user "+userUUID+" failed:", err)
+	}
+}
@@ -0,0 +1,125 @@
+package twitter
+
+import (
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/validate"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChangePassword replaces the password of the user, who must know the current one,
+// and signs out every session including this one
+func (s *twitterClone) ChangePassword(c *fiber.Ctx, req models.ChangePasswordRequest) *models.PasswordResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	currentPasswordEmpty := validate.IsStringEmpty(req.CurrentPassword)
+	if currentPasswordEmpty {
+
+		c.Status(fiber.ErrBadRequest.Code)
+
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field current_password is missing, or empty.",
+			},
+		}
+	}
+
+	user, err := s.users.GetUserByUUID(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.PasswordResponse{BaseResponse: *resp}
+	}
+
+	if err != nil {
+
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Failed while finding user in db.",
+			},
+		}
+	}
+
+	// the current password is guessed against the same limits as the sign ins
+	attempt, refused := s.reserveSignIn(c, user.Email)
+	if refused != nil {
+		return &models.PasswordResponse{BaseResponse: refused.BaseResponse}
+	}
+
+	isPasswordCorrect := s.doPasswordsMatch(req.CurrentPassword, user.Password)
+	if !isPasswordCorrect {
+
+		s.signInFailed(c, attempt, user)
+
+		c.Status(fiber.StatusUnauthorized)
+
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "INVALID_CREDENTIALS",
+				Msg:          "Invalid password.",
+			},
+		}
+	}
+
+	s.signInSucceeded(c, attempt)
+
+	if resp := s.checkPasswordPolicy(c, req.NewPassword, user.Email, user.FirstName, user.LastName); resp != nil {
+		return resp
+	}
+
+	passwordHashedAndSalted, err2 := s.passwords.Hash(req.NewPassword)
+	if err2 != nil {
+
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Hashing password failed.",
+			},
+		}
+	}
+
+	err3 := s.users.UpdatePassword(c.UserContext(), user.UUID, passwordHashedAndSalted)
+	if resp := timeoutResponse(c, err3); resp != nil {
+		return &models.PasswordResponse{BaseResponse: *resp}
+	}
+
+	if err3 != nil {
+
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Saving the new password to the db failed.",
+			},
+		}
+	}
+
+	s.recordAudit(c, user.UUID, models.AuditPasswordChanged)
+
+	// sign out every session, whoever knew the old password may be signed in
+	err4 := s.revocations.RevokeUser(c.UserContext(), user.UUID, s.clock.Now())
+	if err4 != nil {
+		return &models.PasswordResponse{BaseResponse: *revocationFailed()}
+	}
+
+	err5 := s.revokeAccessTokens(c, user.UUID)
+	if err5 != nil {
+		return &models.PasswordResponse{BaseResponse: *revocationFailed()}
+	}
+
+	s.recordAudit(c, user.UUID, models.AuditSessionsRevoked)
+
+	return &models.PasswordResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      true,
+			ResponseType: "PASSWORD_CHANGED",
+			Msg:          "The password was changed, sign in again.",
+		},
+	}
+}
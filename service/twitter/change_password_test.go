@@ -0,0 +1,58 @@
+package twitter
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestChangePasswordLocksTheAccount(t *testing.T) {
+
+	ts := newLockoutService(t, config.Lockout{
+		Window:           time.Hour,
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		Duration:         15 * time.Minute,
+	})
+	user := ts.newUser(t, "user@example.com", "right password")
+
+	ts.app.Post("/changePassword", middleware.Auth(ts.tokens, ts.revocations, nil, log.New(ioutil.Discard, "", 0), ""), func(c *fiber.Ctx) error {
+		var req models.ChangePasswordRequest
+		c.BodyParser(&req)
+		return c.JSON(ts.ChangePassword(c, req))
+	})
+
+	changePassword := func(currentPassword string) (int, models.PasswordResponse) {
+		var resp models.PasswordResponse
+		status := ts.postAs(t, "/changePassword", ts.signedIn(t, user), models.ChangePasswordRequest{CurrentPassword: currentPassword, NewPassword: "a new password"}, &resp)
+		return status, resp
+	}
+
+	// whoever holds a stolen access token guesses the current password
+	for i := 0; i < 3; i++ {
+		if status, resp := changePassword("wrong password"); status != http.StatusUnauthorized || resp.ResponseType != "INVALID_CREDENTIALS" {
+			t.Fatalf("ChangePassword with a wrong password = %d %s, want 401 INVALID_CREDENTIALS", status, resp.ResponseType)
+		}
+	}
+
+	if status, resp := changePassword("right password"); status != http.StatusTooManyRequests || resp.ResponseType != "ACCOUNT_LOCKED" {
+		t.Errorf("ChangePassword after three failures = %d %s, want 429 ACCOUNT_LOCKED", status, resp.ResponseType)
+	}
+
+	if _, resp := ts.signIn(t, "user@example.com", "right password"); resp.ResponseType != "ACCOUNT_LOCKED" {
+		t.Errorf("sign in after the lockout = %s, want ACCOUNT_LOCKED", resp.ResponseType)
+	}
+
+	ts.clock.Advance(15 * time.Minute)
+
+	if status, resp := changePassword("right password"); status != http.StatusOK || resp.ResponseType != "PASSWORD_CHANGED" {
+		t.Errorf("ChangePassword once the lock ended = %d %s, want PASSWORD_CHANGED", status, resp.ResponseType)
+	}
+}
@@ -1,31 +1,32 @@
 package twitter
 
 import (
-	"os"
 	"time"
 
 	"github.com/Bruary/twitter-clone/service/models"
 	"github.com/dgrijalva/jwt-go"
 )
 
-func CreateJWT(userUUID string, accountID string, validDurationMinutes time.Duration) (string, error) {
+// createJWT signs a token of the given purpose, it is only accepted by the endpoints of that purpose
+func (s *twitterClone) createJWT(userUUID string, accountID string, purpose models.TokenPurpose, validDuration time.Duration) (string, error) {
 
-	// create the claims that will be used in the JWT token
+	now := s.clock.Now()
+
+	// create the claims that will be used in the JWT token, the ID and the issue time let it be revoked
 	claims := &models.Claims{
 		User_UUID:  userUUID,
 		Account_ID: accountID,
+		Purpose:    purpose,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(validDurationMinutes * time.Minute).Unix(),
+			Id:        s.ids.NewUUID(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(validDuration).Unix(),
 		},
+		Issued_At_Ms: now.UnixNano() / int64(time.Millisecond),
 	}
 
-	// declaring the token with the method used for signing along with the claims§
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	jwtKey := os.Getenv("access_secret")
-
 	// Create the JWT string
-	tokenString, err4 := token.SignedString(jwtKey)
+	tokenString, err4 := s.tokens.Sign(claims)
 	if err4 != nil {
 		return tokenString, err4
 	}
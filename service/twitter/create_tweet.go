@@ -1,33 +1,16 @@
 package twitter
 
 import (
-	"context"
-	"time"
-
+	"github.com/Bruary/twitter-clone/config"
 	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/middleware"
 	"github.com/Bruary/twitter-clone/service/models"
 	"github.com/Bruary/twitter-clone/validate"
 	"github.com/gofiber/fiber/v2"
-	uuid "github.com/satori/go.uuid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Saves a tweet to the db with all required information
-func (*twitterClone) CreateTweet(c *fiber.Ctx, req models.CreateTweetRequest) *models.BaseResponse {
-
-	// Request validation
-	tokenValueEmpty := validate.IsStringEmpty(req.Token)
-	if tokenValueEmpty {
-
-		c.Status(fiber.ErrBadRequest.Code)
-
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "FIELD_MISSING",
-			Msg:          "Field token is missing, or empty.",
-		}
-	}
+func (s *twitterClone) CreateTweet(c *fiber.Ctx, req models.CreateTweetRequest) *models.BaseResponse {
 
 	tweetValueEmpty := validate.IsStringEmpty(req.Tweet)
 	if tweetValueEmpty {
@@ -41,24 +24,24 @@ func (*twitterClone) CreateTweet(c *fiber.Ctx, req models.CreateTweetRequest) *m
 		}
 	}
 
-	// Validate token
-	validToken := validate.IsTokenValid(req.Token)
-	if !validToken {
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
 
-		c.Status(fiber.StatusUnauthorized)
+	// Check if user exists
+	user, err1_5 := s.users.GetUserByUUID(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err1_5); resp != nil {
+		return resp
+	}
+
+	if err1_5 == db.ErrNotFound {
 
 		return &models.BaseResponse{
 			Success:      false,
-			ResponseType: "INVALID_TOKEN",
-			Msg:          "Invalid token.",
+			ResponseType: "USER_DOES_NOT_EXIST",
+			Msg:          "Invalid email address.",
 		}
 	}
 
-	// Extract the JWT claims
-	tokenClaims := validate.GetJWTclaims(req.Token)
-
-	// Check if user exists
-	userDoc, err1_5 := db.GetDocFromDBUsingUUID(db.UsersCol, tokenClaims.User_UUID)
 	if err1_5 != nil {
 
 		return &models.BaseResponse{
@@ -68,23 +51,15 @@ func (*twitterClone) CreateTweet(c *fiber.Ctx, req models.CreateTweetRequest) *m
 		}
 	}
 
-	var user models.UserInfo
-
-	err1_55 := userDoc.Decode(&user)
-	if err1_55 != nil {
-
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "USER_DOES_NOT_EXIST",
-			Msg:          "Invalid email address.",
-		}
+	if resp := s.notVerified(c, user, config.ActionTweet); resp != nil {
+		return resp
 	}
 
 	// Insert all the info that are required to be saved with the tweet
 	var tweetInfo = models.TweetDB{
 		User_UUID:  user.UUID,
 		Account_ID: tokenClaims.Account_ID,
-		Tweet_UUID: uuid.NewV4().String(),
+		Tweet_UUID: s.ids.NewUUID(),
 		Email:      user.Email,
 		Tweet:      req.Tweet,
 		Metrics: models.TweetMetrics{
@@ -93,11 +68,15 @@ func (*twitterClone) CreateTweet(c *fiber.Ctx, req models.CreateTweetRequest) *m
 			Comments_count:   0,
 			Characters_count: len(req.Tweet),
 		},
-		Created_At: time.Now(),
-		Updated_At: time.Now(),
+		Created_At: s.clock.Now(),
+		Updated_At: s.clock.Now(),
+	}
+
+	err2 := s.tweets.CreateTweet(c.UserContext(), &tweetInfo)
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return resp
 	}
 
-	err2 := db.InsertDocumentToDB(db.TweetsCol, tweetInfo)
 	if err2 != nil {
 
 		return &models.BaseResponse{
@@ -108,12 +87,16 @@ func (*twitterClone) CreateTweet(c *fiber.Ctx, req models.CreateTweetRequest) *m
 	}
 
 	//update the tweet count on the users document in the db
-	updateResult := db.UsersCol.FindOneAndUpdate(context.TODO(), bson.M{"uuid": user.UUID}, bson.M{"$set": bson.M{"metrics.total_tweets_count": user.Metrics.Total_tweets_count + 1}})
-	if updateResult.Err() == mongo.ErrNoDocuments {
+	updateErr := s.users.IncrementTweetsCount(c.UserContext(), user.UUID)
+	if resp := timeoutResponse(c, updateErr); resp != nil {
+		return resp
+	}
+
+	if updateErr == db.ErrNotFound {
 		return &models.BaseResponse{
 			Success:      false,
 			ResponseType: "TWEET_SAVED",
-			Msg:          updateResult.Err().Error(),
+			Msg:          updateErr.Error(),
 		}
 	}
 
@@ -1,21 +1,14 @@
 package twitter
 
 import (
-	"crypto/rand"
-	"encoding/hex"
-	"strings"
-	"time"
-
 	"github.com/Bruary/twitter-clone/db"
 	"github.com/Bruary/twitter-clone/service/models"
 	"github.com/Bruary/twitter-clone/validate"
 	"github.com/gofiber/fiber/v2"
-	uuid "github.com/satori/go.uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Creates a new user and adds it to the db
-func (s *twitterClone) CreateUser(c *fiber.Ctx, req models.CreateUserRequest) *models.BaseResponse {
+func (s *twitterClone) CreateUser(c *fiber.Ctx, req models.CreateUserRequest) *models.PasswordResponse {
 
 	// Validate request
 	firstNameEmpty := validate.IsStringEmpty(req.FirstName)
@@ -23,10 +16,12 @@ func (s *twitterClone) CreateUser(c *fiber.Ctx, req models.CreateUserRequest) *m
 
 		c.Status(fiber.ErrBadRequest.Code)
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "FIELD_MISSING",
-			Msg:          "Field firstname is missing, or empty.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field firstname is missing, or empty.",
+			},
 		}
 	}
 
@@ -35,10 +30,12 @@ func (s *twitterClone) CreateUser(c *fiber.Ctx, req models.CreateUserRequest) *m
 
 		c.Status(fiber.ErrBadRequest.Code)
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "FIELD_MISSING",
-			Msg:          "Field lastname is missing, or empty.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field lastname is missing, or empty.",
+			},
 		}
 	}
 
@@ -47,22 +44,17 @@ func (s *twitterClone) CreateUser(c *fiber.Ctx, req models.CreateUserRequest) *m
 
 		c.Status(fiber.ErrBadRequest.Code)
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "FIELD_MISSING",
-			Msg:          "Field email is missing, or empty.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field email is missing, or empty.",
+			},
 		}
 	}
 
-	passwordValid := validate.IsPasswordLengthCorrect(req.Password)
-	if !passwordValid {
-		c.Status(fiber.ErrBadRequest.Code)
-
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "FIELD_ERROR",
-			Msg:          "Password should atleast have 8 characters.",
-		}
+	if resp := s.checkPasswordPolicy(c, req.Password, req.Email, req.FirstName, req.LastName); resp != nil {
+		return resp
 	}
 
 	ageValid := validate.IsAge12AndAbove(req.Age)
@@ -70,60 +62,68 @@ func (s *twitterClone) CreateUser(c *fiber.Ctx, req models.CreateUserRequest) *m
 
 		c.Status(fiber.ErrBadRequest.Code)
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "FIELD_ERROR",
-			Msg:          "Age should be 12 and above years old to create an account.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_ERROR",
+				Msg:          "Age should be 12 and above years old to create an account.",
+			},
 		}
 	}
 
 	// check is user already exist in db
-	doesUserExist, err10 := db.UserAlreadyExists(db.UsersCol, req.Email)
+	doesUserExist, err10 := s.users.UserExists(c.UserContext(), req.Email)
+	if resp := timeoutResponse(c, err10); resp != nil {
+		return &models.PasswordResponse{BaseResponse: *resp}
+	}
+
 	if err10 == nil && doesUserExist {
 
 		c.Status(403)
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "USER_ALREADY_EXISTS",
-			Msg:          "User's email already exists.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "USER_ALREADY_EXISTS",
+				Msg:          "User's email already exists.",
+			},
 		}
 	}
 
-	passwordHashedAndSalted, err10_5 := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.MinCost)
+	passwordHashedAndSalted, err10_5 := s.passwords.Hash(req.Password)
 	if err10_5 != nil {
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "UNKNOWN_ERROR",
-			Msg:          "Hashing password failed in SignUp endpoint.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Hashing password failed in SignUp endpoint.",
+			},
 		}
 	}
 
 	// Create a random account ID
-	a := make([]byte, 5)
-
-	_, err20_1 := rand.Read(a)
+	accountID, err20_1 := s.ids.NewAccountID()
 	if err20_1 != nil {
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "UNKNOWN_ERROR",
-			Msg:          "Creating account ID failed.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Creating account ID failed.",
+			},
 		}
 	}
 
-	accountID := strings.ToUpper("#" + hex.EncodeToString(a))
-
 	// Fill in the user details
 	userInfo := &models.UserInfo{
-		UUID:       uuid.NewV4().String(),
+		UUID:       s.ids.NewUUID(),
 		Account_ID: accountID,
 		FirstName:  req.FirstName,
 		LastName:   req.LastName,
 		Age:        req.Age,
 		Email:      req.Email,
-		Password:   string(passwordHashedAndSalted),
+		Password:   passwordHashedAndSalted,
 		Metrics: models.UserMetrics{
 			Followers_count:      0,
 			Following_count:      0,
@@ -131,23 +131,51 @@ func (s *twitterClone) CreateUser(c *fiber.Ctx, req models.CreateUserRequest) *m
 			Total_retweets_count: 0,
 			Total_likes_count:    0,
 		},
-		Created_At: time.Now(),
-		Updated_At: time.Now(),
+		Created_At: s.clock.Now(),
+		Updated_At: s.clock.Now(),
+	}
+
+	// add the new user to the users store
+	err1 := s.users.CreateUser(c.UserContext(), userInfo)
+	if resp := timeoutResponse(c, err1); resp != nil {
+		return &models.PasswordResponse{BaseResponse: *resp}
+	}
+
+	// the email was taken by a concurrent signup after the check above
+	if err1 == db.ErrDuplicate {
+
+		c.Status(403)
+
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "USER_ALREADY_EXISTS",
+				Msg:          "User's email already exists.",
+			},
+		}
 	}
 
-	// call the InsertDocumentToDB func to add a new user to the db collection 'Users'
-	err1 := db.InsertDocumentToDB(db.UsersCol, userInfo)
 	if err1 != nil {
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "UNKNOWN_ERROR",
-			Msg:          "Inserting new user to the db failed.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Inserting new user to the db failed.",
+			},
 		}
 	}
 
-	return &models.BaseResponse{
-		Success:      true,
-		ResponseType: "NEW_USER_CREATED",
-		Msg:          "New user was added and saved to the db.",
+	// the account works without it, the link can be sent again from /auth/verifyEmail/resend
+	err2 := s.sendVerificationEmail(c, userInfo)
+	if err2 != nil {
+		s.logger.Println("Sending the verification email failed:", err2)
+	}
+
+	return &models.PasswordResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      true,
+			ResponseType: "NEW_USER_CREATED",
+			Msg:          "New user was added and saved to the db, check your inbox to verify your email.",
+		},
 	}
 }
@@ -1,59 +1,68 @@
 package twitter
 
 import (
-	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/middleware"
 	"github.com/Bruary/twitter-clone/service/models"
-	"github.com/Bruary/twitter-clone/validate"
 	"github.com/gofiber/fiber/v2"
 )
 
 // Delete user from the user using the email
-func (*twitterClone) DeleteUser(c *fiber.Ctx, req models.DeleteUserRequest) *models.BaseResponse {
+func (s *twitterClone) DeleteUser(c *fiber.Ctx, req models.DeleteUserRequest) *models.BaseResponse {
 
-	tokenEmptyValue := validate.IsStringEmpty(req.Token)
-	if tokenEmptyValue {
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
 
-		c.Status(fiber.ErrBadRequest.Code)
-
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "FIELD_MISSING",
-			Msg:          "Field token is missing, or empty.",
-		}
+	// the tokens of a deleted account must stop working right away, the access tokens are not checked
+	// against the account on every request, so nothing is deleted unless they are revoked
+	err := s.revocations.RevokeUser(c.UserContext(), tokenClaims.User_UUID, s.clock.Now())
+	if err != nil {
+		return revocationFailed()
 	}
 
-	// Validate token
-	validToken := validate.IsTokenValid(req.Token)
-	if !validToken {
+	// the tokens of the bots must stop working along with the account, so they go before it
+	err1 := s.accessTokens.DeletePersonalAccessTokens(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err1); resp != nil {
+		return resp
+	}
 
-		c.Status(fiber.StatusUnauthorized)
+	if err1 != nil {
 
 		return &models.BaseResponse{
 			Success:      false,
-			ResponseType: "INVALID_TOKEN",
-			Msg:          "Invalid token.",
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Failed to delete the personal access tokens of the user from DB.",
 		}
 	}
 
-	// Extract the JWT claims
-	tokenClaims := validate.GetJWTclaims(req.Token)
+	err2 := s.users.DeleteUser(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return resp
+	}
 
-	err1 := db.DeleteUser(db.UsersCol, tokenClaims.User_UUID)
-	if err1 != nil {
+	if err2 != nil {
 
 		return &models.BaseResponse{
 			Success:      false,
 			ResponseType: "UNKNOWN_ERROR",
 			Msg:          "Failed to delete user from DB.",
 		}
+	}
 
-	} else {
+	// nothing left to sign in to, a failure only leaves an orphan behind
+	err2_5 := s.twoFactors.DeleteTwoFactor(c.UserContext(), tokenClaims.User_UUID)
+	if err2_5 != nil {
+		s.logger.Println("Deleting the two-factor authentication of user "+tokenClaims.User_UUID+" failed:", err2_5)
+	}
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "USER_DELETED",
-			Msg:          "User has been successfuly deleted from the db.",
-		}
+	// a leftover identity would sign in to an account that is gone
+	err2_6 := s.identities.DeleteIdentities(c.UserContext(), tokenClaims.User_UUID)
+	if err2_6 != nil {
+		s.logger.Println("Deleting the identities of user "+tokenClaims.User_UUID+" failed:", err2_6)
+	}
 
+	return &models.BaseResponse{
+		Success:      true,
+		ResponseType: "USER_DELETED",
+		Msg:          "User has been successfuly deleted from the db.",
 	}
 }
@@ -0,0 +1,180 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/pat"
+	"github.com/Bruary/twitter-clone/revocation"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// failingAccessTokens fails to delete the personal access tokens
+type failingAccessTokens struct {
+	db.PersonalAccessTokenStore
+}
+
+func (failingAccessTokens) DeletePersonalAccessTokens(ctx context.Context, userUUID string) error {
+	return errors.New("down")
+}
+
+// failingRevocations fails to revoke the tokens of a user
+type failingRevocations struct {
+	revocation.Store
+}
+
+func (failingRevocations) RevokeUser(ctx context.Context, userUUID string, issuedBefore time.Time) error {
+	return errors.New("down")
+}
+
+func newDeleteUserService(t *testing.T) *testService {
+
+	ts := newTestService(t, Options{})
+	logger := log.New(ioutil.Discard, "", 0)
+	verifier := pat.NewVerifier(ts.store, ts.store, ts.revocations, ts.clock, logger)
+
+	ts.app.Post("/delete", middleware.Auth(ts.tokens, ts.revocations, verifier, logger, ""), func(c *fiber.Ctx) error {
+		return c.JSON(ts.DeleteUser(c, models.DeleteUserRequest{}))
+	})
+
+	ts.app.Post("/tweets/get", middleware.Auth(ts.tokens, ts.revocations, verifier, logger, models.ScopeTweetsRead), func(c *fiber.Ctx) error {
+		return c.JSON(&models.BaseResponse{Success: true})
+	})
+
+	return ts
+}
+
+// authorized posts to path with the bearer token and returns the status and the response
+func (ts *testService) authorized(t *testing.T, path string, token string) (int, models.BaseResponse) {
+
+	req := httptest.NewRequest("POST", path, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := ts.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var body models.BaseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding the response of %s: %v", path, err)
+	}
+
+	return resp.StatusCode, body
+}
+
+// newAccessToken saves a personal access token of the user reading the tweets and returns it
+func (ts *testService) newAccessToken(t *testing.T, user *models.UserInfo) string {
+
+	token, hash, err := pat.New()
+	if err != nil {
+		t.Fatalf("pat.New: %v", err)
+	}
+
+	err = ts.store.CreatePersonalAccessToken(context.Background(), &models.PersonalAccessToken{
+		ID:         ts.ids.NewUUID(),
+		User_UUID:  user.UUID,
+		Account_ID: user.Account_ID,
+		Name:       "bot",
+		Hash:       hash,
+		Scopes:     []string{models.ScopeTweetsRead},
+		Created_At: ts.clock.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreatePersonalAccessToken: %v", err)
+	}
+
+	return token
+}
+
+func TestDeleteUserDeletesThePersonalAccessTokens(t *testing.T) {
+
+	ts := newDeleteUserService(t)
+	user := ts.newUser(t, "user@example.com", "right password")
+
+	accessToken := ts.newAccessToken(t, user)
+
+	token, err := ts.createJWT(user.UUID, user.Account_ID, models.PurposeAccess, time.Hour)
+	if err != nil {
+		t.Fatalf("createJWT: %v", err)
+	}
+
+	ts.clock.Advance(time.Millisecond)
+
+	if status, resp := ts.authorized(t, "/delete", token); !resp.Success || resp.ResponseType != "USER_DELETED" {
+		t.Fatalf("DeleteUser = %d %+v, want USER_DELETED", status, resp)
+	}
+
+	if status, resp := ts.authorized(t, "/tweets/get", token); status != http.StatusUnauthorized || resp.ResponseType != "TOKEN_REVOKED" {
+		t.Errorf("access token of a deleted user = %d %s, want 401 TOKEN_REVOKED", status, resp.ResponseType)
+	}
+
+	if status, _ := ts.authorized(t, "/tweets/get", accessToken); status != http.StatusUnauthorized {
+		t.Errorf("personal access token of a deleted user = %d, want 401", status)
+	}
+}
+
+func TestDeleteUserFailsWhenThePersonalAccessTokensStay(t *testing.T) {
+
+	ts := newDeleteUserService(t)
+	user := ts.newUser(t, "user@example.com", "right password")
+
+	accessToken := ts.newAccessToken(t, user)
+
+	token, err := ts.createJWT(user.UUID, user.Account_ID, models.PurposeAccess, time.Hour)
+	if err != nil {
+		t.Fatalf("createJWT: %v", err)
+	}
+
+	ts.accessTokens = failingAccessTokens{ts.store}
+
+	if _, resp := ts.authorized(t, "/delete", token); resp.ResponseType != "UNKNOWN_ERROR" {
+		t.Errorf("DeleteUser while the personal access tokens can not be deleted = %s, want UNKNOWN_ERROR", resp.ResponseType)
+	}
+
+	// nothing was deleted, the user can try again
+	if _, err := ts.store.GetUserByUUID(context.Background(), user.UUID); err != nil {
+		t.Errorf("GetUserByUUID after the failed delete: %v", err)
+	}
+
+	if status, _ := ts.authorized(t, "/tweets/get", accessToken); status != http.StatusOK {
+		t.Errorf("personal access token after the failed delete = %d, want 200", status)
+	}
+}
+
+func TestDeleteUserFailsWhenTheTokensCanNotBeRevoked(t *testing.T) {
+
+	ts := newDeleteUserService(t)
+	user := ts.newUser(t, "user@example.com", "right password")
+
+	accessToken := ts.newAccessToken(t, user)
+	token := ts.signedIn(t, user)
+
+	ts.revocations = failingRevocations{ts.revocations}
+
+	if _, resp := ts.authorized(t, "/delete", token); resp.ResponseType != "UNKNOWN_ERROR" {
+		t.Errorf("DeleteUser while the tokens can not be revoked = %s, want UNKNOWN_ERROR", resp.ResponseType)
+	}
+
+	// nothing was deleted, the user can try again
+	if _, err := ts.store.GetUserByUUID(context.Background(), user.UUID); err != nil {
+		t.Errorf("GetUserByUUID after the failed delete: %v", err)
+	}
+
+	if status, _ := ts.authorized(t, "/tweets/get", accessToken); status != http.StatusOK {
+		t.Errorf("personal access token after the failed delete = %d, want 200", status)
+	}
+}
@@ -1,47 +1,30 @@
 package twitter
 
 import (
-	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/middleware"
 	"github.com/Bruary/twitter-clone/service/models"
-	"github.com/Bruary/twitter-clone/validate"
 	"github.com/gofiber/fiber/v2"
 )
 
-func (*twitterClone) Feed(c *fiber.Ctx, req models.BaseRequest) *models.FeedResponse {
+// limit the tweets count of the feed to 30
+const feedTweetsLimit = 30
 
-	// STEPS
-	tokenValueEmpty := validate.IsStringEmpty(req.Token)
-	if tokenValueEmpty {
+func (s *twitterClone) Feed(c *fiber.Ctx, req models.BaseRequest) *models.FeedResponse {
 
-		c.Status(fiber.ErrBadRequest.Code)
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
 
-		return &models.FeedResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "FIELD_MISSING",
-				Msg:          "Field token is missing, or empty.",
-			}}
+	if resp := s.requireVerified(c, tokenClaims.User_UUID, config.ActionFeed); resp != nil {
+		return &models.FeedResponse{BaseResponse: *resp}
 	}
 
-	// Validate token
-	validToken := validate.IsTokenValid(req.Token)
-	if !validToken {
-
-		c.Status(fiber.StatusUnauthorized)
-
-		return &models.FeedResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "INVALID_TOKEN",
-				Msg:          "Invalid token.",
-			}}
+	// get all following_account_ids
+	followingAccountIDs, err := s.follows.GetFollowingAccountIDs(c.UserContext(), tokenClaims.Account_ID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.FeedResponse{BaseResponse: *resp}
 	}
 
-	// Extract the JWT claims
-	tokenClaims := validate.GetJWTclaims(req.Token)
-
-	// get all following_account_ids
-	followingAccountIDs, err := db.GetAllFollowingAccountIDs(db.FollowersCol, tokenClaims.Account_ID)
 	if err != nil {
 		return &models.FeedResponse{
 			BaseResponse: models.BaseResponse{
@@ -52,7 +35,11 @@ func (*twitterClone) Feed(c *fiber.Ctx, req models.BaseRequest) *models.FeedResp
 	}
 
 	// Get all tweets for each of the following accounts
-	tweets, err2 := db.GetTweetsForAListOfAccounts(db.TweetsCol, followingAccountIDs)
+	tweets, err2 := s.tweets.GetTweetsForAccounts(c.UserContext(), followingAccountIDs, feedTweetsLimit)
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return &models.FeedResponse{BaseResponse: *resp}
+	}
+
 	if err2 != nil {
 		return &models.FeedResponse{
 			BaseResponse: models.BaseResponse{
@@ -1,27 +1,15 @@
 package twitter
 
 import (
+	"github.com/Bruary/twitter-clone/config"
 	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/middleware"
 	"github.com/Bruary/twitter-clone/service/models"
 	"github.com/Bruary/twitter-clone/validate"
 	"github.com/gofiber/fiber/v2"
-	uuid "github.com/satori/go.uuid"
 )
 
-func (*twitterClone) Follow(c *fiber.Ctx, req models.FollowRequest) *models.BaseResponse {
-
-	// Request validation
-	tokenValueEmpty := validate.IsStringEmpty(req.Token)
-	if tokenValueEmpty {
-
-		c.Status(fiber.ErrBadRequest.Code)
-
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "FIELD_MISSING",
-			Msg:          "Field token is missing, or empty.",
-		}
-	}
+func (s *twitterClone) Follow(c *fiber.Ctx, req models.FollowRequest) *models.BaseResponse {
 
 	followingAccountIDEmpty := validate.IsStringEmpty(req.Following_Account_ID)
 	if followingAccountIDEmpty {
@@ -35,64 +23,44 @@ func (*twitterClone) Follow(c *fiber.Ctx, req models.FollowRequest) *models.Base
 		}
 	}
 
-	// Validate token
-	validToken := validate.IsTokenValid(req.Token)
-	if !validToken {
-
-		c.Status(fiber.StatusUnauthorized)
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "INVALID_TOKEN",
-			Msg:          "Invalid token.",
-		}
+	if resp := s.requireVerified(c, tokenClaims.User_UUID, config.ActionFollow); resp != nil {
+		return resp
 	}
 
-	// Extract the JWT claims
-	tokenClaims := validate.GetJWTclaims(req.Token)
-
-	// Check if this follower-follower relationship exist in the db
-	followerFollowingCombExits := db.FollowerFollowingCombinationExists(db.FollowersCol, tokenClaims.Account_ID, req.Following_Account_ID)
-	if !followerFollowingCombExits {
-
-		followerData := &models.Followers{
-			ID:                   uuid.NewV4().String(),
-			Follower_Account_ID:  tokenClaims.Account_ID,
-			Following_Account_ID: req.Following_Account_ID,
-		}
+	followerData := &models.Followers{
+		ID:                   s.ids.NewUUID(),
+		Follower_Account_ID:  tokenClaims.Account_ID,
+		Following_Account_ID: req.Following_Account_ID,
+	}
 
-		err2 := db.InsertDocumentToDB(db.FollowersCol, followerData)
-		if err2 != nil {
+	// save the follow along with the "following" and "followers" counts,
+	// following an account that is already followed changes nothing
+	_, err2 := s.follows.Follow(c.UserContext(), followerData)
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return resp
+	}
 
-			return &models.BaseResponse{
-				Success:      false,
-				ResponseType: "UNKNOWN_ERROR",
-				Msg:          "Inserting tweet to the db failed.",
-			}
-		}
+	if err2 == db.ErrNotFound {
 
-		// increment the "following" field
-		err3 := db.UpdateFollowingCount(db.UsersCol, tokenClaims.Account_ID)
-		if err3 != nil {
+		c.Status(fiber.StatusNotFound)
 
-			return &models.BaseResponse{
-				Success:      false,
-				ResponseType: "UNKNOWN_ERROR",
-				Msg:          "Updating following count failed.",
-			}
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "USER_DOES_NOT_EXIST",
+			Msg:          "The account to follow does not exist.",
 		}
+	}
 
-		// increment the "followers" field
-		err4 := db.UpdateFollowersCount(db.UsersCol, req.Following_Account_ID)
-		if err4 != nil {
+	if err2 != nil {
 
-			return &models.BaseResponse{
-				Success:      false,
-				ResponseType: "UNKNOWN_ERROR",
-				Msg:          "Updating followers count failed.",
-			}
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Saving the follow to the db failed.",
 		}
-
 	}
 
 	return &models.BaseResponse{
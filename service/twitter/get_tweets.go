@@ -1,70 +1,38 @@
 package twitter
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
 
-	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/cache"
+	"github.com/Bruary/twitter-clone/middleware"
 	"github.com/Bruary/twitter-clone/service/models"
-	"github.com/Bruary/twitter-clone/validate"
-	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
 )
 
-func (*twitterClone) GetTweets(c *fiber.Ctx, req models.BaseRequest) *models.GetTweetsResponse {
+func (s *twitterClone) GetTweets(c *fiber.Ctx, req models.BaseRequest) *models.GetTweetsResponse {
 
-	// Request validation
-	tokenValueEmpty := validate.IsStringEmpty(req.Token)
-	if tokenValueEmpty {
-		c.Status(fiber.ErrBadRequest.Code)
-
-		return &models.GetTweetsResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "FIELD_MISSING",
-				Msg:          "Field token is missing, or empty.",
-			},
-		}
-	}
-
-	// Validate token
-	validToken := validate.IsTokenValid(req.Token)
-	if !validToken {
-
-		c.Status(fiber.StatusUnauthorized)
-
-		return &models.GetTweetsResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "INVALID_TOKEN",
-				Msg:          "Invalid token.",
-			},
-		}
-	}
-
-	// Extract the JWT claims
-	tokenClaims := validate.GetJWTclaims(req.Token)
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
 
 	// create a unique UUID to save it on redis and then save it
 	cacheUUID := "GET_TWEETS:" + tokenClaims.User_UUID
 
 	// try to get it from cache
-	result, err := db.RedisClient.Get(context.Background(), cacheUUID).Result()
-	if err == redis.Nil {
-		fmt.Println(cacheUUID + " cache key was not found.")
+	result, err := s.cache.Get(c.UserContext(), cacheUUID)
+	if err == cache.ErrMiss {
+		s.logger.Println(cacheUUID + " cache key was not found.")
 	} else if err != nil {
-		fmt.Println("Cache get failed", err)
+		s.logger.Println("Cache get failed", err)
 	} else {
 
 		var cachedTweets []models.Tweet
 
-		unmarshalErr := json.Unmarshal([]byte(result), &cachedTweets)
+		unmarshalErr := json.Unmarshal(result, &cachedTweets)
 		if unmarshalErr != nil {
-			fmt.Println("Marshaling failed in getting cahce.")
+			s.logger.Println("Marshaling failed in getting cahce.")
 		}
 
-		fmt.Println("from cache")
+		s.logger.Println("from cache")
 
 		return &models.GetTweetsResponse{
 			BaseResponse: models.BaseResponse{
@@ -74,7 +42,11 @@ func (*twitterClone) GetTweets(c *fiber.Ctx, req models.BaseRequest) *models.Get
 		}
 	}
 
-	tweets, err12 := db.GetTweetsUsingUUID(db.TweetsCol, tokenClaims.User_UUID)
+	tweets, err12 := s.tweets.GetTweetsByUserUUID(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err12); resp != nil {
+		return &models.GetTweetsResponse{BaseResponse: *resp}
+	}
+
 	if err12 != nil {
 
 		return &models.GetTweetsResponse{
@@ -89,9 +61,9 @@ func (*twitterClone) GetTweets(c *fiber.Ctx, req models.BaseRequest) *models.Get
 	cachedTweets, _ := json.Marshal(tweets)
 
 	// save response on cache
-	cacheErr := db.RedisClient.Set(context.Background(), cacheUUID, cachedTweets, 0).Err()
+	cacheErr := s.cache.Set(c.UserContext(), cacheUUID, cachedTweets, 0)
 	if cacheErr != nil {
-		fmt.Println("Writing cache failed: ", cacheErr)
+		s.logger.Println("Writing cache failed: ", cacheErr)
 	}
 
 	return &models.GetTweetsResponse{
@@ -0,0 +1,265 @@
+package twitter
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/lockout"
+	"github.com/Bruary/twitter-clone/mailer"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/validate"
+	"github.com/gofiber/fiber/v2"
+)
+
+// the failures of an account are counted by email, so that an unknown email is slowed down
+// and locked just like a known one and the responses tell nothing about which emails exist
+func accountLockoutKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipLockoutKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (s *twitterClone) lockoutPolicy() lockout.Policy {
+	return lockout.Policy{
+		Window:           s.config.Lockout.Window,
+		AccountThreshold: s.config.Lockout.AccountThreshold,
+		IPThreshold:      s.config.Lockout.IPThreshold,
+		LockDuration:     s.config.Lockout.Duration,
+		BaseDelay:        s.config.Lockout.BaseDelay,
+		MaxDelay:         s.config.Lockout.MaxDelay,
+	}
+}
+
+// signInAttempt is a sign in counted as a failure of the IP and of the account before it is tried,
+// so that concurrent attempts can not all slip under the limits
+type signInAttempt struct {
+	email   string
+	ip      string
+	at      time.Time
+	before  lockout.Attempts // the failures of the account before the attempt
+	ipFails lockout.Attempts // the failures of the IP before the attempt
+}
+
+// reserveSignIn counts the sign in before the password or the code is checked and returns it, or the response
+// to send when it must not even be tried: the IP failed too often, the account is locked, or it has to wait
+// after its last failure.
+func (s *twitterClone) reserveSignIn(c *fiber.Ctx, email string) (*signInAttempt, *models.SignInResponse) {
+
+	policy := s.lockoutPolicy()
+	attempt := &signInAttempt{email: email, ip: c.IP(), at: s.clock.Now()}
+
+	lockedUntil, err := s.lockout.LockedUntil(c.UserContext(), accountLockoutKey(email))
+	if err != nil {
+		return nil, &models.SignInResponse{BaseResponse: *s.lockoutFailed(err)}
+	}
+
+	if !lockedUntil.IsZero() {
+		return nil, tooManyAttempts(c, "ACCOUNT_LOCKED", lockedUntil.Sub(attempt.at))
+	}
+
+	ipFails, err2 := s.lockout.Reserve(c.UserContext(), ipLockoutKey(attempt.ip), attempt.at, policy.Window)
+	if err2 != nil {
+		return nil, &models.SignInResponse{BaseResponse: *s.lockoutFailed(err2)}
+	}
+
+	attempt.ipFails = ipFails
+
+	if ipFails.Failures >= policy.IPThreshold {
+
+		// a refused attempt is not a failure
+		s.releaseIP(c, attempt)
+
+		return nil, tooManyAttempts(c, "TOO_MANY_ATTEMPTS", ipFails.Last.Add(policy.Window).Sub(attempt.at))
+	}
+
+	before, err3 := s.lockout.Reserve(c.UserContext(), accountLockoutKey(email), attempt.at, policy.Window)
+	if err3 != nil {
+		s.releaseIP(c, attempt)
+
+		return nil, &models.SignInResponse{BaseResponse: *s.lockoutFailed(err3)}
+	}
+
+	attempt.before = before
+
+	// the attempts reserved meanwhile count too, they wait for each other or stop at the threshold
+	wait := before.Last.Add(policy.Delay(before.Failures)).Sub(attempt.at)
+	if wait > 0 || before.Failures >= policy.AccountThreshold {
+
+		s.releaseSignIn(c, attempt)
+
+		if wait <= 0 {
+			wait = policy.BaseDelay
+		}
+
+		return nil, tooManyAttempts(c, "TOO_MANY_ATTEMPTS", wait)
+	}
+
+	return attempt, nil
+}
+
+// signInFailed keeps the attempt counted against the account and the IP, and locks the account once it failed too often.
+// The user is nil for an unknown email.
+func (s *twitterClone) signInFailed(c *fiber.Ctx, attempt *signInAttempt, user *models.UserInfo) {
+
+	policy := s.lockoutPolicy()
+	email := attempt.email
+
+	if attempt.before.Failures+1 < policy.AccountThreshold {
+		return
+	}
+
+	lockedUntil := attempt.at.Add(policy.LockDuration)
+
+	err := s.lockout.Lock(c.UserContext(), accountLockoutKey(email), lockedUntil)
+	if err != nil {
+		s.logger.Println("Locking the account of "+email+" failed:", err)
+		return
+	}
+
+	if user == nil {
+		return
+	}
+
+	s.recordAudit(c, user.UUID, models.AuditAccountLocked)
+
+	// draft the email
+	e := mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Your account was locked",
+		Text: "There were too many failed attempts to sign in to your account, it is locked until " +
+			lockedUntil.UTC().Format(time.RFC1123) + ".\n" +
+			"If it was not you, please reset your password.",
+	}
+
+	// a failure to notify does not keep the account from being locked
+	err2 := s.mailer.Send(c.UserContext(), e)
+	if err2 != nil {
+		s.logger.Println("Sending the lockout email to "+user.Email+" failed:", err2)
+	}
+}
+
+// signInSucceeded forgets the failures of the account once every factor was checked, the ones of the IP are kept
+// so that signing in to an account of their own does not let an attacker go on guessing
+func (s *twitterClone) signInSucceeded(c *fiber.Ctx, attempt *signInAttempt) {
+
+	s.releaseIP(c, attempt)
+
+	err := s.lockout.Reset(c.UserContext(), accountLockoutKey(attempt.email))
+	if err != nil {
+		s.logger.Println("Resetting the failed sign ins of "+attempt.email+" failed:", err)
+	}
+}
+
+// releaseSignIn uncounts an attempt which did not fail, like a right password still waiting for its second factor,
+// the failures before it are kept
+func (s *twitterClone) releaseSignIn(c *fiber.Ctx, attempt *signInAttempt) {
+
+	s.releaseIP(c, attempt)
+
+	err := s.lockout.Release(c.UserContext(), accountLockoutKey(attempt.email), attempt.at, attempt.before)
+	if err != nil {
+		s.logger.Println("Releasing the sign in of "+attempt.email+" failed:", err)
+	}
+}
+
+func (s *twitterClone) releaseIP(c *fiber.Ctx, attempt *signInAttempt) {
+
+	err := s.lockout.Release(c.UserContext(), ipLockoutKey(attempt.ip), attempt.at, attempt.ipFails)
+	if err != nil {
+		s.logger.Println("Releasing the sign in of IP "+attempt.ip+" failed:", err)
+	}
+}
+
+// AdminUnlockUser lets an admin lift the lock of an account before it ends
+func (s *twitterClone) AdminUnlockUser(c *fiber.Ctx, req models.AdminUnlockUserRequest) *models.BaseResponse {
+
+	emailEmpty := validate.IsStringEmpty(req.Email)
+	if emailEmpty {
+
+		c.Status(fiber.ErrBadRequest.Code)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "FIELD_MISSING",
+			Msg:          "Field email is missing, or empty.",
+		}
+	}
+
+	user, err := s.users.GetUserByEmail(c.UserContext(), req.Email)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err == db.ErrNotFound {
+
+		c.Status(fiber.StatusNotFound)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "USER_DOES_NOT_EXIST",
+			Msg:          "No account has this email.",
+		}
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Failed while finding user in db.",
+		}
+	}
+
+	err2 := s.lockout.Unlock(c.UserContext(), accountLockoutKey(user.Email))
+	if err2 != nil {
+		return s.lockoutFailed(err2)
+	}
+
+	s.recordAudit(c, user.UUID, models.AuditAccountUnlockedByAdmin)
+
+	return &models.BaseResponse{
+		Success:      true,
+		ResponseType: "ACCOUNT_UNLOCKED",
+		Msg:          "The account is unlocked.",
+	}
+}
+
+func tooManyAttempts(c *fiber.Ctx, responseType string, retryAfter time.Duration) *models.SignInResponse {
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	c.Status(fiber.StatusTooManyRequests)
+
+	msg := "Too many failed attempts to sign in, try again in " + strconv.Itoa(seconds) + " seconds."
+	if responseType == "ACCOUNT_LOCKED" {
+		msg = "The account is locked after too many failed attempts to sign in, try again in " + strconv.Itoa(seconds) + " seconds."
+	}
+
+	return &models.SignInResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      false,
+			ResponseType: responseType,
+			Msg:          msg,
+		},
+	}
+}
+
+func (s *twitterClone) lockoutFailed(err error) *models.BaseResponse {
+
+	s.logger.Println("Checking the failed sign ins failed:", err)
+
+	return &models.BaseResponse{
+		Success:      false,
+		ResponseType: "UNKNOWN_ERROR",
+		Msg:          "Checking the failed sign ins failed.",
+	}
+}
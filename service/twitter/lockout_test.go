@@ -0,0 +1,222 @@
+package twitter
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newLockoutService(t *testing.T, lockout config.Lockout) *testService {
+
+	cfg := config.Default()
+	cfg.Password.BcryptCost = bcrypt.MinCost
+	cfg.Lockout = lockout
+
+	ts := newTestService(t, Options{Config: cfg})
+
+	ts.route("/signin", func(c *fiber.Ctx) interface{} {
+		var req models.SignInRequest
+		c.BodyParser(&req)
+		return ts.SignIn(c, req)
+	})
+
+	ts.route("/2fa/signin", func(c *fiber.Ctx) interface{} {
+		var req models.TwoFactorSignInRequest
+		c.BodyParser(&req)
+		return ts.TwoFactorSignIn(c, req)
+	})
+
+	return ts
+}
+
+func (ts *testService) signIn(t *testing.T, email string, password string) (int, models.SignInResponse) {
+
+	var resp models.SignInResponse
+	status := ts.post(t, "/signin", models.SignInRequest{Email: email, Password: password}, &resp)
+
+	return status, resp
+}
+
+func (ts *testService) twoFactorSignIn(t *testing.T, challengeToken string, recoveryCode string) (int, models.SignInResponse) {
+
+	var resp models.SignInResponse
+	status := ts.post(t, "/2fa/signin", models.TwoFactorSignInRequest{ChallengeToken: challengeToken, RecoveryCode: recoveryCode}, &resp)
+
+	return status, resp
+}
+
+func TestConcurrentSignInsStopAtTheThreshold(t *testing.T) {
+
+	ts := newLockoutService(t, config.Lockout{
+		Window:           time.Hour,
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		Duration:         15 * time.Minute,
+	})
+	ts.newUser(t, "user@example.com", "right password")
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		verified int
+	)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			status, resp := ts.signIn(t, "user@example.com", "wrong password")
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case resp.ResponseType == "INVALID_CREDENTIALS":
+				verified++
+			case status != http.StatusTooManyRequests:
+				t.Errorf("sign in = %d %s, want it refused", status, resp.ResponseType)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if verified != 3 {
+		t.Errorf("%d concurrent sign ins had their password checked, want the threshold of 3", verified)
+	}
+
+	if _, resp := ts.signIn(t, "user@example.com", "right password"); resp.ResponseType != "ACCOUNT_LOCKED" {
+		t.Errorf("sign in after the threshold = %s, want ACCOUNT_LOCKED", resp.ResponseType)
+	}
+
+	ts.clock.Advance(15 * time.Minute)
+
+	if status, resp := ts.signIn(t, "user@example.com", "right password"); status != http.StatusOK || resp.Token == "" {
+		t.Errorf("sign in once the lock ended = %d %s, want the tokens", status, resp.ResponseType)
+	}
+}
+
+func TestSignInWaitsAfterAFailure(t *testing.T) {
+
+	ts := newLockoutService(t, config.Lockout{
+		Window:           time.Hour,
+		AccountThreshold: 10,
+		IPThreshold:      100,
+		Duration:         15 * time.Minute,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+	})
+	ts.newUser(t, "user@example.com", "right password")
+
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+
+		if _, resp := ts.signIn(t, "user@example.com", "wrong password"); resp.ResponseType != "INVALID_CREDENTIALS" {
+			t.Fatalf("sign in = %s, want INVALID_CREDENTIALS", resp.ResponseType)
+		}
+
+		ts.clock.Advance(wait - time.Millisecond)
+
+		if status, _ := ts.signIn(t, "user@example.com", "right password"); status != http.StatusTooManyRequests {
+			t.Fatalf("sign in before the wait of %v ended = %d, want it refused", wait, status)
+		}
+
+		ts.clock.Advance(time.Millisecond)
+	}
+
+	if status, resp := ts.signIn(t, "user@example.com", "right password"); status != http.StatusOK || resp.Token == "" {
+		t.Errorf("sign in once the wait ended = %d %s, want the tokens", status, resp.ResponseType)
+	}
+
+	// the refused sign ins did not count, and the success forgot the failures
+	attempts, err := ts.lockout.Attempts(context.Background(), accountLockoutKey("user@example.com"))
+	if err != nil || attempts.Failures != 0 {
+		t.Errorf("Attempts after the sign in = %+v, %v, want none", attempts, err)
+	}
+}
+
+func TestWrongCodesCountAsFailures(t *testing.T) {
+
+	ts := newLockoutService(t, config.Lockout{
+		Window:           time.Hour,
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		Duration:         15 * time.Minute,
+	})
+	user := ts.newUser(t, "user@example.com", "right password")
+	ts.enableTwoFactor(t, user, "right-code")
+
+	if _, resp := ts.signIn(t, "user@example.com", "wrong password"); resp.ResponseType != "INVALID_CREDENTIALS" {
+		t.Fatalf("sign in = %s, want INVALID_CREDENTIALS", resp.ResponseType)
+	}
+
+	for i := 0; i < 2; i++ {
+
+		// the right password does not forget the failures before the code is checked
+		_, challenge := ts.signIn(t, "user@example.com", "right password")
+		if challenge.ResponseType != "TWO_FACTOR_REQUIRED" {
+			t.Fatalf("sign in = %s, want TWO_FACTOR_REQUIRED", challenge.ResponseType)
+		}
+
+		if _, resp := ts.twoFactorSignIn(t, challenge.ChallengeToken, "wrong-code"); resp.ResponseType != "INVALID_CODE" {
+			t.Fatalf("two-factor sign in = %s, want INVALID_CODE", resp.ResponseType)
+		}
+	}
+
+	if _, resp := ts.signIn(t, "user@example.com", "right password"); resp.ResponseType != "ACCOUNT_LOCKED" {
+		t.Errorf("sign in after three failures = %s, want ACCOUNT_LOCKED", resp.ResponseType)
+	}
+}
+
+func TestTheSecondFactorForgetsTheFailures(t *testing.T) {
+
+	ts := newLockoutService(t, config.Lockout{
+		Window:           time.Hour,
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		Duration:         15 * time.Minute,
+	})
+	user := ts.newUser(t, "user@example.com", "right password")
+	ts.enableTwoFactor(t, user, "right-code")
+
+	ts.signIn(t, "user@example.com", "wrong password")
+	ts.signIn(t, "user@example.com", "wrong password")
+
+	_, challenge := ts.signIn(t, "user@example.com", "right password")
+
+	attempts, _ := ts.lockout.Attempts(context.Background(), accountLockoutKey("user@example.com"))
+	if attempts.Failures != 2 {
+		t.Errorf("failures while the code is pending = %d, want 2", attempts.Failures)
+	}
+
+	if status, resp := ts.twoFactorSignIn(t, challenge.ChallengeToken, "right-code"); status != http.StatusOK || resp.Token == "" {
+		t.Fatalf("two-factor sign in = %d %s, want the tokens", status, resp.ResponseType)
+	}
+
+	attempts, _ = ts.lockout.Attempts(context.Background(), accountLockoutKey("user@example.com"))
+	if attempts.Failures != 0 {
+		t.Errorf("failures after the second factor = %d, want none", attempts.Failures)
+	}
+}
+
+// enableTwoFactor enables the two-factor authentication of the user with a single recovery code
+func (ts *testService) enableTwoFactor(t *testing.T, user *models.UserInfo, recoveryCode string) {
+
+	ctx := context.Background()
+
+	if err := ts.store.CreateTwoFactor(ctx, &models.TwoFactor{User_UUID: user.UUID, Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatalf("CreateTwoFactor: %v", err)
+	}
+
+	codes := []models.RecoveryCode{{Hash: hashToken(normalizeRecoveryCode(recoveryCode))}}
+	if err := ts.store.EnableTwoFactor(ctx, user.UUID, ts.clock.Now(), codes); err != nil {
+		t.Fatalf("EnableTwoFactor: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package twitter
+
+import (
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Logout revokes the access token of the request until it expires,
+// and the family of the refresh token when one is given
+func (s *twitterClone) Logout(c *fiber.Ctx, req models.LogoutRequest) *models.BaseResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	var err error
+	if tokenClaims.Id != "" {
+		err = s.revocations.RevokeToken(c.UserContext(), tokenClaims.Id, time.Unix(tokenClaims.ExpiresAt, 0))
+	} else {
+		// the tokens issued before they had an ID can only be revoked along with every other token of the user
+		err = s.revocations.RevokeUser(c.UserContext(), tokenClaims.User_UUID, s.clock.Now())
+	}
+
+	if err != nil {
+		return revocationFailed()
+	}
+
+	if req.RefreshToken == "" {
+		return &models.BaseResponse{
+			Success: true,
+		}
+	}
+
+	// using the refresh token makes it worthless even when revoking its family fails below
+	refreshToken, err2 := s.refreshTokens.UseRefreshToken(c.UserContext(), hashToken(req.RefreshToken))
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return resp
+	}
+
+	if err2 != nil && err2 != db.ErrNotFound && err2 != db.ErrAlreadyUsed {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Finding the refresh token in the db failed.",
+		}
+	}
+
+	// only the refresh tokens of the same user are revoked
+	if err2 == db.ErrNotFound || refreshToken.User_UUID != tokenClaims.User_UUID {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "INVALID_REFRESH_TOKEN",
+			Msg:          "Logged out, but the refresh token is invalid.",
+		}
+	}
+
+	err3 := s.refreshTokens.RevokeRefreshTokenFamily(c.UserContext(), refreshToken.Family_ID)
+	if resp := timeoutResponse(c, err3); resp != nil {
+		return resp
+	}
+
+	if err3 != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Revoking the refresh tokens failed.",
+		}
+	}
+
+	return &models.BaseResponse{
+		Success: true,
+	}
+}
+
+// LogoutAll revokes every access, refresh and personal access token of the user issued so far, on every device
+func (s *twitterClone) LogoutAll(c *fiber.Ctx, req models.BaseRequest) *models.BaseResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	err := s.revocations.RevokeUser(c.UserContext(), tokenClaims.User_UUID, s.clock.Now())
+	if err != nil {
+		return revocationFailed()
+	}
+
+	return &models.BaseResponse{
+		Success: true,
+	}
+}
+
+func revocationFailed() *models.BaseResponse {
+
+	return &models.BaseResponse{
+		Success:      false,
+		ResponseType: "UNKNOWN_ERROR",
+		Msg:          "Revoking the tokens failed.",
+	}
+}
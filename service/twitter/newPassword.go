@@ -1,12 +1,12 @@
 package twitter
 
 import (
+	"github.com/Bruary/twitter-clone/db"
 	"github.com/Bruary/twitter-clone/service/models"
-	"github.com/Bruary/twitter-clone/validate"
 	"github.com/gofiber/fiber/v2"
 )
 
-func (*twitterClone) NewPassword(c *fiber.Ctx) *models.BaseResponse {
+func (s *twitterClone) NewPassword(c *fiber.Ctx) *models.BaseResponse {
 
 	token := c.Query("token")
 
@@ -18,7 +18,21 @@ func (*twitterClone) NewPassword(c *fiber.Ctx) *models.BaseResponse {
 		}
 	}
 
-	if isTokenValid := validate.IsTokenValid(token); !isTokenValid {
+	// the link is only checked here, it is used up once the new password is set
+	reset, err1 := s.passwordResets.GetPasswordReset(c.UserContext(), hashToken(token))
+	if resp := timeoutResponse(c, err1); resp != nil {
+		return resp
+	}
+
+	if err1 != nil && err1 != db.ErrNotFound {
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Finding the reset token in the db failed.",
+		}
+	}
+
+	if err1 == db.ErrNotFound || reset.Used || reset.Invalidated || !s.clock.Now().Before(reset.Expires_At) {
 		return &models.BaseResponse{
 			Success:      true,
 			ResponseType: "INVALID_TOKEN",
@@ -30,7 +44,7 @@ func (*twitterClone) NewPassword(c *fiber.Ctx) *models.BaseResponse {
 	// 3) send the JWT to the frontend
 	// 4) get the new password from the frontend along with the JWT and update the password
 
-	err := c.Redirect("http://localhost:3000/api/v1/auth/resetPassword/newPassword?token=" + token)
+	err := c.Redirect(s.config.HTTP.FrontendURL + "/api/v1/auth/resetPassword/newPassword?token=" + token)
 	if err != nil {
 		return &models.BaseResponse{
 			Success:      false,
@@ -0,0 +1,430 @@
+package twitter
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/oidc"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// oidcStateCookie holds the state of the login in the browser that started it,
+// so that a callback can not be forged with the state of someone else's login
+const oidcStateCookie = "oidc_state"
+
+// OIDCLogin sends the user to sign in with the provider
+func (s *twitterClone) OIDCLogin(c *fiber.Ctx) *models.BaseResponse {
+
+	provider, resp := s.oidcProvider(c)
+	if resp != nil {
+		return resp
+	}
+
+	state, err := s.oidcStates.New(provider.Name())
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Creating the state of the login failed.",
+		}
+	}
+
+	authURL, err2 := provider.AuthCodeURL(c.UserContext(), state, s.oidcStates.Nonce(state), oidc.CodeChallenge(s.oidcStates.CodeVerifier(state)))
+	if err2 != nil {
+
+		s.logger.Println("Starting the login with "+provider.Name()+" failed:", err2)
+
+		return providerUnavailable(c)
+	}
+
+	s.setOIDCStateCookie(c, provider.Name(), state, s.clock.Now().Add(s.config.OIDC.LoginTTL))
+
+	err3 := c.Redirect(authURL)
+	if err3 != nil {
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Failed to redirect to a new page.",
+		}
+	}
+
+	return &models.BaseResponse{
+		Success: true,
+	}
+}
+
+// OIDCCallback finishes the login the provider sends the user back from, and signs the user in
+// like SignIn does. The account is found by the identity at the provider, then by the verified email,
+// and is created when there is none.
+func (s *twitterClone) OIDCCallback(c *fiber.Ctx) *models.SignInResponse {
+
+	provider, resp := s.oidcProvider(c)
+	if resp != nil {
+		return &models.SignInResponse{BaseResponse: *resp}
+	}
+
+	// the state is single use, whatever happens next
+	cookieState := c.Cookies(oidcStateCookie)
+	s.setOIDCStateCookie(c, provider.Name(), "", time.Unix(0, 0))
+
+	if c.Query("error") != "" {
+
+		c.Status(fiber.StatusUnauthorized)
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "OIDC_LOGIN_FAILED",
+				Msg:          "The provider did not sign you in: " + c.Query("error"),
+			},
+		}
+	}
+
+	state := c.Query("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 || s.oidcStates.Check(state, provider.Name()) != nil {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "INVALID_STATE",
+				Msg:          "The login is invalid or expired, start it again.",
+			},
+		}
+	}
+
+	code := c.Query("code")
+	if code == "" {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field code is missing, or empty.",
+			},
+		}
+	}
+
+	idToken, err := provider.Exchange(c.UserContext(), code, s.oidcStates.CodeVerifier(state), s.oidcStates.Nonce(state))
+	if errors.Is(err, oidc.ErrInvalidIDToken) {
+
+		s.logger.Println("The ID token of "+provider.Name()+" was refused:", err)
+
+		c.Status(fiber.StatusUnauthorized)
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "INVALID_ID_TOKEN",
+				Msg:          "The provider sent an invalid ID token.",
+			},
+		}
+	}
+
+	if err != nil {
+
+		s.logger.Println("Exchanging the code with "+provider.Name()+" failed:", err)
+
+		return &models.SignInResponse{BaseResponse: *providerUnavailable(c)}
+	}
+
+	user, resp2 := s.oidcUser(c, provider.Name(), idToken)
+	if resp2 != nil {
+		return &models.SignInResponse{BaseResponse: *resp2}
+	}
+
+	// the provider does not stand in for the second factor
+	if challenge := s.twoFactorChallenge(c, user); challenge != nil {
+		return challenge
+	}
+
+	// every sign in starts a new family of refresh tokens
+	return s.issueTokens(c, user.UUID, user.Account_ID, s.ids.NewUUID())
+}
+
+// oidcUser returns the user the identity at the provider belongs to, linking or creating it on the first sign in
+func (s *twitterClone) oidcUser(c *fiber.Ctx, provider string, idToken *oidc.IDToken) (*models.UserInfo, *models.BaseResponse) {
+
+	identity, err := s.identities.GetIdentity(c.UserContext(), provider, idToken.Subject)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return nil, resp
+	}
+
+	if err != nil && err != db.ErrNotFound {
+
+		return nil, &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Finding the identity in the db failed.",
+		}
+	}
+
+	if err == nil {
+
+		user, err2 := s.users.GetUserByUUID(c.UserContext(), identity.User_UUID)
+		if resp := timeoutResponse(c, err2); resp != nil {
+			return nil, resp
+		}
+
+		if err2 == db.ErrNotFound {
+
+			c.Status(fiber.StatusNotFound)
+
+			return nil, &models.BaseResponse{
+				Success:      false,
+				ResponseType: "USER_NOT_FOUND",
+				Msg:          "The account of this identity does not exist anymore.",
+			}
+		}
+
+		if err2 != nil {
+
+			return nil, &models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Finding the user document in the DB failed.",
+			}
+		}
+
+		return user, nil
+	}
+
+	// only an email the provider checked may take over or create the account of that email
+	if idToken.Email == "" || !idToken.EmailVerified {
+
+		c.Status(fiber.StatusForbidden)
+
+		return nil, &models.BaseResponse{
+			Success:      false,
+			ResponseType: "EMAIL_NOT_VERIFIED",
+			Msg:          "The provider has not verified the email of your account.",
+		}
+	}
+
+	user, err3 := s.users.GetUserByEmail(c.UserContext(), idToken.Email)
+	if resp := timeoutResponse(c, err3); resp != nil {
+		return nil, resp
+	}
+
+	if err3 != nil && err3 != db.ErrNotFound {
+
+		return nil, &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Finding the user document in the DB failed.",
+		}
+	}
+
+	if err3 == db.ErrNotFound {
+		user, resp := s.createOIDCUser(c, idToken)
+		if resp != nil {
+			return nil, resp
+		}
+
+		return user, s.linkIdentity(c, provider, idToken.Subject, user.UUID)
+	}
+
+	if user.Verified_At == nil {
+		if resp := s.claimUnverifiedAccount(c, user); resp != nil {
+			return nil, resp
+		}
+	}
+
+	return user, s.linkIdentity(c, provider, idToken.Subject, user.UUID)
+}
+
+// claimUnverifiedAccount hands the account to the owner of its email. Whoever signed up with it
+// never proved owning the email, so the password they set is removed and their sessions are revoked.
+func (s *twitterClone) claimUnverifiedAccount(c *fiber.Ctx, user *models.UserInfo) *models.BaseResponse {
+
+	err := s.users.UpdatePassword(c.UserContext(), user.UUID, "")
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Removing the password of the account failed.",
+		}
+	}
+
+	err2 := s.revocations.RevokeUser(c.UserContext(), user.UUID, s.clock.Now())
+	if err2 != nil {
+		return revocationFailed()
+	}
+
+	err3 := s.revokeAccessTokens(c, user.UUID)
+	if err3 != nil {
+		return revocationFailed()
+	}
+
+	s.recordAudit(c, user.UUID, models.AuditSessionsRevoked)
+
+	user.Password = ""
+
+	if resp := s.setEmailVerified(c, user.UUID, models.AuditEmailVerified); !resp.Success {
+		return resp
+	}
+
+	return nil
+}
+
+// createOIDCUser creates the account of a new user, it has no password and its email is verified
+func (s *twitterClone) createOIDCUser(c *fiber.Ctx, idToken *oidc.IDToken) (*models.UserInfo, *models.BaseResponse) {
+
+	accountID, err := s.ids.NewAccountID()
+	if err != nil {
+
+		return nil, &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Creating account ID failed.",
+		}
+	}
+
+	firstName, lastName := idToken.GivenName, idToken.FamilyName
+	if firstName == "" && lastName == "" {
+		firstName, lastName = splitName(idToken.Name)
+	}
+
+	if firstName == "" {
+		firstName = idToken.Email[:strings.Index(idToken.Email+"@", "@")]
+	}
+
+	now := s.clock.Now()
+
+	user := &models.UserInfo{
+		UUID:        s.ids.NewUUID(),
+		Account_ID:  accountID,
+		FirstName:   firstName,
+		LastName:    lastName,
+		Email:       idToken.Email,
+		Created_At:  now,
+		Updated_At:  now,
+		Verified_At: &now,
+	}
+
+	err2 := s.users.CreateUser(c.UserContext(), user)
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return nil, resp
+	}
+
+	// the email was taken by a concurrent signup since it was looked up
+	if err2 == db.ErrDuplicate {
+
+		c.Status(fiber.StatusConflict)
+
+		return nil, &models.BaseResponse{
+			Success:      false,
+			ResponseType: "USER_ALREADY_EXISTS",
+			Msg:          "User's email already exists, sign in again.",
+		}
+	}
+
+	if err2 != nil {
+
+		return nil, &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Inserting new user to the db failed.",
+		}
+	}
+
+	return user, nil
+}
+
+// linkIdentity links the identity at the provider to the user, or returns the response to send
+func (s *twitterClone) linkIdentity(c *fiber.Ctx, provider string, subject string, userUUID string) *models.BaseResponse {
+
+	err := s.identities.CreateIdentity(c.UserContext(), &models.Identity{
+		Provider:   provider,
+		Subject:    subject,
+		User_UUID:  userUUID,
+		Created_At: s.clock.Now(),
+	})
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	// a concurrent callback of the same identity linked it already
+	if err == db.ErrDuplicate {
+		return nil
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Saving the identity to the db failed.",
+		}
+	}
+
+	s.recordAudit(c, userUUID, models.AuditIdentityLinked)
+
+	return nil
+}
+
+// oidcProvider returns the provider of the route, or the response to send when it is not configured
+func (s *twitterClone) oidcProvider(c *fiber.Ctx) (*oidc.Provider, *models.BaseResponse) {
+
+	provider, found := s.oidcProviders[c.Params("provider")]
+	if !found {
+
+		c.Status(fiber.StatusNotFound)
+
+		return nil, &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_PROVIDER",
+			Msg:          "There is no such identity provider.",
+		}
+	}
+
+	return provider, nil
+}
+
+func (s *twitterClone) setOIDCStateCookie(c *fiber.Ctx, provider string, state string, expires time.Time) {
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/oidc/" + provider,
+		Expires:  expires,
+		Secure:   strings.HasPrefix(s.config.HTTP.PublicURL, "https://"),
+		HTTPOnly: true,
+		SameSite: "Lax", // the callback is a top level navigation from the provider
+	})
+}
+
+// splitName splits the full name of the ID tokens without the given and family names
+func splitName(name string) (string, string) {
+
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	return fields[0], strings.Join(fields[1:], " ")
+}
+
+func providerUnavailable(c *fiber.Ctx) *models.BaseResponse {
+
+	c.Status(fiber.StatusBadGateway)
+
+	return &models.BaseResponse{
+		Success:      false,
+		ResponseType: "PROVIDER_UNAVAILABLE",
+		Msg:          "The identity provider could not be reached, try again later.",
+	}
+}
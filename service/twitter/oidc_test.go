@@ -0,0 +1,295 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/oidc"
+	"github.com/Bruary/twitter-clone/oidc/oidctest"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// oidcService is the service with the fake provider "test"
+type oidcService struct {
+	*testService
+	provider *oidctest.Provider
+}
+
+func newOIDCService(t *testing.T) *oidcService {
+
+	cfg := config.Default()
+	cfg.Password.BcryptCost = bcrypt.MinCost
+	cfg.OIDC.StateSecret = "a state secret of at least 32 characters"
+
+	ts := newTestService(t, Options{Config: cfg})
+	fake := oidctest.NewProvider(t, "twitter-clone", "client secret", ts.clock)
+
+	ts.oidcProviders["test"] = oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       fake.Issuer(),
+		ClientID:     fake.ClientID,
+		ClientSecret: fake.ClientSecret,
+		RedirectURL:  "http://localhost/api/v1/auth/oidc/test/callback",
+	}, fake.Server.Client(), ts.clock)
+
+	ts.app.Get("/oidc/:provider/login", func(c *fiber.Ctx) error {
+		if resp := ts.OIDCLogin(c); !resp.Success {
+			return c.JSON(resp)
+		}
+
+		return nil
+	})
+
+	ts.app.Get("/oidc/:provider/callback", func(c *fiber.Ctx) error {
+		return c.JSON(ts.OIDCCallback(c))
+	})
+
+	ts.route("/signin", func(c *fiber.Ctx) interface{} {
+		var req models.SignInRequest
+		c.BodyParser(&req)
+		return ts.SignIn(c, req)
+	})
+
+	return &oidcService{testService: ts, provider: fake}
+}
+
+// oidcLogin starts a login and returns where the user is sent and the state cookie
+func (ts *oidcService) oidcLogin(t *testing.T) (string, *http.Cookie) {
+
+	resp, err := ts.app.Test(httptest.NewRequest("GET", "/oidc/test/login", nil), -1)
+	if err != nil {
+		t.Fatalf("GET login: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("GET login = %d, want a redirect to the provider", resp.StatusCode)
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == oidcStateCookie {
+			return resp.Header.Get("Location"), cookie
+		}
+	}
+
+	t.Fatal("GET login set no state cookie")
+
+	return "", nil
+}
+
+// oidcSignIn signs in with the provider as the user of the claims, on top of the ones of a valid ID token
+func (ts *oidcService) oidcSignIn(t *testing.T, subject string, claims jwt.MapClaims) (int, models.SignInResponse) {
+
+	authURL, cookie := ts.oidcLogin(t)
+
+	idClaims := ts.provider.Claims(t, authURL, subject)
+	for key, value := range claims {
+		idClaims[key] = value
+	}
+
+	return ts.callback(t, authURL, cookie, ts.provider.Login(t, authURL, idClaims))
+}
+
+func (ts *oidcService) callback(t *testing.T, authURL string, cookie *http.Cookie, code string) (int, models.SignInResponse) {
+
+	parsed, _ := url.Parse(authURL)
+
+	query := url.Values{}
+	query.Set("state", parsed.Query().Get("state"))
+	query.Set("code", code)
+
+	req := httptest.NewRequest("GET", "/oidc/test/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := ts.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET callback: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var signIn models.SignInResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signIn); err != nil {
+		t.Fatalf("decoding the callback: %v", err)
+	}
+
+	return resp.StatusCode, signIn
+}
+
+// oidcSignedIn signs in with the provider like oidcSignIn and returns the user the access token is for
+func (ts *oidcService) oidcSignedIn(t *testing.T, subject string, claims jwt.MapClaims) string {
+
+	status, resp := ts.oidcSignIn(t, subject, claims)
+	if status != http.StatusOK || resp.Token == "" {
+		t.Fatalf("OIDC sign in = %d %s %s, want the tokens", status, resp.ResponseType, resp.Msg)
+	}
+
+	tokenClaims, err := ts.tokens.ParseToken(resp.Token, models.PurposeAccess)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+
+	return tokenClaims.User_UUID
+}
+
+func verifiedEmail(email string) jwt.MapClaims {
+	return jwt.MapClaims{"email": email, "email_verified": true, "name": "Ada Lovelace"}
+}
+
+func TestOIDCCreatesTheAccountOfANewEmail(t *testing.T) {
+
+	ts := newOIDCService(t)
+	ctx := context.Background()
+
+	userUUID := ts.oidcSignedIn(t, "subject", verifiedEmail("new@example.com"))
+
+	user, err := ts.store.GetUserByUUID(ctx, userUUID)
+	if err != nil {
+		t.Fatalf("GetUserByUUID: %v", err)
+	}
+
+	if user.Email != "new@example.com" || user.Verified_At == nil || user.Password != "" || user.FirstName != "Ada" || user.LastName != "Lovelace" {
+		t.Errorf("created user = %+v, want a verified account of the email without a password", user)
+	}
+
+	// the identity finds the account from then on, whatever email the provider says
+	again := ts.oidcSignedIn(t, "subject", verifiedEmail("changed@example.com"))
+	if again != userUUID {
+		t.Errorf("second sign in of the identity signed in %s, want %s", again, userUUID)
+	}
+}
+
+func TestOIDCLinksTheAccountOfAVerifiedEmail(t *testing.T) {
+
+	ts := newOIDCService(t)
+	ctx := context.Background()
+
+	user := ts.newUser(t, "user@example.com", "right password")
+	if err := ts.store.SetEmailVerified(ctx, user.UUID, ts.clock.Now()); err != nil {
+		t.Fatalf("SetEmailVerified: %v", err)
+	}
+
+	if got := ts.oidcSignedIn(t, "subject", verifiedEmail("user@example.com")); got != user.UUID {
+		t.Errorf("OIDC sign in signed in %s, want the account of the email %s", got, user.UUID)
+	}
+
+	identity, err := ts.store.GetIdentity(ctx, "test", "subject")
+	if err != nil || identity.User_UUID != user.UUID {
+		t.Errorf("GetIdentity = %+v, %v, want the identity linked to %s", identity, err, user.UUID)
+	}
+
+	// the owner of a verified account keeps their password
+	var resp models.SignInResponse
+	if status := ts.post(t, "/signin", models.SignInRequest{Email: "user@example.com", Password: "right password"}, &resp); status != http.StatusOK {
+		t.Errorf("password sign in after linking = %d %s, want the tokens", status, resp.ResponseType)
+	}
+}
+
+func TestOIDCClaimsAnUnverifiedAccount(t *testing.T) {
+
+	ts := newOIDCService(t)
+	ctx := context.Background()
+
+	// signed up by someone who never proved owning the email
+	squatter := ts.newUser(t, "user@example.com", "squatter password")
+
+	if got := ts.oidcSignedIn(t, "subject", verifiedEmail("user@example.com")); got != squatter.UUID {
+		t.Errorf("OIDC sign in signed in %s, want the account of the email %s", got, squatter.UUID)
+	}
+
+	user, err := ts.store.GetUserByUUID(ctx, squatter.UUID)
+	if err != nil {
+		t.Fatalf("GetUserByUUID: %v", err)
+	}
+
+	if user.Password != "" || user.Verified_At == nil {
+		t.Errorf("claimed account = %+v, want it verified and without the password of the squatter", user)
+	}
+}
+
+func TestOIDCRefusesUnverifiedEmails(t *testing.T) {
+
+	ts := newOIDCService(t)
+	ctx := context.Background()
+
+	user := ts.newUser(t, "user@example.com", "right password")
+	ts.store.SetEmailVerified(ctx, user.UUID, ts.clock.Now())
+
+	for _, claims := range []jwt.MapClaims{
+		{"email": "user@example.com", "email_verified": false},
+		{"email": "user@example.com"},
+		{"email": "user@example.com", "email_verified": "false"},
+	} {
+		status, resp := ts.oidcSignIn(t, "subject", claims)
+		if status != http.StatusForbidden || resp.ResponseType != "EMAIL_NOT_VERIFIED" {
+			t.Errorf("OIDC sign in with %v = %d %s, want 403 EMAIL_NOT_VERIFIED", claims, status, resp.ResponseType)
+		}
+	}
+
+	if _, err := ts.store.GetIdentity(ctx, "test", "subject"); err != db.ErrNotFound {
+		t.Errorf("GetIdentity after refused sign ins = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOIDCCallbackChecksTheLogin(t *testing.T) {
+
+	ts := newOIDCService(t)
+
+	// the callback of a login started in another browser
+	authURL, _ := ts.oidcLogin(t)
+	code := ts.provider.Login(t, authURL, ts.provider.Claims(t, authURL, "subject"))
+
+	if status, resp := ts.callback(t, authURL, nil, code); status != http.StatusBadRequest || resp.ResponseType != "INVALID_STATE" {
+		t.Errorf("callback without the state cookie = %d %s, want 400 INVALID_STATE", status, resp.ResponseType)
+	}
+
+	// an ID token replayed from another login
+	authURL, cookie := ts.oidcLogin(t)
+	other, _ := ts.oidcLogin(t)
+
+	claims := ts.provider.Claims(t, other, "subject")
+	claims["email"] = "user@example.com"
+	claims["email_verified"] = true
+
+	code = ts.provider.Issue(t, mustQuery(t, authURL).Get("code_challenge"), ts.provider.Sign(t, claims))
+
+	if status, resp := ts.callback(t, authURL, cookie, code); status != http.StatusUnauthorized || resp.ResponseType != "INVALID_ID_TOKEN" {
+		t.Errorf("callback with the ID token of another login = %d %s, want 401 INVALID_ID_TOKEN", status, resp.ResponseType)
+	}
+}
+
+func TestOIDCTwoFactor(t *testing.T) {
+
+	ts := newOIDCService(t)
+	ctx := context.Background()
+
+	user := ts.newUser(t, "user@example.com", "right password")
+	ts.store.SetEmailVerified(ctx, user.UUID, ts.clock.Now())
+	ts.enableTwoFactor(t, user, "right-code")
+
+	// the provider does not stand in for the second factor
+	status, resp := ts.oidcSignIn(t, "subject", verifiedEmail("user@example.com"))
+	if status != http.StatusOK || resp.ResponseType != "TWO_FACTOR_REQUIRED" || resp.Token != "" {
+		t.Errorf("OIDC sign in with two-factor authentication = %d %s, want TWO_FACTOR_REQUIRED without the tokens", status, resp.ResponseType)
+	}
+}
+
+func mustQuery(t *testing.T, rawURL string) url.Values {
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", rawURL, err)
+	}
+
+	return parsed.Query()
+}
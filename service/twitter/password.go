@@ -0,0 +1,99 @@
+package twitter
+
+import (
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/password"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func passwordParams(cfg config.Password) password.Params {
+	return password.Params{
+		Algorithm:     cfg.Algorithm,
+		BcryptCost:    cfg.BcryptCost,
+		Argon2Time:    uint32(cfg.Argon2Time),
+		Argon2Memory:  uint32(cfg.Argon2Memory),
+		Argon2Threads: uint8(cfg.Argon2Threads),
+	}
+}
+
+// doPasswordsMatch checks the password against the saved hash, a hash that can not be read never matches
+func (s *twitterClone) doPasswordsMatch(pw string, savedPassword string) bool {
+
+	// the accounts created through an identity provider have no password until they reset it
+	if savedPassword == "" {
+		return false
+	}
+
+	ok, err := s.passwords.Verify(pw, savedPassword)
+	if err != nil {
+		s.logger.Println("Verifying a password failed:", err)
+		return false
+	}
+
+	return ok
+}
+
+// checkPasswordPolicy returns the response to send when the password breaks the policy, or nil.
+// The personal info is the email and the names of the user.
+func (s *twitterClone) checkPasswordPolicy(c *fiber.Ctx, pw string, personalInfo ...string) *models.PasswordResponse {
+
+	violations := s.passwordPolicy.Check(pw, personalInfo...)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	c.Status(fiber.StatusBadRequest)
+
+	return &models.PasswordResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      false,
+			ResponseType: "PASSWORD_POLICY_VIOLATION",
+			Msg:          violations[0].Msg,
+		},
+		Violations: violations,
+	}
+}
+
+// dummyPasswordHash is checked against for the unknown emails,
+// so that they take as long to refuse as a wrong password
+func (s *twitterClone) dummyPasswordHash() string {
+
+	s.dummyHashOnce.Do(func() {
+		hash, err := s.passwords.Hash("not the password of anyone")
+		if err != nil {
+			s.logger.Println("Hashing the dummy password failed:", err)
+		}
+
+		s.dummyHash = hash
+	})
+
+	return s.dummyHash
+}
+
+// rehashPassword saves a new hash of the password when the saved one was made with outdated params,
+// so that the hashes are upgraded as the users sign in. A failure is logged but does not fail the sign in.
+func (s *twitterClone) rehashPassword(c *fiber.Ctx, user *models.UserInfo, pw string) {
+
+	if !s.passwords.NeedsRehash(user.Password) {
+		return
+	}
+
+	// bcrypt would only keep the start of a longer password, its current hash is kept instead
+	newHash, err := s.passwords.Hash(pw)
+	if err == password.ErrTooLong {
+		return
+	}
+
+	if err != nil {
+		s.logger.Println("Rehashing the password of user "+user.UUID+" failed:", err)
+		return
+	}
+
+	// the password may have been reset since it was checked, the new one must not be overwritten
+	err2 := s.users.RehashPassword(c.UserContext(), user.UUID, user.Password, newHash)
+	if err2 != nil && err2 != db.ErrNotFound {
+		s.logger.Println("Saving the rehashed password of user "+user.UUID+" failed:", err2)
+	}
+}
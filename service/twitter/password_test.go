@@ -0,0 +1,98 @@
+package twitter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/password"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/validate"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptPasswordsAreLimitedInBytes(t *testing.T) {
+
+	// 60 characters, 120 bytes
+	long := strings.Repeat("é", 60)
+
+	bcryptConfig := config.Default()
+	bcryptConfig.Password.BcryptCost = bcrypt.MinCost
+
+	argon2Config := config.Default()
+	argon2Config.Password.Algorithm = password.Argon2id
+
+	for _, tt := range []struct {
+		name       string
+		config     *config.Config
+		violations int
+	}{
+		{"bcrypt", bcryptConfig, 1},
+		{"argon2id", argon2Config, 0},
+	} {
+		ts := newTestService(t, Options{Config: tt.config})
+
+		violations := ts.passwordPolicy.Check(long)
+		if len(violations) != tt.violations || (tt.violations > 0 && violations[0].Rule != validate.RuleMaxLength) {
+			t.Errorf("%s: Check of a password of %d bytes = %+v, want %d max_length violations", tt.name, len(long), violations, tt.violations)
+		}
+	}
+
+	hasher, err := password.New(password.Params{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := hasher.Hash(long); err != password.ErrTooLong {
+		t.Errorf("bcrypt Hash of a password of %d bytes = %v, want ErrTooLong", len(long), err)
+	}
+}
+
+func TestSignInRehashesOutdatedPasswords(t *testing.T) {
+
+	cfg := config.Default()
+	cfg.Password.Algorithm = password.Argon2id
+	cfg.Password.Argon2Time = 1
+	cfg.Password.Argon2Memory = 64
+	cfg.Password.Argon2Threads = 1
+
+	ts := newTestService(t, Options{Config: cfg})
+	ts.route("/signin", func(c *fiber.Ctx) interface{} {
+		var req models.SignInRequest
+		c.BodyParser(&req)
+		return ts.SignIn(c, req)
+	})
+
+	// hashed before the switch to argon2id
+	bcryptHasher, _ := password.New(password.Params{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost})
+	oldHash, _ := bcryptHasher.Hash("right password")
+
+	user := ts.newUser(t, "user@example.com", "right password")
+	if err := ts.store.UpdatePassword(context.Background(), user.UUID, oldHash); err != nil {
+		t.Fatalf("UpdatePassword: %v", err)
+	}
+
+	// a wrong password keeps the hash
+	ts.signIn(t, "user@example.com", "wrong password")
+
+	if saved, _ := ts.store.GetUserByUUID(context.Background(), user.UUID); saved.Password != oldHash {
+		t.Error("a failed sign in replaced the hash")
+	}
+
+	ts.clock.Advance(cfg.Lockout.MaxDelay)
+
+	if _, resp := ts.signIn(t, "user@example.com", "right password"); resp.Token == "" {
+		t.Fatalf("sign in with the bcrypt hash = %s, want the tokens", resp.ResponseType)
+	}
+
+	saved, _ := ts.store.GetUserByUUID(context.Background(), user.UUID)
+	if !strings.HasPrefix(saved.Password, "$argon2id$") || ts.passwords.NeedsRehash(saved.Password) {
+		t.Errorf("hash after the sign in = %q, want an argon2id hash of the current params", saved.Password)
+	}
+
+	if _, resp := ts.signIn(t, "user@example.com", "right password"); resp.Token == "" {
+		t.Errorf("sign in with the new hash = %s, want the tokens", resp.ResponseType)
+	}
+}
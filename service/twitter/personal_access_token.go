@@ -0,0 +1,316 @@
+package twitter
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/pat"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/validate"
+	"github.com/gofiber/fiber/v2"
+)
+
+// accessTokenNameMaxLength is the longest name of a personal access token, in characters
+const accessTokenNameMaxLength = 100
+
+// accessTokenMaxDays bounds the lifetime of the tokens that expire, a longer one should never expire instead
+const accessTokenMaxDays = 3650
+
+// CreatePersonalAccessToken creates a long lived token with the given scopes, the token is only shown in the response
+func (s *twitterClone) CreatePersonalAccessToken(c *fiber.Ctx, req models.CreatePersonalAccessTokenRequest) *models.PersonalAccessTokenResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	if validate.IsStringEmpty(req.Name) {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.PersonalAccessTokenResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field name is missing, or empty.",
+			},
+		}
+	}
+
+	if utf8.RuneCountInString(req.Name) > accessTokenNameMaxLength {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.PersonalAccessTokenResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_ERROR",
+				Msg:          fmt.Sprintf("Field name can not be longer than %d characters.", accessTokenNameMaxLength),
+			},
+		}
+	}
+
+	scopes, resp := checkScopes(c, req.Scopes)
+	if resp != nil {
+		return &models.PersonalAccessTokenResponse{BaseResponse: *resp}
+	}
+
+	if resp := s.checkAccessTokenLifetime(c, req.ExpiresInDays); resp != nil {
+		return &models.PersonalAccessTokenResponse{BaseResponse: *resp}
+	}
+
+	existing, err := s.accessTokens.ListPersonalAccessTokens(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.PersonalAccessTokenResponse{BaseResponse: *resp}
+	}
+
+	if err != nil {
+
+		return &models.PersonalAccessTokenResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Finding the personal access tokens in the db failed.",
+			},
+		}
+	}
+
+	now := s.clock.Now()
+
+	active := 0
+	for _, accessToken := range existing {
+		if accessToken.Revoked_At == nil && (accessToken.Expires_At == nil || now.Before(*accessToken.Expires_At)) {
+			active++
+		}
+	}
+
+	if active >= s.config.AccessTokens.MaxPerUser {
+
+		c.Status(fiber.StatusForbidden)
+
+		return &models.PersonalAccessTokenResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "TOO_MANY_TOKENS",
+				Msg:          fmt.Sprintf("You can have at most %d personal access tokens, revoke one first.", s.config.AccessTokens.MaxPerUser),
+			},
+		}
+	}
+
+	token, hash, err2 := pat.New()
+	if err2 != nil {
+
+		return &models.PersonalAccessTokenResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Creating the token failed.",
+			},
+		}
+	}
+
+	accessToken := &models.PersonalAccessToken{
+		ID:         s.ids.NewUUID(),
+		User_UUID:  tokenClaims.User_UUID,
+		Account_ID: tokenClaims.Account_ID,
+		Name:       req.Name,
+		Hash:       hash,
+		Scopes:     scopes,
+		Created_At: now,
+	}
+
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		accessToken.Expires_At = &expiresAt
+	}
+
+	err3 := s.accessTokens.CreatePersonalAccessToken(c.UserContext(), accessToken)
+	if resp := timeoutResponse(c, err3); resp != nil {
+		return &models.PersonalAccessTokenResponse{BaseResponse: *resp}
+	}
+
+	if err3 != nil {
+
+		return &models.PersonalAccessTokenResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Saving the personal access token to the db failed.",
+			},
+		}
+	}
+
+	s.recordAudit(c, tokenClaims.User_UUID, models.AuditAccessTokenCreated)
+
+	return &models.PersonalAccessTokenResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      true,
+			ResponseType: "TOKEN_CREATED",
+			Msg:          "Copy the token now, it is not shown again.",
+		},
+		Token:               token,
+		PersonalAccessToken: accessToken,
+	}
+}
+
+// ListPersonalAccessTokens returns the personal access tokens of the user, without the tokens themselves
+func (s *twitterClone) ListPersonalAccessTokens(c *fiber.Ctx, req models.BaseRequest) *models.PersonalAccessTokensResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	accessTokens, err := s.accessTokens.ListPersonalAccessTokens(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.PersonalAccessTokensResponse{BaseResponse: *resp}
+	}
+
+	if err != nil {
+
+		return &models.PersonalAccessTokensResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Finding the personal access tokens in the db failed.",
+			},
+		}
+	}
+
+	return &models.PersonalAccessTokensResponse{
+		BaseResponse: models.BaseResponse{
+			Success: true,
+		},
+		PersonalAccessTokens: accessTokens,
+	}
+}
+
+// RevokePersonalAccessToken revokes a personal access token of the user, it stops working right away
+func (s *twitterClone) RevokePersonalAccessToken(c *fiber.Ctx, req models.RevokePersonalAccessTokenRequest) *models.BaseResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	if validate.IsStringEmpty(req.ID) {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "FIELD_MISSING",
+			Msg:          "Field id is missing, or empty.",
+		}
+	}
+
+	err := s.accessTokens.RevokePersonalAccessToken(c.UserContext(), tokenClaims.User_UUID, req.ID, s.clock.Now())
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err == db.ErrNotFound {
+
+		c.Status(fiber.StatusNotFound)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "TOKEN_NOT_FOUND",
+			Msg:          "You have no such personal access token.",
+		}
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Revoking the personal access token failed.",
+		}
+	}
+
+	s.recordAudit(c, tokenClaims.User_UUID, models.AuditAccessTokenRevoked)
+
+	return &models.BaseResponse{
+		Success:      true,
+		ResponseType: "TOKEN_REVOKED",
+		Msg:          "The personal access token is revoked.",
+	}
+}
+
+// checkScopes returns the requested scopes without duplicates, or the response to send when one is unknown
+func checkScopes(c *fiber.Ctx, requested []string) ([]string, *models.BaseResponse) {
+
+	if len(requested) == 0 {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return nil, &models.BaseResponse{
+			Success:      false,
+			ResponseType: "FIELD_MISSING",
+			Msg:          "Field scopes is missing, or empty.",
+		}
+	}
+
+	known := map[string]bool{}
+	for _, scope := range models.Scopes {
+		known[scope] = true
+	}
+
+	seen := map[string]bool{}
+
+	var scopes []string
+	for _, scope := range requested {
+
+		if !known[scope] {
+
+			c.Status(fiber.StatusBadRequest)
+
+			return nil, &models.BaseResponse{
+				Success:      false,
+				ResponseType: "INVALID_SCOPE",
+				Msg:          fmt.Sprintf("Unknown scope %q, the scopes are %v.", scope, models.Scopes),
+			}
+		}
+
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes, nil
+}
+
+// checkAccessTokenLifetime returns the response to send when the lifetime is negative or longer than allowed, or nil
+func (s *twitterClone) checkAccessTokenLifetime(c *fiber.Ctx, expiresInDays int) *models.BaseResponse {
+
+	maxTTL := s.config.AccessTokens.MaxTTL
+
+	if expiresInDays < 0 || expiresInDays > accessTokenMaxDays {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "FIELD_ERROR",
+			Msg:          fmt.Sprintf("Field expires_in_days must be between 0, for a token that never expires, and %d.", accessTokenMaxDays),
+		}
+	}
+
+	if maxTTL > 0 && (expiresInDays == 0 || time.Duration(expiresInDays)*24*time.Hour > maxTTL) {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "FIELD_ERROR",
+			Msg:          fmt.Sprintf("Field expires_in_days must be between 1 and %d.", int(maxTTL/(24*time.Hour))),
+		}
+	}
+
+	return nil
+}
+
+// revokeAccessTokens revokes every personal access token of the user,
+// whoever knew the old password may have created some
+func (s *twitterClone) revokeAccessTokens(c *fiber.Ctx, userUUID string) error {
+	return s.accessTokens.RevokePersonalAccessTokens(c.UserContext(), userUUID, s.clock.Now())
+}
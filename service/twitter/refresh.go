@@ -0,0 +1,198 @@
+package twitter
+
+import (
+	"context"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/validate"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Refresh exchanges a refresh token for a new access token and a new refresh token of the same family.
+// A refresh token works once, presenting it again means it leaked, so its whole family is revoked
+// and every device of that sign in has to sign in again.
+func (s *twitterClone) Refresh(c *fiber.Ctx, req models.RefreshRequest) *models.SignInResponse {
+
+	refreshTokenEmpty := validate.IsStringEmpty(req.RefreshToken)
+	if refreshTokenEmpty {
+
+		c.Status(fiber.ErrBadRequest.Code)
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field refresh_token is missing, or empty.",
+			},
+		}
+	}
+
+	refreshToken, err := s.refreshTokens.UseRefreshToken(c.UserContext(), hashToken(req.RefreshToken))
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.SignInResponse{BaseResponse: *resp}
+	}
+
+	// the refresh token of a logout was used to revoke its family, it is no sign of a leak
+	if err == db.ErrAlreadyUsed && refreshToken.Revoked {
+		return invalidRefreshToken(c)
+	}
+
+	if err == db.ErrAlreadyUsed {
+
+		err2 := s.refreshTokens.RevokeRefreshTokenFamily(c.UserContext(), refreshToken.Family_ID)
+		if resp := timeoutResponse(c, err2); resp != nil {
+			return &models.SignInResponse{BaseResponse: *resp}
+		}
+
+		if err2 != nil {
+
+			return &models.SignInResponse{
+				BaseResponse: models.BaseResponse{
+					Success:      false,
+					ResponseType: "UNKNOWN_ERROR",
+					Msg:          "Revoking the refresh tokens failed.",
+				},
+			}
+		}
+
+		s.logger.Println("Refresh token reused, revoked the tokens of family " + refreshToken.Family_ID)
+
+		c.Status(fiber.StatusUnauthorized)
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "REFRESH_TOKEN_REUSED",
+				Msg:          "The refresh token was already used, sign in again.",
+			},
+		}
+	}
+
+	if err != nil && err != db.ErrNotFound {
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Finding the refresh token in the db failed.",
+			},
+		}
+	}
+
+	if err == db.ErrNotFound || refreshToken.Revoked || !s.clock.Now().Before(refreshToken.Expires_At) {
+		return invalidRefreshToken(c)
+	}
+
+	// logging out everywhere, changing the password or deleting the account revokes the refresh tokens too
+	revoked, err1_5 := s.revocations.IsRevoked(c.UserContext(), "", refreshToken.User_UUID, refreshToken.Created_At)
+	if err1_5 != nil {
+		return &models.SignInResponse{BaseResponse: *revocationFailed()}
+	}
+
+	if revoked {
+		return invalidRefreshToken(c)
+	}
+
+	// the account may have been deleted since the sign in
+	_, err2 := s.users.GetUserByUUID(c.UserContext(), refreshToken.User_UUID)
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return &models.SignInResponse{BaseResponse: *resp}
+	}
+
+	if err2 == db.ErrNotFound {
+		return invalidRefreshToken(c)
+	}
+
+	if err2 != nil {
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Failed while finding user in db.",
+			},
+		}
+	}
+
+	return s.issueTokens(c, refreshToken.User_UUID, refreshToken.Account_ID, refreshToken.Family_ID)
+}
+
+func invalidRefreshToken(c *fiber.Ctx) *models.SignInResponse {
+
+	c.Status(fiber.StatusUnauthorized)
+
+	return &models.SignInResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      false,
+			ResponseType: "INVALID_REFRESH_TOKEN",
+			Msg:          "Invalid refresh token.",
+		},
+	}
+}
+
+// issueTokens returns a new access token and a new refresh token of the given family
+func (s *twitterClone) issueTokens(c *fiber.Ctx, userUUID string, accountID string, familyID string) *models.SignInResponse {
+
+	accessToken, err := s.createJWT(userUUID, accountID, models.PurposeAccess, s.config.JWT.AccessTokenTTL)
+	if err != nil {
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Creating the token failed.",
+			},
+		}
+	}
+
+	refreshToken, err2 := s.createRefreshToken(c.UserContext(), userUUID, accountID, familyID)
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return &models.SignInResponse{BaseResponse: *resp}
+	}
+
+	if err2 != nil {
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Saving the refresh token failed.",
+			},
+		}
+	}
+
+	return &models.SignInResponse{
+		BaseResponse: models.BaseResponse{
+			Success: true,
+		},
+		Token:        accessToken,
+		ExpiresIn:    int(s.config.JWT.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}
+}
+
+// createRefreshToken saves a new refresh token of the family and returns it, only its hash is saved
+func (s *twitterClone) createRefreshToken(ctx context.Context, userUUID string, accountID string, familyID string) (string, error) {
+
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := s.clock.Now()
+
+	err = s.refreshTokens.CreateRefreshToken(ctx, &models.RefreshToken{
+		Hash:       hashToken(refreshToken),
+		Family_ID:  familyID,
+		User_UUID:  userUUID,
+		Account_ID: accountID,
+		Created_At: now,
+		Expires_At: now.Add(s.config.JWT.RefreshTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
+}
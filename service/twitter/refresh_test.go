@@ -0,0 +1,157 @@
+package twitter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newRefreshService(t *testing.T) *testService {
+
+	ts := newTestService(t, Options{})
+
+	ts.route("/signin", func(c *fiber.Ctx) interface{} {
+		var req models.SignInRequest
+		c.BodyParser(&req)
+		return ts.SignIn(c, req)
+	})
+
+	ts.route("/refresh", func(c *fiber.Ctx) interface{} {
+		var req models.RefreshRequest
+		c.BodyParser(&req)
+		return ts.Refresh(c, req)
+	})
+
+	return ts
+}
+
+func (ts *testService) refresh(t *testing.T, refreshToken string) (int, models.SignInResponse) {
+
+	var resp models.SignInResponse
+	status := ts.post(t, "/refresh", models.RefreshRequest{RefreshToken: refreshToken}, &resp)
+
+	return status, resp
+}
+
+// signedInRefreshToken signs the user in and returns the refresh token
+func (ts *testService) signedInRefreshToken(t *testing.T, email string, password string) string {
+
+	_, resp := ts.signIn(t, email, password)
+	if resp.RefreshToken == "" {
+		t.Fatalf("sign in = %s, want the tokens", resp.ResponseType)
+	}
+
+	return resp.RefreshToken
+}
+
+func TestRefreshRotatesTheRefreshToken(t *testing.T) {
+
+	ts := newRefreshService(t)
+	ts.newUser(t, "user@example.com", "right password")
+
+	first := ts.signedInRefreshToken(t, "user@example.com", "right password")
+
+	status, resp := ts.refresh(t, first)
+	if status != http.StatusOK || resp.Token == "" || resp.RefreshToken == "" || resp.RefreshToken == first {
+		t.Fatalf("Refresh = %d %s, want new tokens", status, resp.ResponseType)
+	}
+
+	if _, err := ts.tokens.ParseToken(resp.Token, models.PurposeAccess); err != nil {
+		t.Errorf("ParseToken of the refreshed access token: %v", err)
+	}
+
+	second := resp.RefreshToken
+
+	if _, resp := ts.refresh(t, second); resp.RefreshToken == "" {
+		t.Errorf("Refresh with the new refresh token = %s, want new tokens", resp.ResponseType)
+	}
+}
+
+func TestRefreshReuseRevokesTheFamily(t *testing.T) {
+
+	ts := newRefreshService(t)
+	ts.newUser(t, "user@example.com", "right password")
+
+	first := ts.signedInRefreshToken(t, "user@example.com", "right password")
+	other := ts.signedInRefreshToken(t, "user@example.com", "right password")
+
+	_, resp := ts.refresh(t, first)
+	second := resp.RefreshToken
+
+	// the first token leaked and is presented again
+	if status, resp := ts.refresh(t, first); status != http.StatusUnauthorized || resp.ResponseType != "REFRESH_TOKEN_REUSED" {
+		t.Errorf("Refresh with a used refresh token = %d %s, want 401 REFRESH_TOKEN_REUSED", status, resp.ResponseType)
+	}
+
+	// whoever holds the latest token of the family has to sign in again
+	if status, resp := ts.refresh(t, second); status != http.StatusUnauthorized || resp.ResponseType != "INVALID_REFRESH_TOKEN" {
+		t.Errorf("Refresh with the latest token of a revoked family = %d %s, want 401 INVALID_REFRESH_TOKEN", status, resp.ResponseType)
+	}
+
+	// the other sign ins are another family
+	if _, resp := ts.refresh(t, other); resp.RefreshToken == "" {
+		t.Errorf("Refresh of another sign in = %s, want new tokens", resp.ResponseType)
+	}
+}
+
+func TestRefreshRefusesExpiredTokens(t *testing.T) {
+
+	ts := newRefreshService(t)
+	ts.newUser(t, "user@example.com", "right password")
+
+	refreshToken := ts.signedInRefreshToken(t, "user@example.com", "right password")
+
+	ts.clock.Advance(ts.config.JWT.RefreshTokenTTL)
+
+	if status, resp := ts.refresh(t, refreshToken); status != http.StatusUnauthorized || resp.ResponseType != "INVALID_REFRESH_TOKEN" {
+		t.Errorf("Refresh with an expired refresh token = %d %s, want 401 INVALID_REFRESH_TOKEN", status, resp.ResponseType)
+	}
+
+	if status, resp := ts.refresh(t, "unknown"); status != http.StatusUnauthorized || resp.ResponseType != "INVALID_REFRESH_TOKEN" {
+		t.Errorf("Refresh with an unknown refresh token = %d %s, want 401 INVALID_REFRESH_TOKEN", status, resp.ResponseType)
+	}
+}
+
+func TestRefreshChecksTheRevocationsOfTheUser(t *testing.T) {
+
+	ts := newRefreshService(t)
+	user := ts.newUser(t, "user@example.com", "right password")
+
+	before := ts.signedInRefreshToken(t, "user@example.com", "right password")
+
+	// logging out everywhere
+	ts.clock.Advance(time.Millisecond)
+	if err := ts.revocations.RevokeUser(context.Background(), user.UUID, ts.clock.Now()); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+
+	if status, resp := ts.refresh(t, before); status != http.StatusUnauthorized || resp.ResponseType != "INVALID_REFRESH_TOKEN" {
+		t.Errorf("Refresh with a refresh token from before the revocation = %d %s, want 401 INVALID_REFRESH_TOKEN", status, resp.ResponseType)
+	}
+
+	after := ts.signedInRefreshToken(t, "user@example.com", "right password")
+
+	if _, resp := ts.refresh(t, after); resp.RefreshToken == "" {
+		t.Errorf("Refresh with a refresh token from after the revocation = %s, want new tokens", resp.ResponseType)
+	}
+}
+
+func TestRefreshRefusesTheTokensOfADeletedUser(t *testing.T) {
+
+	ts := newRefreshService(t)
+	user := ts.newUser(t, "user@example.com", "right password")
+
+	refreshToken := ts.signedInRefreshToken(t, "user@example.com", "right password")
+
+	if err := ts.store.DeleteUser(context.Background(), user.UUID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if status, resp := ts.refresh(t, refreshToken); status != http.StatusUnauthorized || resp.ResponseType != "INVALID_REFRESH_TOKEN" {
+		t.Errorf("Refresh of a deleted user = %d %s, want 401 INVALID_REFRESH_TOKEN", status, resp.ResponseType)
+	}
+}
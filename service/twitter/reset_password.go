@@ -1,84 +1,87 @@
 package twitter
 
 import (
-	"net/smtp"
-
 	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/mailer"
 	"github.com/Bruary/twitter-clone/service/models"
 	"github.com/gofiber/fiber/v2"
-	"github.com/jordan-wright/email"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func (*twitterClone) ResetPassword(c *fiber.Ctx, req models.ResetPasswordRequest) *models.BaseResponse {
+func (s *twitterClone) ResetPassword(c *fiber.Ctx, req models.ResetPasswordRequest) *models.BaseResponse {
 
 	// Check if user exists in the db
-	result, err := db.GetDocFromDBUsingEmail(db.UsersCol, req.Email)
-	if err != nil {
-
-		// if user does not exist
-		if err == mongo.ErrNoDocuments {
-
-			c.Status(fiber.StatusNotFound)
+	user, err := s.users.GetUserByEmail(c.UserContext(), req.Email)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
 
-			return &models.BaseResponse{
-				Success:      true,
-				ResponseType: "USER_DOES_NOT_EXIST",
-				Msg:          err.Error(),
-			}
-		}
+	// an unknown email is answered like a known one, so that it tells nothing about which emails have an account
+	if err == db.ErrNotFound {
+		return resetPasswordSent()
+	}
 
-		c.Status(fiber.ErrBadRequest.Code)
+	if err != nil {
 
 		return &models.BaseResponse{
 			Success:      false,
 			ResponseType: "UNKNOWN_ERROR",
-			Msg:          err.Error(),
+			Msg:          "Failed while finding user in db.",
 		}
 	}
 
-	var user models.UserInfo
+	// create the token to be sent in the email, only its hash is saved and the earlier links stop working
+	token, err3 := newOpaqueToken()
+	if err3 != nil {
 
-	// decode the single result into the user variable
-	if err2 := result.Decode(&user); err2 != nil {
-		c.Status(fiber.ErrBadRequest.Code)
 		return &models.BaseResponse{
 			Success:      false,
 			ResponseType: "UNKNOWN_ERROR",
-			Msg:          err2.Error(),
+			Msg:          "Creating the reset token failed.",
 		}
 	}
 
-	// create JWT to be send in the email
-	token, err3 := CreateJWT(user.UUID, user.Account_ID, 15)
-	if err3 != nil {
-		c.Status(fiber.ErrBadRequest.Code)
+	now := s.clock.Now()
+
+	err4 := s.passwordResets.CreatePasswordReset(c.UserContext(), &models.PasswordReset{
+		Hash:       hashToken(token),
+		User_UUID:  user.UUID,
+		Created_At: now,
+		Expires_At: now.Add(s.config.JWT.ResetTokenTTL),
+	})
+	if resp := timeoutResponse(c, err4); resp != nil {
+		return resp
+	}
+
+	if err4 != nil {
 		return &models.BaseResponse{
 			Success:      false,
 			ResponseType: "UNKNOWN_ERROR",
-			Msg:          err3.Error(),
+			Msg:          "Saving the reset token failed.",
 		}
 	}
 
+	s.recordAudit(c, user.UUID, models.AuditPasswordResetRequested)
+
 	// draft the email
-	e := email.Email{
+	e := mailer.Message{
 		To:      []string{user.Email},
-		From:    "Twitter-clone <bruary99@gmail.com>",
 		Subject: "Reset Password",
-		Text:    []byte("Please click on the below link to reset your password: \n" + "http://localhost:4000/api/v1/auth/resetPassword/newPassword?token=" + token),
+		Text:    "Please click on the below link to reset your password: \n" + s.config.HTTP.PublicURL + "/api/v1/auth/resetPassword/newPassword?token=" + token,
 	}
 
-	// send the email
-	err2 := e.Send("smtp.gmail.com:587", smtp.PlainAuth("", "bruary99@gmail.com", "Bakir12345", "smtp.gmail.com"))
+	// send the email, a failure is only logged since answering it would tell that the email has an account
+	err2 := s.mailer.Send(c.UserContext(), e)
 	if err2 != nil {
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "UNKNOWN_ERROR",
-			Msg:          err2.Error(),
-		}
+		s.logger.Println("Sending the reset password email to "+user.Email+" failed:", err2)
 	}
 
+	return resetPasswordSent()
+}
+
+func resetPasswordSent() *models.BaseResponse {
 	return &models.BaseResponse{
-		Success: true,
+		Success:      true,
+		ResponseType: "RESET_EMAIL_SENT",
+		Msg:          "If an account has this email, an email with a link to reset its password was sent.",
 	}
 }
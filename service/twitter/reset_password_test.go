@@ -0,0 +1,143 @@
+package twitter
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/pat"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newResetPasswordService(t *testing.T) *testService {
+
+	ts := newTestService(t, Options{})
+
+	ts.route("/resetPassword", func(c *fiber.Ctx) interface{} {
+		var req models.ResetPasswordRequest
+		c.BodyParser(&req)
+		return ts.ResetPassword(c, req)
+	})
+
+	ts.route("/setNewPassword", func(c *fiber.Ctx) interface{} {
+		var req models.SetNewPasswordRequest
+		c.BodyParser(&req)
+		return ts.SetNewPassword(c, req)
+	})
+
+	return ts
+}
+
+// resetLink asks for a reset of the password of email and returns the token of the link it was sent
+func (ts *testService) resetLink(t *testing.T, email string) string {
+
+	var resp models.BaseResponse
+	ts.post(t, "/resetPassword", models.ResetPasswordRequest{Email: email}, &resp)
+
+	mails := ts.mails.sent()
+	if len(mails) == 0 {
+		t.Fatal("no reset email was sent")
+	}
+
+	text := mails[len(mails)-1].Text
+
+	i := strings.Index(text, "token=")
+	if i < 0 {
+		t.Fatalf("the reset email has no link: %q", text)
+	}
+
+	return strings.TrimSpace(text[i+len("token="):])
+}
+
+func (ts *testService) setNewPassword(t *testing.T, token string, password string) (int, models.PasswordResponse) {
+
+	var resp models.PasswordResponse
+	status := ts.post(t, "/setNewPassword", models.SetNewPasswordRequest{BaseRequest: models.BaseRequest{Token: token}, Password: password}, &resp)
+
+	return status, resp
+}
+
+func TestResetPasswordTellsNothingAboutTheEmail(t *testing.T) {
+
+	ts := newResetPasswordService(t)
+	ts.newUser(t, "user@example.com", "right password")
+
+	var known, unknown models.BaseResponse
+	knownStatus := ts.post(t, "/resetPassword", models.ResetPasswordRequest{Email: "user@example.com"}, &known)
+	unknownStatus := ts.post(t, "/resetPassword", models.ResetPasswordRequest{Email: "nobody@example.com"}, &unknown)
+
+	if knownStatus != unknownStatus || known != unknown {
+		t.Errorf("ResetPassword of an unknown email = %d %+v, want it answered like a known one: %d %+v", unknownStatus, unknown, knownStatus, known)
+	}
+
+	if !known.Success || known.ResponseType != "RESET_EMAIL_SENT" {
+		t.Errorf("ResetPassword = %+v, want RESET_EMAIL_SENT", known)
+	}
+
+	// only the account got an email
+	mails := ts.mails.sent()
+	if len(mails) != 1 || mails[0].To[0] != "user@example.com" {
+		t.Errorf("emails sent = %+v, want one to user@example.com", mails)
+	}
+}
+
+func TestResetLinkWorksOnce(t *testing.T) {
+
+	ts := newResetPasswordService(t)
+	ts.newUser(t, "user@example.com", "old password")
+
+	token := ts.resetLink(t, "user@example.com")
+
+	if status, resp := ts.setNewPassword(t, token, "a new password"); status != http.StatusOK || !resp.Success {
+		t.Fatalf("SetNewPassword = %d %s, want the password set", status, resp.ResponseType)
+	}
+
+	if status, resp := ts.setNewPassword(t, token, "another new password"); status != http.StatusForbidden || resp.ResponseType != "INVALID_TOKEN" {
+		t.Errorf("SetNewPassword with a used link = %d %s, want 403 INVALID_TOKEN", status, resp.ResponseType)
+	}
+
+	user, err := ts.store.GetUserByEmail(context.Background(), "user@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+
+	if !ts.doPasswordsMatch("a new password", user.Password) {
+		t.Error("the password is not the one of the first use of the link")
+	}
+}
+
+func TestResetPasswordRevokesTheSessions(t *testing.T) {
+
+	ts := newResetPasswordService(t)
+	user := ts.newUser(t, "user@example.com", "old password")
+
+	// signed in by whoever knew the old password
+	session := ts.signedIn(t, user)
+	accessToken := ts.newAccessToken(t, user)
+
+	ts.clock.Advance(time.Millisecond)
+
+	if _, resp := ts.setNewPassword(t, ts.resetLink(t, "user@example.com"), "a new password"); !resp.Success {
+		t.Fatalf("SetNewPassword = %s, want the password set", resp.ResponseType)
+	}
+
+	claims, err := ts.tokens.ParseToken(session, models.PurposeAccess)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+
+	revoked, err := ts.revocations.IsRevoked(context.Background(), claims.Id, claims.User_UUID, claims.IssuedAtTime())
+	if err != nil || !revoked {
+		t.Errorf("IsRevoked of a session from before the reset = %v, %v, want it revoked", revoked, err)
+	}
+
+	verifier := pat.NewVerifier(ts.store, ts.store, ts.revocations, ts.clock, log.New(ioutil.Discard, "", 0))
+	if _, err := verifier.Verify(context.Background(), accessToken); err != pat.ErrInvalid {
+		t.Errorf("Verify of a personal access token from before the reset = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,22 @@
+package twitter
+
+import (
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// timeoutResponse returns the response to send when a store call ran out of time, or nil for any other error
+func timeoutResponse(c *fiber.Ctx, err error) *models.BaseResponse {
+	if !db.IsTimeout(err) {
+		return nil
+	}
+
+	c.Status(fiber.StatusGatewayTimeout)
+
+	return &models.BaseResponse{
+		Success:      false,
+		ResponseType: "TIMEOUT",
+		Msg:          "The database took too long to respond, please try again.",
+	}
+}
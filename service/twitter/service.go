@@ -1,11 +1,195 @@
 package twitter
 
-import "github.com/Bruary/twitter-clone/service"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/Bruary/twitter-clone/cache"
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/lockout"
+	"github.com/Bruary/twitter-clone/mailer"
+	"github.com/Bruary/twitter-clone/oidc"
+	"github.com/Bruary/twitter-clone/password"
+	"github.com/Bruary/twitter-clone/revocation"
+	"github.com/Bruary/twitter-clone/service"
+	"github.com/Bruary/twitter-clone/token"
+	"github.com/Bruary/twitter-clone/validate"
+	uuid "github.com/satori/go.uuid"
+)
+
+// IDGenerator creates the IDs of new users, tweets and follows
+type IDGenerator interface {
+	NewUUID() string
+	NewAccountID() (string, error)
+}
+
+// Options holds the dependencies of the service.
+// The stores, the mailer and the token signer are required, the rest fall back to a default when nil.
+type Options struct {
+	Config         *config.Config // defaults to config.Default()
+	Users          db.UserStore
+	Tweets         db.TweetStore
+	Follows        db.FollowStore
+	RefreshTokens  db.RefreshTokenStore
+	PasswordResets db.PasswordResetStore
+	Audit          db.AuditStore
+	TwoFactors     db.TwoFactorStore
+	Identities     db.IdentityStore
+	AccessTokens   db.PersonalAccessTokenStore
+	Revocations    revocation.Store // defaults to an in-process store, only fit for a single instance
+	Lockout        lockout.Store    // defaults to an in-process store, only fit for a single instance
+	Cache          cache.Cache      // defaults to no caching
+	Mailer         mailer.Mailer
+	Clock          clock.Clock // defaults to the wall clock
+	IDs            IDGenerator // defaults to random UUIDs and account IDs
+	Tokens         token.Signer
+	Passwords      password.Hasher          // defaults to the hasher of Config.Password
+	PasswordPolicy *validate.PasswordPolicy // defaults to the lengths of Config.Password, without a blocklist
+	OIDCProviders  []*oidc.Provider         // the logins are signed with Config.OIDC.StateSecret
+	Logger         *log.Logger              // defaults to the standard logger
+}
 
 type twitterClone struct {
+	config         *config.Config
+	users          db.UserStore
+	tweets         db.TweetStore
+	follows        db.FollowStore
+	refreshTokens  db.RefreshTokenStore
+	passwordResets db.PasswordResetStore
+	audit          db.AuditStore
+	twoFactors     db.TwoFactorStore
+	identities     db.IdentityStore
+	accessTokens   db.PersonalAccessTokenStore
+	revocations    revocation.Store
+	lockout        lockout.Store
+	cache          cache.Cache
+	mailer         mailer.Mailer
+	clock          clock.Clock
+	ids            IDGenerator
+	tokens         token.Signer
+	passwords      password.Hasher
+	passwordPolicy *validate.PasswordPolicy
+	oidcProviders  map[string]*oidc.Provider // keyed by name
+	oidcStates     *oidc.States
+	logger         *log.Logger
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
 // NewTwitter: fill the interface with the following struct
-func NewTwitter() service.Service {
-	return &twitterClone{}
+func NewTwitter(opts Options) service.Service {
+
+	if opts.Users == nil || opts.Tweets == nil || opts.Follows == nil {
+		panic("twitter: the users, tweets and follows stores are required")
+	}
+
+	if opts.RefreshTokens == nil || opts.PasswordResets == nil || opts.Audit == nil || opts.TwoFactors == nil || opts.Identities == nil {
+		panic("twitter: the refresh tokens, password resets, audit, two-factor and identity stores are required")
+	}
+
+	if opts.AccessTokens == nil {
+		panic("twitter: the personal access tokens store is required")
+	}
+
+	if opts.Mailer == nil || opts.Tokens == nil {
+		panic("twitter: the mailer and the token signer are required")
+	}
+
+	if opts.Config == nil {
+		opts.Config = config.Default()
+	}
+
+	if opts.Cache == nil {
+		opts.Cache = cache.Nop()
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+
+	if opts.IDs == nil {
+		opts.IDs = randomIDs{}
+	}
+
+	if opts.Revocations == nil {
+		// the revocations of a user must outlive the longest lived token
+		opts.Revocations = revocation.NewMemory(opts.Clock, opts.Config.JWT.RefreshTokenTTL)
+	}
+
+	if opts.Passwords == nil {
+		passwords, err := password.New(passwordParams(opts.Config.Password))
+		if err != nil {
+			panic("twitter: " + err.Error())
+		}
+
+		opts.Passwords = passwords
+	}
+
+	if opts.PasswordPolicy == nil {
+		opts.PasswordPolicy = validate.NewPasswordPolicy(opts.Config.Password.MinLength, opts.Config.Password.MaxLength, nil)
+		opts.PasswordPolicy.MaxBytes = password.MaxBytes(opts.Config.Password.Algorithm)
+	}
+
+	if opts.Lockout == nil {
+		opts.Lockout = lockout.NewMemory(opts.Clock)
+	}
+
+	oidcProviders := map[string]*oidc.Provider{}
+	for _, provider := range opts.OIDCProviders {
+		oidcProviders[provider.Name()] = provider
+	}
+
+	return &twitterClone{
+		config:         opts.Config,
+		users:          opts.Users,
+		tweets:         opts.Tweets,
+		follows:        opts.Follows,
+		refreshTokens:  opts.RefreshTokens,
+		passwordResets: opts.PasswordResets,
+		audit:          opts.Audit,
+		twoFactors:     opts.TwoFactors,
+		identities:     opts.Identities,
+		accessTokens:   opts.AccessTokens,
+		revocations:    opts.Revocations,
+		lockout:        opts.Lockout,
+		cache:          opts.Cache,
+		mailer:         opts.Mailer,
+		clock:          opts.Clock,
+		ids:            opts.IDs,
+		tokens:         opts.Tokens,
+		passwords:      opts.Passwords,
+		passwordPolicy: opts.PasswordPolicy,
+		oidcProviders:  oidcProviders,
+		oidcStates:     oidc.NewStates([]byte(opts.Config.OIDC.StateSecret), opts.Config.OIDC.LoginTTL, opts.Clock),
+		logger:         opts.Logger,
+	}
+}
+
+type randomIDs struct{}
+
+func (randomIDs) NewUUID() string {
+	return uuid.NewV4().String()
+}
+
+func (randomIDs) NewAccountID() (string, error) {
+
+	// Create a random account ID
+	a := make([]byte, 5)
+
+	_, err := rand.Read(a)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper("#" + hex.EncodeToString(a)), nil
 }
@@ -0,0 +1,177 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db/memory"
+	"github.com/Bruary/twitter-clone/mailer"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/token"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testService is the service over the in-memory store with a fake clock, its handlers are served by app
+type testService struct {
+	*twitterClone
+	store *memory.Store
+	clock *clock.Fake
+	mails *mailbox
+	app   *fiber.App
+}
+
+// mailbox keeps the emails instead of sending them
+type mailbox struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *mailbox) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+
+	return nil
+}
+
+// sent returns the emails sent so far
+func (m *mailbox) sent() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]mailer.Message(nil), m.messages...)
+}
+
+// newTestService returns the service with the given options on top of the in-memory defaults,
+// the config defaults to config.Default() with the cheapest bcrypt cost
+func newTestService(t *testing.T, opts Options) *testService {
+
+	store := memory.New()
+	clk := clock.NewFake(time.Now())
+
+	if opts.Config == nil {
+		opts.Config = config.Default()
+		opts.Config.Password.BcryptCost = bcrypt.MinCost
+	}
+
+	keyring, err := token.NewKeyring([]token.Key{{ID: "test", Algorithm: token.HS256, Secret: []byte("test secret")}}, "test", token.Settings{
+		Issuer:   opts.Config.JWT.Issuer,
+		Audience: opts.Config.JWT.Audience,
+		Clock:    clk,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	opts.Users = store
+	opts.Tweets = store
+	opts.Follows = store
+	opts.RefreshTokens = store
+	opts.PasswordResets = store
+	opts.Audit = store
+	opts.TwoFactors = store
+	opts.Identities = store
+	opts.AccessTokens = store
+	mails := &mailbox{}
+
+	opts.Mailer = mails
+	opts.Clock = clk
+	opts.Tokens = keyring
+	opts.Logger = log.New(ioutil.Discard, "", 0)
+
+	return &testService{
+		twitterClone: NewTwitter(opts).(*twitterClone),
+		store:        store,
+		clock:        clk,
+		mails:        mails,
+		app:          fiber.New(),
+	}
+}
+
+// route serves the handler at path, the response it returns is sent as JSON
+func (ts *testService) route(path string, handler func(c *fiber.Ctx) interface{}) {
+	ts.app.Post(path, func(c *fiber.Ctx) error {
+		return c.JSON(handler(c))
+	})
+}
+
+// post sends the request to path as JSON, decodes the response into resp and returns its status
+func (ts *testService) post(t *testing.T, path string, req interface{}, resp interface{}) int {
+	return ts.postAs(t, path, "", req, resp)
+}
+
+// postAs is post with the token in the Authorization header, none when it is empty
+func (ts *testService) postAs(t *testing.T, path string, token string, req interface{}, resp interface{}) int {
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("encoding the request: %v", err)
+	}
+
+	httpReq := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	httpResp, err := ts.app.Test(httpReq, -1)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer httpResp.Body.Close()
+
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		t.Fatalf("decoding the response of %s: %v", path, err)
+	}
+
+	return httpResp.StatusCode
+}
+
+// newUser saves a user with the given email and password
+func (ts *testService) newUser(t *testing.T, email string, password string) *models.UserInfo {
+
+	hash, err := ts.passwords.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	user := &models.UserInfo{
+		UUID:       ts.ids.NewUUID(),
+		Account_ID: ts.ids.NewUUID(),
+		FirstName:  "Test",
+		LastName:   "User",
+		Age:        30,
+		Email:      email,
+		Password:   hash,
+		Created_At: ts.clock.Now(),
+		Updated_At: ts.clock.Now(),
+	}
+
+	if err := ts.store.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	return user
+}
+
+// signedIn returns an access token of the user, like the one of a sign in
+func (ts *testService) signedIn(t *testing.T, user *models.UserInfo) string {
+
+	token, err := ts.createJWT(user.UUID, user.Account_ID, models.PurposeAccess, time.Hour)
+	if err != nil {
+		t.Fatalf("createJWT: %v", err)
+	}
+
+	return token
+}
@@ -3,91 +3,118 @@ package twitter
 import (
 	"github.com/Bruary/twitter-clone/db"
 	"github.com/Bruary/twitter-clone/service/models"
-	"github.com/Bruary/twitter-clone/validate"
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func (*twitterClone) SetNewPassword(c *fiber.Ctx, req models.SetNewPasswordRequest) *models.BaseResponse {
+func (s *twitterClone) SetNewPassword(c *fiber.Ctx, req models.SetNewPasswordRequest) *models.PasswordResponse {
 
-	// Validate token
-	isTokenValid := validate.IsTokenValid(req.Token)
-	if !isTokenValid {
-		c.Status(fiber.StatusForbidden)
-
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "INVALID_TOKEN",
-			Msg:          "Invalid Token.",
-		}
+	// check the password before using up the link, so that a refused password does not cost the link
+	pending, err0 := s.passwordResets.GetPasswordReset(c.UserContext(), hashToken(req.Token))
+	if resp := timeoutResponse(c, err0); resp != nil {
+		return &models.PasswordResponse{BaseResponse: *resp}
 	}
 
-	// Extract claims
-	claims := validate.GetJWTclaims(req.Token)
+	if err0 == nil {
 
-	// // Get user from DB
-	// result, err := db.GetDocFromDBUsingUUID(db.UsersCol, claims.User_UUID)
-	// if err != nil {
-
-	// 	// if user does not exist
-	// 	if err == mongo.ErrNoDocuments {
+		user, err0_5 := s.users.GetUserByUUID(c.UserContext(), pending.User_UUID)
+		if resp := timeoutResponse(c, err0_5); resp != nil {
+			return &models.PasswordResponse{BaseResponse: *resp}
+		}
 
-	// 		c.Status(fiber.StatusNotFound)
+		if err0_5 != nil {
 
-	// 		return &models.BaseResponse{
-	// 			Success:      true,
-	// 			ResponseType: "USER_DOES_NOT_EXIST",
-	// 			Msg:          err.Error(),
-	// 		}
-	// 	}
+			return &models.PasswordResponse{
+				BaseResponse: models.BaseResponse{
+					Success:      false,
+					ResponseType: "UNKNOWN_ERROR",
+					Msg:          "Failed while finding user in db.",
+				},
+			}
+		}
 
-	// 	c.Status(fiber.StatusBadRequest)
+		if resp := s.checkPasswordPolicy(c, req.Password, user.Email, user.FirstName, user.LastName); resp != nil {
+			return resp
+		}
+	}
 
-	// 	return &models.BaseResponse{
-	// 		Success:      false,
-	// 		ResponseType: "UNKNOWN_ERROR",
-	// 		Msg:          err.Error(),
-	// 	}
-	// }
+	// use up the reset token, a link works once
+	reset, err := s.passwordResets.UsePasswordReset(c.UserContext(), hashToken(req.Token))
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.PasswordResponse{BaseResponse: *resp}
+	}
 
-	// var user models.UserInfo
+	if err != nil && err != db.ErrNotFound && err != db.ErrAlreadyUsed {
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Finding the reset token in the db failed.",
+			},
+		}
+	}
 
-	// // decode mongo doc into the user struct
-	// err2 := result.Decode(&user)
-	// if err2 != nil {
+	if err == db.ErrAlreadyUsed || (err == nil && !s.clock.Now().Before(reset.Expires_At)) {
+		s.recordAudit(c, reset.User_UUID, models.AuditPasswordResetRejected)
+	}
 
-	// 	c.Status(fiber.StatusBadRequest)
+	if err != nil || !s.clock.Now().Before(reset.Expires_At) {
+		c.Status(fiber.StatusForbidden)
 
-	// 	return &models.BaseResponse{
-	// 		Success:      false,
-	// 		ResponseType: "UNKNOWN_ERROR",
-	// 		Msg:          err2.Error(),
-	// 	}
-	// }
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "INVALID_TOKEN",
+				Msg:          "Invalid Token.",
+			},
+		}
+	}
 
-	passwordHashedAndSalted, err10_5 := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.MinCost)
+	passwordHashedAndSalted, err10_5 := s.passwords.Hash(req.Password)
 	if err10_5 != nil {
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "UNKNOWN_ERROR",
-			Msg:          "Hashing password failed in SignUp endpoint.",
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Hashing password failed.",
+			},
 		}
 	}
 
-	err3 := db.UpdateUsersPassword(db.UsersCol, claims.User_UUID, string(passwordHashedAndSalted))
-	if err3 != nil {
+	err3 := s.users.UpdatePassword(c.UserContext(), reset.User_UUID, passwordHashedAndSalted)
+	if resp := timeoutResponse(c, err3); resp != nil {
+		return &models.PasswordResponse{BaseResponse: *resp}
+	}
 
-		c.Status(fiber.StatusBadRequest)
+	if err3 != nil {
 
-		return &models.BaseResponse{
-			Success:      false,
-			ResponseType: "UNKNOWN_ERROR",
-			Msg:          err3.Error(),
+		return &models.PasswordResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Saving the new password to the db failed.",
+			},
 		}
 	}
 
-	return &models.BaseResponse{
-		Success: true,
+	s.recordAudit(c, reset.User_UUID, models.AuditPasswordResetCompleted)
+
+	// sign out every session, whoever knew the old password may be signed in
+	err4 := s.revocations.RevokeUser(c.UserContext(), reset.User_UUID, s.clock.Now())
+	if err4 != nil {
+		return &models.PasswordResponse{BaseResponse: *revocationFailed()}
+	}
+
+	err5 := s.revokeAccessTokens(c, reset.User_UUID)
+	if err5 != nil {
+		return &models.PasswordResponse{BaseResponse: *revocationFailed()}
+	}
+
+	s.recordAudit(c, reset.User_UUID, models.AuditSessionsRevoked)
+
+	return &models.PasswordResponse{
+		BaseResponse: models.BaseResponse{
+			Success: true,
+		},
 	}
 }
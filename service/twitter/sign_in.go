@@ -1,16 +1,10 @@
 package twitter
 
 import (
-	"os"
-	"time"
-
 	"github.com/Bruary/twitter-clone/db"
 	"github.com/Bruary/twitter-clone/service/models"
 	"github.com/Bruary/twitter-clone/validate"
-	"github.com/dgrijalva/jwt-go"
-
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // validate user and then sign in if creds are correct (send back token)
@@ -45,35 +39,25 @@ func (s *twitterClone) SignIn(c *fiber.Ctx, req models.SignInRequest) *models.Si
 		}
 	}
 
-	// check if email exists in the db
-	doesUserExist, err2 := db.UserAlreadyExists(db.UsersCol, req.Email)
-	if err2 != nil {
-
-		return &models.SignInResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "UNKNOWN_ERROR",
-				Msg:          "Failed while searching for the User in SignIn endpoint.",
-			},
-		}
+	// the sign ins failing too often are refused before looking at the password,
+	// the others count as failures until they succeed
+	attempt, refused := s.reserveSignIn(c, req.Email)
+	if refused != nil {
+		return refused
 	}
 
-	if !doesUserExist {
-
-		c.Status(fiber.StatusUnauthorized)
+	// Get the user document from the db to check the password later on
+	userDocumentDecoded, err2 := s.users.GetUserByEmail(c.UserContext(), req.Email)
+	// the db failed, not the attempt
+	if err2 != nil && err2 != db.ErrNotFound {
+		s.releaseSignIn(c, attempt)
+	}
 
-		return &models.SignInResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "USER_DOES_NOT_EXIST",
-				Msg:          "User does not exist.",
-			},
-		}
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return &models.SignInResponse{BaseResponse: *resp}
 	}
 
-	// Get the user document from the db to check the password later on
-	userDocument, err2_5 := db.GetDocFromDBUsingEmail(db.UsersCol, req.Email)
-	if err2_5 != nil {
+	if err2 != nil && err2 != db.ErrNotFound {
 
 		return &models.SignInResponse{
 			BaseResponse: models.BaseResponse{
@@ -84,76 +68,51 @@ func (s *twitterClone) SignIn(c *fiber.Ctx, req models.SignInRequest) *models.Si
 		}
 	}
 
-	// Check if the request password matches the one stored in the DB
-	var userDocumentDecoded models.UserInfo
+	// an unknown email is answered like a wrong password, after as long, so that it tells nothing
+	// about which emails have an account
+	if err2 == db.ErrNotFound {
 
-	err2_6 := userDocument.Decode(&userDocumentDecoded)
-	if err2_6 != nil {
+		s.doPasswordsMatch(req.Password, s.dummyPasswordHash())
+		s.signInFailed(c, attempt, nil)
 
-		return &models.SignInResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "UNKNOWN_ERROR",
-				Msg:          "Decoding failed in SignIn endpoint.",
-			},
-		}
+		return invalidCredentials(c)
 	}
 
 	// Check if the received password with hashing matches the one saved in the db
-	isPasswordCorrect := DoPasswordsMatch([]byte(req.Password), userDocumentDecoded.Password)
+	isPasswordCorrect := s.doPasswordsMatch(req.Password, userDocumentDecoded.Password)
 	if !isPasswordCorrect {
 
-		c.Status(fiber.StatusUnauthorized)
+		s.signInFailed(c, attempt, userDocumentDecoded)
 
-		return &models.SignInResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "INVALID_CREDENTIALS",
-				Msg:          "Invalid email or password.",
-			},
-		}
+		return invalidCredentials(c)
 	}
 
-	// If password matches then do the following
+	s.rehashPassword(c, userDocumentDecoded, req.Password)
 
-	// get access secret from the env file
-	jwtKey := os.Getenv("access_secret")
+	// If password matches then do the following
 
-	// create the claims that will be used in the JWT token
-	claims := &models.Claims{
-		User_UUID:  userDocumentDecoded.UUID,
-		Account_ID: userDocumentDecoded.Account_ID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(60 * time.Minute).Unix(),
-		},
+	// the users with two-factor authentication get a challenge to send along with a code instead of the tokens,
+	// their failures are only forgotten once the code is right
+	if challenge := s.twoFactorChallenge(c, userDocumentDecoded); challenge != nil {
+		s.releaseSignIn(c, attempt)
+		return challenge
 	}
 
-	// declaring the token with the method used for signing along with the claims§
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	s.signInSucceeded(c, attempt)
 
-	// Create the JWT string
-	tokenString, err4 := token.SignedString([]byte(jwtKey))
-	if err4 != nil {
+	// every sign in starts a new family of refresh tokens
+	return s.issueTokens(c, userDocumentDecoded.UUID, userDocumentDecoded.Account_ID, s.ids.NewUUID())
+}
 
-		return &models.SignInResponse{
-			BaseResponse: models.BaseResponse{
-				Success:      false,
-				ResponseType: "UNKNOWN_ERROR",
-				Msg:          "Creating the token failed in SignIn endpoint.",
-			},
-		}
-	}
+func invalidCredentials(c *fiber.Ctx) *models.SignInResponse {
+
+	c.Status(fiber.StatusUnauthorized)
 
-	// filing in the final response with the generated token string
 	return &models.SignInResponse{
 		BaseResponse: models.BaseResponse{
-			Success: true,
+			Success:      false,
+			ResponseType: "INVALID_CREDENTIALS",
+			Msg:          "Invalid email or password.",
 		},
-		Token: tokenString,
 	}
 }
-
-func DoPasswordsMatch(password []byte, savedPassword string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(savedPassword), password)
-	return err == nil
-}
@@ -0,0 +1,607 @@
+package twitter
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/totp"
+	"github.com/Bruary/twitter-clone/validate"
+	"github.com/gofiber/fiber/v2"
+)
+
+// recoveryCodesCount is how many recovery codes are handed out when enabling two-factor authentication
+const recoveryCodesCount = 10
+
+// EnrollTwoFactor starts the two-factor authentication of the user with a new secret,
+// it is only enabled once ConfirmTwoFactor checked a first code
+func (s *twitterClone) EnrollTwoFactor(c *fiber.Ctx, req models.BaseRequest) *models.TwoFactorEnrollResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	user, err := s.users.GetUserByUUID(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.TwoFactorEnrollResponse{BaseResponse: *resp}
+	}
+
+	if err != nil {
+
+		return &models.TwoFactorEnrollResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Failed while finding user in db.",
+			},
+		}
+	}
+
+	secret, err2 := totp.NewSecret()
+	if err2 != nil {
+
+		return &models.TwoFactorEnrollResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Creating the secret failed.",
+			},
+		}
+	}
+
+	// enrolling again replaces the pending secret
+	err3 := s.twoFactors.CreateTwoFactor(c.UserContext(), &models.TwoFactor{
+		User_UUID: user.UUID,
+		Secret:    secret,
+	})
+	if resp := timeoutResponse(c, err3); resp != nil {
+		return &models.TwoFactorEnrollResponse{BaseResponse: *resp}
+	}
+
+	if err3 == db.ErrDuplicate {
+		return &models.TwoFactorEnrollResponse{BaseResponse: *twoFactorAlreadyEnabled(c)}
+	}
+
+	if err3 != nil {
+
+		return &models.TwoFactorEnrollResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Saving the secret to the db failed.",
+			},
+		}
+	}
+
+	return &models.TwoFactorEnrollResponse{
+		BaseResponse: models.BaseResponse{
+			Success: true,
+		},
+		Secret:     secret,
+		OTPAuthURI: totp.URI(secret, s.config.TwoFactor.Issuer, user.Email),
+	}
+}
+
+// ConfirmTwoFactor enables the pending two-factor authentication with a first code from the authenticator,
+// the recovery codes are returned once
+func (s *twitterClone) ConfirmTwoFactor(c *fiber.Ctx, req models.TwoFactorConfirmRequest) *models.TwoFactorConfirmResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	codeEmpty := validate.IsStringEmpty(req.Code)
+	if codeEmpty {
+
+		c.Status(fiber.ErrBadRequest.Code)
+
+		return &models.TwoFactorConfirmResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field code is missing, or empty.",
+			},
+		}
+	}
+
+	twoFactor, err := s.twoFactors.GetTwoFactor(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.TwoFactorConfirmResponse{BaseResponse: *resp}
+	}
+
+	if err == db.ErrNotFound {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.TwoFactorConfirmResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "TWO_FACTOR_NOT_ENROLLED",
+				Msg:          "Enroll in two-factor authentication first.",
+			},
+		}
+	}
+
+	if err != nil {
+
+		return &models.TwoFactorConfirmResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Finding the two-factor authentication in the db failed.",
+			},
+		}
+	}
+
+	if twoFactor.Enabled_At != nil {
+		return &models.TwoFactorConfirmResponse{BaseResponse: *twoFactorAlreadyEnabled(c)}
+	}
+
+	if resp := s.checkCode(c, twoFactor, req.Code); resp != nil {
+		return &models.TwoFactorConfirmResponse{BaseResponse: *resp}
+	}
+
+	recoveryCodes, hashes, err2 := newRecoveryCodes()
+	if err2 != nil {
+
+		return &models.TwoFactorConfirmResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Creating the recovery codes failed.",
+			},
+		}
+	}
+
+	err3 := s.twoFactors.EnableTwoFactor(c.UserContext(), twoFactor.User_UUID, s.clock.Now(), hashes)
+	if resp := timeoutResponse(c, err3); resp != nil {
+		return &models.TwoFactorConfirmResponse{BaseResponse: *resp}
+	}
+
+	// a concurrent confirmation got there first
+	if err3 == db.ErrNotFound {
+		return &models.TwoFactorConfirmResponse{BaseResponse: *twoFactorAlreadyEnabled(c)}
+	}
+
+	if err3 != nil {
+
+		return &models.TwoFactorConfirmResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Enabling the two-factor authentication failed.",
+			},
+		}
+	}
+
+	s.recordAudit(c, twoFactor.User_UUID, models.AuditTwoFactorEnabled)
+
+	return &models.TwoFactorConfirmResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      true,
+			ResponseType: "TWO_FACTOR_ENABLED",
+			Msg:          "Two-factor authentication is enabled, keep the recovery codes somewhere safe.",
+		},
+		RecoveryCodes: recoveryCodes,
+	}
+}
+
+// TwoFactorSignIn is the second step of the sign in of the users with two-factor authentication,
+// it trades the challenge token of SignIn and a code for the tokens. A challenge takes a single attempt,
+// a wrong code means signing in again with the password.
+func (s *twitterClone) TwoFactorSignIn(c *fiber.Ctx, req models.TwoFactorSignInRequest) *models.SignInResponse {
+
+	claims, err := s.tokens.ParseToken(req.ChallengeToken, models.PurposeTwoFactor)
+	if err != nil {
+		return invalidChallenge(c)
+	}
+
+	// a password change or logging out everywhere revokes the pending sign ins too
+	revoked, err2 := s.revocations.IsRevoked(c.UserContext(), claims.Id, claims.User_UUID, claims.IssuedAtTime())
+	if err2 != nil {
+		return &models.SignInResponse{BaseResponse: *revocationFailed()}
+	}
+
+	if revoked {
+		return invalidChallenge(c)
+	}
+
+	if validate.IsStringEmpty(req.Code) && validate.IsStringEmpty(req.RecoveryCode) {
+
+		c.Status(fiber.ErrBadRequest.Code)
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "FIELD_MISSING",
+				Msg:          "Field code or recovery_code is missing, or empty.",
+			},
+		}
+	}
+
+	user, err2_5 := s.users.GetUserByUUID(c.UserContext(), claims.User_UUID)
+	if resp := timeoutResponse(c, err2_5); resp != nil {
+		return &models.SignInResponse{BaseResponse: *resp}
+	}
+
+	// deleted since the password step
+	if err2_5 == db.ErrNotFound {
+		return invalidChallenge(c)
+	}
+
+	if err2_5 != nil {
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Failed while finding user in db.",
+			},
+		}
+	}
+
+	// the codes are guessed against the same limits as the passwords
+	attempt, refused := s.reserveSignIn(c, user.Email)
+	if refused != nil {
+		return refused
+	}
+
+	// the challenge is spent whatever the outcome, so that it can not be used to guess the code
+	err3 := s.revocations.RevokeToken(c.UserContext(), claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err3 != nil {
+		s.releaseSignIn(c, attempt)
+
+		return &models.SignInResponse{BaseResponse: *revocationFailed()}
+	}
+
+	twoFactor, err4 := s.twoFactors.GetTwoFactor(c.UserContext(), claims.User_UUID)
+	if err4 != nil || twoFactor.Enabled_At == nil {
+		s.releaseSignIn(c, attempt)
+	}
+
+	if resp := timeoutResponse(c, err4); resp != nil {
+		return &models.SignInResponse{BaseResponse: *resp}
+	}
+
+	// disabled since the password step
+	if err4 == db.ErrNotFound || (err4 == nil && twoFactor.Enabled_At == nil) {
+		return invalidChallenge(c)
+	}
+
+	if err4 != nil {
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Finding the two-factor authentication in the db failed.",
+			},
+		}
+	}
+
+	if resp := s.checkSecondFactor(c, twoFactor, req.Code, req.RecoveryCode); resp != nil {
+
+		// a wrong code counts like a wrong password, a failing db does not
+		if resp.ResponseType == "INVALID_CODE" {
+			s.signInFailed(c, attempt, user)
+		} else {
+			s.releaseSignIn(c, attempt)
+		}
+
+		return &models.SignInResponse{BaseResponse: *resp}
+	}
+
+	s.signInSucceeded(c, attempt)
+
+	// every sign in starts a new family of refresh tokens
+	return s.issueTokens(c, claims.User_UUID, claims.Account_ID, s.ids.NewUUID())
+}
+
+// DisableTwoFactor turns the two-factor authentication off, it takes the password along with a code
+// or a recovery code so that a stolen access token is not enough
+func (s *twitterClone) DisableTwoFactor(c *fiber.Ctx, req models.TwoFactorDisableRequest) *models.BaseResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	passwordEmpty := validate.IsStringEmpty(req.Password)
+	if passwordEmpty {
+
+		c.Status(fiber.ErrBadRequest.Code)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "FIELD_MISSING",
+			Msg:          "Field password is missing, or empty.",
+		}
+	}
+
+	if validate.IsStringEmpty(req.Code) && validate.IsStringEmpty(req.RecoveryCode) {
+
+		c.Status(fiber.ErrBadRequest.Code)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "FIELD_MISSING",
+			Msg:          "Field code or recovery_code is missing, or empty.",
+		}
+	}
+
+	user, err := s.users.GetUserByUUID(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Failed while finding user in db.",
+		}
+	}
+
+	// the password and the code are guessed against the same limits as the sign ins
+	attempt, refused := s.reserveSignIn(c, user.Email)
+	if refused != nil {
+		return &refused.BaseResponse
+	}
+
+	isPasswordCorrect := s.doPasswordsMatch(req.Password, user.Password)
+	if !isPasswordCorrect {
+
+		s.signInFailed(c, attempt, user)
+
+		return invalidPasswordOrCode(c)
+	}
+
+	twoFactor, err2 := s.twoFactors.GetTwoFactor(c.UserContext(), user.UUID)
+	if err2 != nil || twoFactor.Enabled_At == nil {
+		s.releaseSignIn(c, attempt)
+	}
+
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return resp
+	}
+
+	if err2 == db.ErrNotFound || (err2 == nil && twoFactor.Enabled_At == nil) {
+
+		c.Status(fiber.StatusBadRequest)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "TWO_FACTOR_NOT_ENABLED",
+			Msg:          "Two-factor authentication is not enabled.",
+		}
+	}
+
+	if err2 != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Finding the two-factor authentication in the db failed.",
+		}
+	}
+
+	if resp := s.checkSecondFactor(c, twoFactor, req.Code, req.RecoveryCode); resp != nil {
+
+		// a wrong code is answered like a wrong password, so that neither can be guessed on its own
+		if resp.ResponseType == "INVALID_CODE" {
+			s.signInFailed(c, attempt, user)
+			return invalidPasswordOrCode(c)
+		}
+
+		s.releaseSignIn(c, attempt)
+
+		return resp
+	}
+
+	s.signInSucceeded(c, attempt)
+
+	err3 := s.twoFactors.DeleteTwoFactor(c.UserContext(), user.UUID)
+	if resp := timeoutResponse(c, err3); resp != nil {
+		return resp
+	}
+
+	if err3 != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Disabling the two-factor authentication failed.",
+		}
+	}
+
+	s.recordAudit(c, user.UUID, models.AuditTwoFactorDisabled)
+
+	return &models.BaseResponse{
+		Success:      true,
+		ResponseType: "TWO_FACTOR_DISABLED",
+		Msg:          "Two-factor authentication is disabled.",
+	}
+}
+
+// twoFactorChallenge is the response of the password step of SignIn for the users with two-factor authentication,
+// or nil when the user has none enabled
+func (s *twitterClone) twoFactorChallenge(c *fiber.Ctx, user *models.UserInfo) *models.SignInResponse {
+
+	twoFactor, err := s.twoFactors.GetTwoFactor(c.UserContext(), user.UUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return &models.SignInResponse{BaseResponse: *resp}
+	}
+
+	if err == db.ErrNotFound || (err == nil && twoFactor.Enabled_At == nil) {
+		return nil
+	}
+
+	if err != nil {
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Finding the two-factor authentication in the db failed.",
+			},
+		}
+	}
+
+	challengeToken, err2 := s.createJWT(user.UUID, user.Account_ID, models.PurposeTwoFactor, s.config.TwoFactor.ChallengeTTL)
+	if err2 != nil {
+
+		return &models.SignInResponse{
+			BaseResponse: models.BaseResponse{
+				Success:      false,
+				ResponseType: "UNKNOWN_ERROR",
+				Msg:          "Creating the token failed.",
+			},
+		}
+	}
+
+	return &models.SignInResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      true,
+			ResponseType: "TWO_FACTOR_REQUIRED",
+			Msg:          "Send a code from the authenticator, or a recovery code, along with the challenge token.",
+		},
+		ChallengeToken: challengeToken,
+	}
+}
+
+// checkSecondFactor checks the code, or the recovery code when no code is given.
+// It returns the response to send when neither checks out, or nil.
+func (s *twitterClone) checkSecondFactor(c *fiber.Ctx, twoFactor *models.TwoFactor, code string, recoveryCode string) *models.BaseResponse {
+
+	if !validate.IsStringEmpty(code) {
+		return s.checkCode(c, twoFactor, code)
+	}
+
+	err := s.twoFactors.UseRecoveryCode(c.UserContext(), twoFactor.User_UUID, hashToken(normalizeRecoveryCode(recoveryCode)))
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err == db.ErrNotFound {
+		return invalidCode(c)
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Using the recovery code failed.",
+		}
+	}
+
+	s.recordAudit(c, twoFactor.User_UUID, models.AuditRecoveryCodeUsed)
+
+	return nil
+}
+
+// checkCode checks the code against the secret, a code is accepted once
+func (s *twitterClone) checkCode(c *fiber.Ctx, twoFactor *models.TwoFactor, code string) *models.BaseResponse {
+
+	step, ok := totp.Validate(twoFactor.Secret, strings.TrimSpace(code), s.clock.Now())
+	if !ok {
+		return invalidCode(c)
+	}
+
+	err := s.twoFactors.UseTwoFactorStep(c.UserContext(), twoFactor.User_UUID, step)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err == db.ErrAlreadyUsed {
+		return invalidCode(c)
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Saving the code to the db failed.",
+		}
+	}
+
+	return nil
+}
+
+// newRecoveryCodes returns the recovery codes to show the user and their hashes to save
+func newRecoveryCodes() ([]string, []models.RecoveryCode, error) {
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]models.RecoveryCode, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+
+		// 50 random bits, written as xxxxx-xxxxx
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(b)[:10])
+
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, models.RecoveryCode{Hash: hashToken(code)})
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode lets the users type the recovery codes with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func twoFactorAlreadyEnabled(c *fiber.Ctx) *models.BaseResponse {
+
+	c.Status(fiber.StatusConflict)
+
+	return &models.BaseResponse{
+		Success:      false,
+		ResponseType: "TWO_FACTOR_ALREADY_ENABLED",
+		Msg:          "Two-factor authentication is already enabled.",
+	}
+}
+
+func invalidCode(c *fiber.Ctx) *models.BaseResponse {
+
+	c.Status(fiber.StatusUnauthorized)
+
+	return &models.BaseResponse{
+		Success:      false,
+		ResponseType: "INVALID_CODE",
+		Msg:          "Invalid code.",
+	}
+}
+
+func invalidPasswordOrCode(c *fiber.Ctx) *models.BaseResponse {
+
+	c.Status(fiber.StatusUnauthorized)
+
+	return &models.BaseResponse{
+		Success:      false,
+		ResponseType: "INVALID_CREDENTIALS",
+		Msg:          "Invalid password or code.",
+	}
+}
+
+func invalidChallenge(c *fiber.Ctx) *models.SignInResponse {
+
+	c.Status(fiber.StatusUnauthorized)
+
+	return &models.SignInResponse{
+		BaseResponse: models.BaseResponse{
+			Success:      false,
+			ResponseType: "INVALID_CHALLENGE",
+			Msg:          "Invalid or expired challenge, sign in again.",
+		},
+	}
+}
@@ -0,0 +1,241 @@
+package twitter
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/config"
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/totp"
+	"github.com/gofiber/fiber/v2"
+)
+
+// the secret of enableTwoFactor
+const testTwoFactorSecret = "JBSWY3DPEHPK3PXP"
+
+func newTwoFactorService(t *testing.T) *testService {
+
+	ts := newLockoutService(t, config.Lockout{
+		Window:           time.Hour,
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		Duration:         15 * time.Minute,
+	})
+
+	requireAuth := middleware.Auth(ts.tokens, ts.revocations, nil, log.New(ioutil.Discard, "", 0), "")
+
+	ts.app.Post("/2fa/enroll", requireAuth, func(c *fiber.Ctx) error {
+		return c.JSON(ts.EnrollTwoFactor(c, models.BaseRequest{}))
+	})
+
+	ts.app.Post("/2fa/confirm", requireAuth, func(c *fiber.Ctx) error {
+		var req models.TwoFactorConfirmRequest
+		c.BodyParser(&req)
+		return c.JSON(ts.ConfirmTwoFactor(c, req))
+	})
+
+	ts.app.Post("/2fa/disable", requireAuth, func(c *fiber.Ctx) error {
+		var req models.TwoFactorDisableRequest
+		c.BodyParser(&req)
+		return c.JSON(ts.DisableTwoFactor(c, req))
+	})
+
+	return ts
+}
+
+// code returns the code of the secret at the time of the fake clock
+func (ts *testService) code(t *testing.T, secret string) string {
+
+	code, err := totp.Code(secret, ts.clock.Now())
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+
+	return code
+}
+
+func (ts *testService) disableTwoFactor(t *testing.T, user *models.UserInfo, req models.TwoFactorDisableRequest) (int, models.BaseResponse) {
+
+	var resp models.BaseResponse
+	status := ts.postAs(t, "/2fa/disable", ts.signedIn(t, user), req, &resp)
+
+	return status, resp
+}
+
+func TestTwoFactorEnrollAndSignIn(t *testing.T) {
+
+	ts := newTwoFactorService(t)
+	user := ts.newUser(t, "user@example.com", "right password")
+	token := ts.signedIn(t, user)
+
+	var enrolled models.TwoFactorEnrollResponse
+	if ts.postAs(t, "/2fa/enroll", token, models.BaseRequest{}, &enrolled); !enrolled.Success || enrolled.Secret == "" {
+		t.Fatalf("EnrollTwoFactor = %s, want a secret", enrolled.ResponseType)
+	}
+
+	// enrolling alone does not ask for a code
+	if _, resp := ts.signIn(t, "user@example.com", "right password"); resp.Token == "" {
+		t.Fatalf("sign in while enrolling = %s, want the tokens", resp.ResponseType)
+	}
+
+	var confirmed models.TwoFactorConfirmResponse
+	if ts.postAs(t, "/2fa/confirm", token, models.TwoFactorConfirmRequest{Code: "000000"}, &confirmed); confirmed.ResponseType != "INVALID_CODE" {
+		t.Errorf("ConfirmTwoFactor with a wrong code = %s, want INVALID_CODE", confirmed.ResponseType)
+	}
+
+	code := ts.code(t, enrolled.Secret)
+
+	if ts.postAs(t, "/2fa/confirm", token, models.TwoFactorConfirmRequest{Code: code}, &confirmed); confirmed.ResponseType != "TWO_FACTOR_ENABLED" || len(confirmed.RecoveryCodes) != recoveryCodesCount {
+		t.Fatalf("ConfirmTwoFactor = %s with %d recovery codes, want TWO_FACTOR_ENABLED with %d", confirmed.ResponseType, len(confirmed.RecoveryCodes), recoveryCodesCount)
+	}
+
+	_, challenge := ts.signIn(t, "user@example.com", "right password")
+	if challenge.ResponseType != "TWO_FACTOR_REQUIRED" || challenge.Token != "" {
+		t.Fatalf("sign in = %s, want TWO_FACTOR_REQUIRED without the tokens", challenge.ResponseType)
+	}
+
+	// the code of the confirmation was used already
+	var resp models.SignInResponse
+	if ts.post(t, "/2fa/signin", models.TwoFactorSignInRequest{ChallengeToken: challenge.ChallengeToken, Code: code}, &resp); resp.ResponseType != "INVALID_CODE" {
+		t.Errorf("two-factor sign in with a used code = %s, want INVALID_CODE", resp.ResponseType)
+	}
+
+	// a challenge takes a single attempt
+	ts.clock.Advance(30 * time.Second)
+	if ts.post(t, "/2fa/signin", models.TwoFactorSignInRequest{ChallengeToken: challenge.ChallengeToken, Code: ts.code(t, enrolled.Secret)}, &resp); resp.ResponseType != "INVALID_CHALLENGE" {
+		t.Errorf("second attempt of a challenge = %s, want INVALID_CHALLENGE", resp.ResponseType)
+	}
+
+	_, challenge = ts.signIn(t, "user@example.com", "right password")
+	if status := ts.post(t, "/2fa/signin", models.TwoFactorSignInRequest{ChallengeToken: challenge.ChallengeToken, Code: ts.code(t, enrolled.Secret)}, &resp); status != http.StatusOK || resp.Token == "" {
+		t.Errorf("two-factor sign in with the code of the next step = %d %s, want the tokens", status, resp.ResponseType)
+	}
+
+	// a recovery code works once, typed in any case and without the dash
+	recoveryCode := strings.ToUpper(strings.Replace(confirmed.RecoveryCodes[0], "-", "", 1))
+
+	_, challenge = ts.signIn(t, "user@example.com", "right password")
+	if status, resp := ts.twoFactorSignIn(t, challenge.ChallengeToken, recoveryCode); status != http.StatusOK || resp.Token == "" {
+		t.Errorf("two-factor sign in with a recovery code = %d %s, want the tokens", status, resp.ResponseType)
+	}
+
+	_, challenge = ts.signIn(t, "user@example.com", "right password")
+	if _, resp := ts.twoFactorSignIn(t, challenge.ChallengeToken, recoveryCode); resp.ResponseType != "INVALID_CODE" {
+		t.Errorf("two-factor sign in with a used recovery code = %s, want INVALID_CODE", resp.ResponseType)
+	}
+}
+
+func TestDisableTwoFactorRefusesAWrongPasswordOrCode(t *testing.T) {
+
+	for _, tt := range []struct {
+		name string
+		req  func(t *testing.T, ts *testService) models.TwoFactorDisableRequest
+	}{
+		{"wrong password", func(t *testing.T, ts *testService) models.TwoFactorDisableRequest {
+			return models.TwoFactorDisableRequest{Password: "wrong password", Code: ts.code(t, testTwoFactorSecret)}
+		}},
+		{"wrong code", func(t *testing.T, ts *testService) models.TwoFactorDisableRequest {
+			return models.TwoFactorDisableRequest{Password: "right password", Code: "000000"}
+		}},
+		{"wrong recovery code", func(t *testing.T, ts *testService) models.TwoFactorDisableRequest {
+			return models.TwoFactorDisableRequest{Password: "right password", RecoveryCode: "wrong-code"}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTwoFactorService(t)
+			user := ts.newUser(t, "user@example.com", "right password")
+			ts.enableTwoFactor(t, user, "right-code")
+
+			// a wrong password or a wrong code tells nothing about the other one
+			status, resp := ts.disableTwoFactor(t, user, tt.req(t, ts))
+			if status != http.StatusUnauthorized || resp.ResponseType != "INVALID_CREDENTIALS" || resp.Msg != "Invalid password or code." {
+				t.Errorf("DisableTwoFactor = %d %s %q, want 401 INVALID_CREDENTIALS", status, resp.ResponseType, resp.Msg)
+			}
+
+			if twoFactor, err := ts.store.GetTwoFactor(context.Background(), user.UUID); err != nil || twoFactor.Enabled_At == nil {
+				t.Errorf("GetTwoFactor after the refused disable = %v, want it still enabled", err)
+			}
+
+			attempts, _ := ts.lockout.Attempts(context.Background(), accountLockoutKey(user.Email))
+			if attempts.Failures != 1 {
+				t.Errorf("failures after the refused disable = %d, want 1", attempts.Failures)
+			}
+		})
+	}
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+
+	for _, tt := range []struct {
+		name string
+		req  func(t *testing.T, ts *testService) models.TwoFactorDisableRequest
+	}{
+		{"code", func(t *testing.T, ts *testService) models.TwoFactorDisableRequest {
+			return models.TwoFactorDisableRequest{Password: "right password", Code: ts.code(t, testTwoFactorSecret)}
+		}},
+		{"recovery code", func(t *testing.T, ts *testService) models.TwoFactorDisableRequest {
+			return models.TwoFactorDisableRequest{Password: "right password", RecoveryCode: "RIGHTCODE"}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTwoFactorService(t)
+			user := ts.newUser(t, "user@example.com", "right password")
+			ts.enableTwoFactor(t, user, "right-code")
+
+			// the failures before are forgotten like after a sign in
+			ts.signIn(t, "user@example.com", "wrong password")
+
+			if status, resp := ts.disableTwoFactor(t, user, tt.req(t, ts)); status != http.StatusOK || resp.ResponseType != "TWO_FACTOR_DISABLED" {
+				t.Fatalf("DisableTwoFactor = %d %s, want TWO_FACTOR_DISABLED", status, resp.ResponseType)
+			}
+
+			if _, err := ts.store.GetTwoFactor(context.Background(), user.UUID); err != db.ErrNotFound {
+				t.Errorf("GetTwoFactor after disabling = %v, want ErrNotFound", err)
+			}
+
+			attempts, _ := ts.lockout.Attempts(context.Background(), accountLockoutKey(user.Email))
+			if attempts.Failures != 0 {
+				t.Errorf("failures after disabling = %d, want none", attempts.Failures)
+			}
+
+			if _, resp := ts.signIn(t, "user@example.com", "right password"); resp.Token == "" {
+				t.Errorf("sign in after disabling = %s, want the tokens", resp.ResponseType)
+			}
+		})
+	}
+}
+
+func TestDisableTwoFactorLocksTheAccount(t *testing.T) {
+
+	ts := newTwoFactorService(t)
+	user := ts.newUser(t, "user@example.com", "right password")
+	ts.enableTwoFactor(t, user, "right-code")
+
+	// whoever holds a stolen access token guesses the password, then the code
+	ts.disableTwoFactor(t, user, models.TwoFactorDisableRequest{Password: "guess 1", Code: "000000"})
+	ts.disableTwoFactor(t, user, models.TwoFactorDisableRequest{Password: "guess 2", Code: "000000"})
+	ts.disableTwoFactor(t, user, models.TwoFactorDisableRequest{Password: "right password", Code: "000000"})
+
+	status, resp := ts.disableTwoFactor(t, user, models.TwoFactorDisableRequest{Password: "right password", Code: ts.code(t, testTwoFactorSecret)})
+	if status != http.StatusTooManyRequests || resp.ResponseType != "ACCOUNT_LOCKED" {
+		t.Errorf("DisableTwoFactor after three failures = %d %s, want 429 ACCOUNT_LOCKED", status, resp.ResponseType)
+	}
+
+	if twoFactor, err := ts.store.GetTwoFactor(context.Background(), user.UUID); err != nil || twoFactor.Enabled_At == nil {
+		t.Errorf("GetTwoFactor after the lockout = %v, want it still enabled", err)
+	}
+
+	// the lock holds for the sign ins too
+	if _, resp := ts.signIn(t, "user@example.com", "right password"); resp.ResponseType != "ACCOUNT_LOCKED" {
+		t.Errorf("sign in after the lockout = %s, want ACCOUNT_LOCKED", resp.ResponseType)
+	}
+}
@@ -0,0 +1,249 @@
+package twitter
+
+import (
+	"fmt"
+
+	"github.com/Bruary/twitter-clone/db"
+	"github.com/Bruary/twitter-clone/mailer"
+	"github.com/Bruary/twitter-clone/middleware"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/Bruary/twitter-clone/validate"
+	"github.com/gofiber/fiber/v2"
+)
+
+// VerifyEmail verifies the email of the account with the link sent at signup
+func (s *twitterClone) VerifyEmail(c *fiber.Ctx) *models.BaseResponse {
+
+	claims, err := s.tokens.ParseToken(c.Query("token"), models.PurposeEmailVerify)
+	if err != nil {
+
+		c.Status(fiber.StatusForbidden)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "INVALID_TOKEN",
+			Msg:          "Invalid or expired verification link.",
+		}
+	}
+
+	user, err2 := s.users.GetUserByUUID(c.UserContext(), claims.User_UUID)
+	if resp := timeoutResponse(c, err2); resp != nil {
+		return resp
+	}
+
+	if err2 == db.ErrNotFound {
+
+		c.Status(fiber.StatusNotFound)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "USER_DOES_NOT_EXIST",
+			Msg:          "The account was deleted.",
+		}
+	}
+
+	if err2 != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Failed while finding user in db.",
+		}
+	}
+
+	// the link keeps working, following it again changes nothing
+	if user.Verified_At != nil {
+
+		return &models.BaseResponse{
+			Success:      true,
+			ResponseType: "EMAIL_VERIFIED",
+			Msg:          "The email is verified.",
+		}
+	}
+
+	return s.setEmailVerified(c, user.UUID, models.AuditEmailVerified)
+}
+
+// ResendVerificationEmail sends a new verification link to the email of the account
+func (s *twitterClone) ResendVerificationEmail(c *fiber.Ctx, req models.BaseRequest) *models.BaseResponse {
+
+	// claims of the token verified by the auth middleware
+	tokenClaims := middleware.Claims(c)
+
+	user, err := s.users.GetUserByUUID(c.UserContext(), tokenClaims.User_UUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Failed while finding user in db.",
+		}
+	}
+
+	if user.Verified_At != nil {
+
+		c.Status(fiber.StatusConflict)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "EMAIL_ALREADY_VERIFIED",
+			Msg:          "The email is already verified.",
+		}
+	}
+
+	err2 := s.sendVerificationEmail(c, user)
+	if err2 != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Sending the verification email failed.",
+		}
+	}
+
+	return &models.BaseResponse{
+		Success: true,
+	}
+}
+
+// AdminVerifyEmail lets an admin verify the email of an account without the link
+func (s *twitterClone) AdminVerifyEmail(c *fiber.Ctx, req models.AdminVerifyEmailRequest) *models.BaseResponse {
+
+	emailEmpty := validate.IsStringEmpty(req.Email)
+	if emailEmpty {
+
+		c.Status(fiber.ErrBadRequest.Code)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "FIELD_MISSING",
+			Msg:          "Field email is missing, or empty.",
+		}
+	}
+
+	user, err := s.users.GetUserByEmail(c.UserContext(), req.Email)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err == db.ErrNotFound {
+
+		c.Status(fiber.StatusNotFound)
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "USER_DOES_NOT_EXIST",
+			Msg:          "No account has this email.",
+		}
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Failed while finding user in db.",
+		}
+	}
+
+	return s.setEmailVerified(c, user.UUID, models.AuditEmailVerifiedByAdmin)
+}
+
+func (s *twitterClone) setEmailVerified(c *fiber.Ctx, userUUID string, auditType string) *models.BaseResponse {
+
+	err := s.users.SetEmailVerified(c.UserContext(), userUUID, s.clock.Now())
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Saving the verification to the db failed.",
+		}
+	}
+
+	s.recordAudit(c, userUUID, auditType)
+
+	return &models.BaseResponse{
+		Success:      true,
+		ResponseType: "EMAIL_VERIFIED",
+		Msg:          "The email is verified.",
+	}
+}
+
+// sendVerificationEmail emails a signed link verifying the email of the user
+func (s *twitterClone) sendVerificationEmail(c *fiber.Ctx, user *models.UserInfo) error {
+
+	token, err := s.createJWT(user.UUID, user.Account_ID, models.PurposeEmailVerify, s.config.Verification.TTL)
+	if err != nil {
+		return err
+	}
+
+	// draft the email
+	e := mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Verify your email",
+		Text:    "Please click on the below link to verify your email: \n" + s.config.HTTP.PublicURL + "/api/v1/auth/verifyEmail?token=" + token,
+	}
+
+	return s.mailer.Send(c.UserContext(), e)
+}
+
+// requireVerified returns the response to send when the action needs a verified email and the user has none, or nil
+func (s *twitterClone) requireVerified(c *fiber.Ctx, userUUID string, action string) *models.BaseResponse {
+
+	if !s.verificationRequired(action) {
+		return nil
+	}
+
+	user, err := s.users.GetUserByUUID(c.UserContext(), userUUID)
+	if resp := timeoutResponse(c, err); resp != nil {
+		return resp
+	}
+
+	if err != nil {
+
+		return &models.BaseResponse{
+			Success:      false,
+			ResponseType: "UNKNOWN_ERROR",
+			Msg:          "Failed while finding user in db.",
+		}
+	}
+
+	return s.notVerified(c, user, action)
+}
+
+// notVerified is requireVerified for a user at hand
+func (s *twitterClone) notVerified(c *fiber.Ctx, user *models.UserInfo, action string) *models.BaseResponse {
+
+	if user.Verified_At != nil || !s.verificationRequired(action) {
+		return nil
+	}
+
+	c.Status(fiber.StatusForbidden)
+
+	return &models.BaseResponse{
+		Success:      false,
+		ResponseType: "EMAIL_NOT_VERIFIED",
+		Msg:          fmt.Sprintf("Verify your email first, it is required to %s.", action),
+	}
+}
+
+// verificationRequired reports whether the unverified accounts are kept from the action
+func (s *twitterClone) verificationRequired(action string) bool {
+
+	for _, required := range s.config.Verification.RequiredFor {
+		if required == action {
+			return true
+		}
+	}
+
+	return false
+}
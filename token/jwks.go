@@ -0,0 +1,60 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JWKS is the JSON Web Key Set of the keyring, the public keys other services verify our tokens with
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public key of the JWKS (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"` // OKP
+	X         string `json:"x,omitempty"`   // OKP
+	N         string `json:"n,omitempty"`   // RSA
+	E         string `json:"e,omitempty"`   // RSA
+}
+
+// JWKS returns the public keys that still verify, the retired ones until their grace period is over.
+// The HS256 keys are secrets and are never published.
+func (k *Keyring) JWKS() JWKS {
+
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, key := range k.keys {
+
+		if key.Algorithm == HS256 || !k.verifies(key) {
+			continue
+		}
+
+		jwk := JWK{
+			KeyID:     key.ID,
+			Algorithm: key.Algorithm,
+			Use:       "sig",
+		}
+
+		switch publicKey := key.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
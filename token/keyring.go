@@ -0,0 +1,185 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// the supported signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// DefaultKeyID is the key verifying the tokens without a kid header, they were signed before the keyring
+const DefaultKeyID = "default"
+
+// Key is a signing key of the keyring
+type Key struct {
+	ID         string        // the kid header of the tokens it signs
+	Algorithm  string        // HS256, RS256 or EdDSA
+	Secret     []byte        // the secret of an HS256 key
+	PrivateKey crypto.Signer // the *rsa.PrivateKey of an RS256 key, or the ed25519.PrivateKey of an EdDSA key
+	RetiredAt  time.Time     // zero while the key is in use, then it only verifies for the grace period
+}
+
+// Keyring signs the tokens with its signing key and verifies them with the key named by their kid header
+type Keyring struct {
+	keys     map[string]Key
+	signing  Key
+	settings Settings
+}
+
+var _ Signer = (*Keyring)(nil)
+
+// NewKeyring returns a keyring signing with the key signingKeyID, which must not be retired
+func NewKeyring(keys []Key, signingKeyID string, settings Settings) (*Keyring, error) {
+
+	if settings.Clock == nil {
+		settings.Clock = clock.Real()
+	}
+
+	keyring := &Keyring{
+		keys:     map[string]Key{},
+		settings: settings,
+	}
+
+	for _, key := range keys {
+
+		if key.ID == "" {
+			return nil, fmt.Errorf("a key has no ID")
+		}
+
+		if _, found := keyring.keys[key.ID]; found {
+			return nil, fmt.Errorf("key %q is there twice", key.ID)
+		}
+
+		if err := checkKey(key); err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, err)
+		}
+
+		keyring.keys[key.ID] = key
+	}
+
+	signing, found := keyring.keys[signingKeyID]
+	if !found {
+		return nil, fmt.Errorf("the signing key %q is not in the keyring", signingKeyID)
+	}
+
+	if !signing.RetiredAt.IsZero() {
+		return nil, fmt.Errorf("the signing key %q is retired", signingKeyID)
+	}
+
+	keyring.signing = signing
+
+	return keyring, nil
+}
+
+// checkKey makes sure the key material fits the algorithm
+func checkKey(key Key) error {
+
+	switch key.Algorithm {
+	case HS256:
+		if len(key.Secret) == 0 {
+			return fmt.Errorf("an HS256 key needs a secret")
+		}
+
+		return nil
+
+	case RS256:
+		if _, ok := key.PrivateKey.(*rsa.PrivateKey); !ok {
+			return fmt.Errorf("an RS256 key needs an RSA private key")
+		}
+
+		return nil
+
+	case EdDSA:
+		if _, ok := key.PrivateKey.(ed25519.PrivateKey); !ok {
+			return fmt.Errorf("an EdDSA key needs an Ed25519 private key")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported algorithm %q, expected %s, %s or %s", key.Algorithm, HS256, RS256, EdDSA)
+}
+
+// SigningKeyID is the kid of the new tokens
+func (k *Keyring) SigningKeyID() string {
+	return k.signing.ID
+}
+
+func (k *Keyring) Sign(claims *models.Claims) (string, error) {
+
+	claims.Issuer = k.settings.Issuer
+	claims.Audience = k.settings.Audience
+
+	// declaring the token with the method used for signing along with the claims
+	token := jwt.NewWithClaims(signingMethod(k.signing.Algorithm), claims)
+	token.Header["kid"] = k.signing.ID
+
+	if k.signing.Algorithm == HS256 {
+		return token.SignedString(k.signing.Secret)
+	}
+
+	return token.SignedString(k.signing.PrivateKey)
+}
+
+func (k *Keyring) ParseToken(tokenString string, purpose models.TokenPurpose) (*models.Claims, error) {
+	return parse(tokenString, purpose, k.keyfunc, k.settings)
+}
+
+// keyfunc returns the key named by the kid header of the token, as long as it still verifies
+// and the token was signed with its algorithm
+func (k *Keyring) keyfunc(t *jwt.Token) (interface{}, error) {
+
+	kid := DefaultKeyID
+	if header, found := t.Header["kid"]; found {
+		kid, _ = header.(string)
+	}
+
+	key, found := k.keys[kid]
+	if !found {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	if !k.verifies(key) {
+		return nil, fmt.Errorf("key %q retired", kid)
+	}
+
+	// pin the algorithm, the header of the token must not choose how it is verified
+	if t.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing algorithm %v for key %q", t.Header["alg"], kid)
+	}
+
+	if key.Algorithm == HS256 {
+		return key.Secret, nil
+	}
+
+	return key.PrivateKey.Public(), nil
+}
+
+// verifies reports whether the key is in use or retired for less than the grace period
+func (k *Keyring) verifies(key Key) bool {
+	return key.RetiredAt.IsZero() || k.settings.Clock.Now().Before(key.RetiredAt.Add(k.settings.GracePeriod))
+}
+
+func signingMethod(algorithm string) jwt.SigningMethod {
+
+	switch algorithm {
+	case RS256:
+		return jwt.SigningMethodRS256
+	case EdDSA:
+		return SigningMethodEdDSA
+	}
+
+	return jwt.SigningMethodHS256
+}
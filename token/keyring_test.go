@@ -0,0 +1,403 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	testIssuer   = "twitter-clone"
+	testAudience = "twitter-clone-api"
+	testSecret   = "a secret of at least 16 characters"
+)
+
+// testKeys are an HS256, an RS256 and an EdDSA key
+type testKeys struct {
+	hs256 Key
+	rs256 Key
+	eddsa Key
+}
+
+func newTestKeys(t *testing.T) testKeys {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating the RSA key: %v", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating the Ed25519 key: %v", err)
+	}
+
+	return testKeys{
+		hs256: Key{ID: "hs", Algorithm: HS256, Secret: []byte(testSecret)},
+		rs256: Key{ID: "rs", Algorithm: RS256, PrivateKey: rsaKey},
+		eddsa: Key{ID: "ed", Algorithm: EdDSA, PrivateKey: edKey},
+	}
+}
+
+func newTestKeyring(t *testing.T, clk clock.Clock, signingKeyID string, keys ...Key) *Keyring {
+
+	keyring, err := NewKeyring(keys, signingKeyID, Settings{Issuer: testIssuer, Audience: testAudience, GracePeriod: time.Hour, Clock: clk})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	return keyring
+}
+
+// accessClaims are the claims of an access token issued at now for an hour
+func accessClaims(now time.Time) *models.Claims {
+	return &models.Claims{
+		User_UUID: "user",
+		Purpose:   models.PurposeAccess,
+		StandardClaims: jwt.StandardClaims{
+			Id:        "token",
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Hour).Unix(),
+		},
+	}
+}
+
+// signRaw signs the claims with the method and the key as they are, setting the kid header when it is not empty
+func signRaw(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing the token: %v", err)
+	}
+
+	return signed
+}
+
+func TestSignAndParse(t *testing.T) {
+
+	clk := clock.NewFake(time.Now())
+	keys := newTestKeys(t)
+
+	for _, key := range []Key{keys.hs256, keys.rs256, keys.eddsa} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+
+			keyring := newTestKeyring(t, clk, key.ID, key)
+
+			signed, err := keyring.Sign(accessClaims(clk.Now()))
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(signed, &models.Claims{})
+			if err != nil || parsed.Header["kid"] != key.ID || parsed.Header["alg"] != key.Algorithm {
+				t.Errorf("Sign = header %v, %v, want the kid %q and the alg %q", parsed.Header, err, key.ID, key.Algorithm)
+			}
+
+			claims, err := keyring.ParseToken(signed, models.PurposeAccess)
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+
+			if claims.User_UUID != "user" || claims.Issuer != testIssuer || claims.Audience != testAudience {
+				t.Errorf("ParseToken = %+v, want the claims signed with the issuer and the audience", claims)
+			}
+		})
+	}
+}
+
+func TestParseTokenRefusesInvalidTokens(t *testing.T) {
+
+	clk := clock.NewFake(time.Now())
+	keys := newTestKeys(t)
+	keyring := newTestKeyring(t, clk, keys.hs256.ID, keys.hs256, keys.rs256, keys.eddsa)
+
+	now := clk.Now()
+	rsaPublicKey := x509.MarshalPKCS1PublicKey(&keys.rs256.PrivateKey.(*rsa.PrivateKey).PublicKey)
+
+	stamped := func(claims *models.Claims) *models.Claims {
+		claims.Issuer = testIssuer
+		claims.Audience = testAudience
+		return claims
+	}
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{
+			"alg none",
+			signRaw(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, keys.hs256.ID, stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			// the public key of an RS256 key used as an HS256 secret
+			"HS256 for an RS256 key",
+			signRaw(t, jwt.SigningMethodHS256, rsaPublicKey, keys.rs256.ID, stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			// verifies with the same secret, only the pinning refuses it
+			"HS512 for an HS256 key",
+			signRaw(t, jwt.SigningMethodHS512, keys.hs256.Secret, keys.hs256.ID, stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			"PS256 for an RS256 key",
+			signRaw(t, jwt.SigningMethodPS256, keys.rs256.PrivateKey, keys.rs256.ID, stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			"RS256 for an HS256 key",
+			signRaw(t, jwt.SigningMethodRS256, keys.rs256.PrivateKey, keys.hs256.ID, stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			"RS256 for an EdDSA key",
+			signRaw(t, jwt.SigningMethodRS256, keys.rs256.PrivateKey, keys.eddsa.ID, stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			"another secret",
+			signRaw(t, jwt.SigningMethodHS256, []byte("another secret of 16 characters"), keys.hs256.ID, stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			"unknown kid",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, "unknown", stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			// the default key is not in the keyring
+			"no kid",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, "", stamped(accessClaims(now))),
+			ErrInvalid,
+		},
+		{
+			"another issuer",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, keys.hs256.ID, func() *models.Claims {
+				claims := stamped(accessClaims(now))
+				claims.Issuer = "someone else"
+				return claims
+			}()),
+			ErrInvalid,
+		},
+		{
+			"no issuer",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, keys.hs256.ID, func() *models.Claims {
+				claims := stamped(accessClaims(now))
+				claims.Issuer = ""
+				return claims
+			}()),
+			ErrInvalid,
+		},
+		{
+			"another audience",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, keys.hs256.ID, func() *models.Claims {
+				claims := stamped(accessClaims(now))
+				claims.Audience = "another api"
+				return claims
+			}()),
+			ErrInvalid,
+		},
+		{
+			"not valid yet",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, keys.hs256.ID, func() *models.Claims {
+				claims := stamped(accessClaims(now))
+				claims.NotBefore = now.Add(time.Minute).Unix()
+				return claims
+			}()),
+			ErrInvalid,
+		},
+		{
+			"expired",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, keys.hs256.ID, stamped(accessClaims(now.Add(-2*time.Hour)))),
+			ErrExpired,
+		},
+		{
+			"no expiry",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, keys.hs256.ID, func() *models.Claims {
+				claims := stamped(accessClaims(now))
+				claims.ExpiresAt = 0
+				return claims
+			}()),
+			ErrExpired,
+		},
+		{
+			"reset link",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, keys.hs256.ID, func() *models.Claims {
+				claims := stamped(accessClaims(now))
+				claims.Purpose = models.PurposePasswordReset
+				return claims
+			}()),
+			ErrWrongPurpose,
+		},
+		{
+			"no purpose",
+			signRaw(t, jwt.SigningMethodHS256, keys.hs256.Secret, keys.hs256.ID, func() *models.Claims {
+				claims := stamped(accessClaims(now))
+				claims.Purpose = ""
+				return claims
+			}()),
+			ErrWrongPurpose,
+		},
+		{
+			"malformed",
+			"not.a.token",
+			ErrInvalid,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keyring.ParseToken(tt.token, models.PurposeAccess); !errors.Is(err, tt.want) {
+				t.Errorf("ParseToken = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokensWithoutKidUseTheDefaultKey(t *testing.T) {
+
+	clk := clock.NewFake(time.Now())
+	keys := newTestKeys(t)
+
+	legacy := Key{ID: DefaultKeyID, Algorithm: HS256, Secret: []byte("the former JWT secret")}
+	keyring := newTestKeyring(t, clk, keys.eddsa.ID, keys.eddsa, legacy)
+
+	claims := accessClaims(clk.Now())
+	claims.Issuer = testIssuer
+	claims.Audience = testAudience
+
+	signed := signRaw(t, jwt.SigningMethodHS256, legacy.Secret, "", claims)
+
+	if _, err := keyring.ParseToken(signed, models.PurposeAccess); err != nil {
+		t.Errorf("ParseToken of a token signed before the keyring: %v", err)
+	}
+}
+
+func TestRetiredKeysVerifyForTheGracePeriod(t *testing.T) {
+
+	clk := clock.NewFake(time.Now())
+	keys := newTestKeys(t)
+
+	// the tokens signed with the old key are still around when it is retired
+	old := newTestKeyring(t, clk, keys.rs256.ID, keys.rs256)
+	signed, err := old.Sign(accessClaims(clk.Now()))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	retired := keys.rs256
+	retired.RetiredAt = clk.Now()
+
+	keyring := newTestKeyring(t, clk, keys.eddsa.ID, keys.eddsa, retired)
+
+	// the new tokens are signed with the new key
+	signedNew, _ := keyring.Sign(accessClaims(clk.Now()))
+	if parsed, _, _ := new(jwt.Parser).ParseUnverified(signedNew, &models.Claims{}); parsed.Header["kid"] != keys.eddsa.ID {
+		t.Errorf("Sign after the rotation = kid %v, want %q", parsed.Header["kid"], keys.eddsa.ID)
+	}
+
+	clk.Advance(time.Hour - time.Second)
+
+	if _, err := keyring.ParseToken(signed, models.PurposeAccess); err != nil {
+		t.Errorf("ParseToken within the grace period: %v", err)
+	}
+
+	if len(keyring.JWKS().Keys) != 2 {
+		t.Errorf("JWKS within the grace period = %+v, want the retired key too", keyring.JWKS())
+	}
+
+	clk.Advance(time.Second)
+
+	if _, err := keyring.ParseToken(signed, models.PurposeAccess); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseToken after the grace period = %v, want ErrInvalid", err)
+	}
+
+	if jwks := keyring.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != keys.eddsa.ID {
+		t.Errorf("JWKS after the grace period = %+v, want only the signing key", jwks)
+	}
+}
+
+func TestNewKeyringChecksTheKeys(t *testing.T) {
+
+	keys := newTestKeys(t)
+
+	retired := keys.hs256
+	retired.RetiredAt = time.Now()
+
+	for _, tt := range []struct {
+		name    string
+		keys    []Key
+		signing string
+	}{
+		{"retired signing key", []Key{retired}, retired.ID},
+		{"unknown signing key", []Key{keys.hs256}, "unknown"},
+		{"key twice", []Key{keys.hs256, keys.hs256}, keys.hs256.ID},
+		{"no ID", []Key{{Algorithm: HS256, Secret: []byte(testSecret)}}, ""},
+		{"HS256 without a secret", []Key{{ID: "hs", Algorithm: HS256}}, "hs"},
+		{"RS256 with an Ed25519 key", []Key{{ID: "rs", Algorithm: RS256, PrivateKey: keys.eddsa.PrivateKey}}, "rs"},
+		{"EdDSA with an RSA key", []Key{{ID: "ed", Algorithm: EdDSA, PrivateKey: keys.rs256.PrivateKey}}, "ed"},
+		{"unsupported algorithm", []Key{{ID: "es", Algorithm: "ES256", Secret: []byte(testSecret)}}, "es"},
+	} {
+		if _, err := NewKeyring(tt.keys, tt.signing, Settings{}); err == nil {
+			t.Errorf("NewKeyring with %s succeeded", tt.name)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+
+	keys := newTestKeys(t)
+	keyring := newTestKeyring(t, clock.NewFake(time.Now()), keys.hs256.ID, keys.hs256, keys.rs256, keys.eddsa)
+
+	jwks := keyring.JWKS()
+
+	published := map[string]JWK{}
+	for _, jwk := range jwks.Keys {
+		published[jwk.KeyID] = jwk
+	}
+
+	// the secrets are never published
+	if _, found := published[keys.hs256.ID]; found || len(published) != 2 {
+		t.Fatalf("JWKS = %+v, want the RS256 and the EdDSA keys only", jwks)
+	}
+
+	rsaKey := keys.rs256.PrivateKey.(*rsa.PrivateKey).PublicKey
+	want := JWK{
+		KeyType:   "RSA",
+		KeyID:     keys.rs256.ID,
+		Algorithm: RS256,
+		Use:       "sig",
+		N:         base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}
+	if published[keys.rs256.ID] != want {
+		t.Errorf("JWK of the RS256 key = %+v, want %+v", published[keys.rs256.ID], want)
+	}
+
+	want = JWK{
+		KeyType:   "OKP",
+		KeyID:     keys.eddsa.ID,
+		Algorithm: EdDSA,
+		Use:       "sig",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(keys.eddsa.PrivateKey.Public().(ed25519.PublicKey)),
+	}
+	if published[keys.eddsa.ID] != want {
+		t.Errorf("JWK of the EdDSA key = %+v, want %+v", published[keys.eddsa.ID], want)
+	}
+}
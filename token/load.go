@@ -0,0 +1,122 @@
+package token
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Bruary/twitter-clone/config"
+)
+
+// keyringFile is the JSON file of JWT_KEYS_FILE, e.g.
+//
+//	{
+//		"signing_key": "2026-10",
+//		"keys": [
+//			{"kid": "2026-10", "alg": "EdDSA", "private_key_file": "2026-10.pem"},
+//			{"kid": "2026-04", "alg": "RS256", "private_key_file": "2026-04.pem", "retired_at": "2026-10-01T00:00:00Z"},
+//			{"kid": "default", "alg": "HS256", "secret": "...", "retired_at": "2026-10-01T00:00:00Z"}
+//		]
+//	}
+type keyringFile struct {
+	SigningKey string `json:"signing_key"`
+	Keys       []struct {
+		ID             string     `json:"kid"`
+		Algorithm      string     `json:"alg"`
+		Secret         string     `json:"secret"`           // HS256
+		PrivateKeyFile string     `json:"private_key_file"` // RS256 and EdDSA, a PEM file relative to the keyring file
+		RetiredAt      *time.Time `json:"retired_at"`
+	} `json:"keys"`
+}
+
+// LoadKeyring reads the keyring file at path, see keyringFile for its format
+func LoadKeyring(path string, settings Settings) (*Keyring, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the keyring: %w", err)
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("parsing the keyring %s: %w", path, err)
+	}
+
+	keys := make([]Key, 0, len(file.Keys))
+
+	for _, entry := range file.Keys {
+
+		// held to the same length as JWT_ACCESS_SECRET
+		if entry.Algorithm == HS256 && len(entry.Secret) < config.JWTSecretMinLength {
+			return nil, fmt.Errorf("key %q: the secret of an HS256 key should have at least %d characters", entry.ID, config.JWTSecretMinLength)
+		}
+
+		key := Key{
+			ID:        entry.ID,
+			Algorithm: entry.Algorithm,
+			Secret:    []byte(entry.Secret),
+		}
+
+		if entry.RetiredAt != nil {
+			key.RetiredAt = *entry.RetiredAt
+		}
+
+		if entry.PrivateKeyFile != "" {
+
+			keyPath := entry.PrivateKeyFile
+			if !filepath.IsAbs(keyPath) {
+				keyPath = filepath.Join(filepath.Dir(path), keyPath)
+			}
+
+			key.PrivateKey, err = readPrivateKey(keyPath)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	keyring, err := NewKeyring(keys, file.SigningKey, settings)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+
+	return keyring, nil
+}
+
+// readPrivateKey reads a PKCS #8 or PKCS #1 private key from a PEM file,
+// as written by "openssl genpkey -algorithm ed25519" or "openssl genrsa"
+func readPrivateKey(path string) (crypto.Signer, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s holds no signing key", path)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,101 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/service/models"
+)
+
+func writeFile(t *testing.T, path string, content []byte) {
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+
+	dir := t.TempDir()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeFile(t, filepath.Join(dir, "rs.pem"), pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "ed.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+
+	writeFile(t, filepath.Join(dir, "keys.json"), []byte(`{
+		"signing_key": "ed",
+		"keys": [
+			{"kid": "ed", "alg": "EdDSA", "private_key_file": "ed.pem"},
+			{"kid": "rs", "alg": "RS256", "private_key_file": "rs.pem", "retired_at": "2026-10-01T00:00:00Z"},
+			{"kid": "default", "alg": "HS256", "secret": "`+testSecret+`", "retired_at": "2026-10-01T00:00:00Z"}
+		]
+	}`))
+
+	clk := clock.NewFake(time.Date(2026, 10, 1, 0, 30, 0, 0, time.UTC))
+
+	keyring, err := LoadKeyring(filepath.Join(dir, "keys.json"), Settings{Issuer: testIssuer, Audience: testAudience, GracePeriod: time.Hour, Clock: clk})
+	if err != nil {
+		t.Fatalf("LoadKeyring: %v", err)
+	}
+
+	if keyring.SigningKeyID() != "ed" {
+		t.Errorf("SigningKeyID = %q, want ed", keyring.SigningKeyID())
+	}
+
+	signed, err := keyring.Sign(accessClaims(clk.Now()))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	if _, err := keyring.ParseToken(signed, models.PurposeAccess); err != nil {
+		t.Errorf("ParseToken: %v", err)
+	}
+
+	// the retired RSA key is published until its grace period is over, the secret never
+	if jwks := keyring.JWKS(); len(jwks.Keys) != 2 {
+		t.Errorf("JWKS = %+v, want the EdDSA and the RS256 keys", jwks)
+	}
+}
+
+func TestLoadKeyringRefusesShortSecrets(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeFile(t, path, []byte(`{"signing_key": "hs", "keys": [{"kid": "hs", "alg": "HS256", "secret": "short secret"}]}`))
+
+	_, err := LoadKeyring(path, Settings{})
+	if err == nil || !strings.Contains(err.Error(), "at least 16 characters") {
+		t.Errorf("LoadKeyring with a secret of 12 characters = %v, want it refused", err)
+	}
+}
+
+func TestLoadKeyringRefusesMissingKeys(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys.json")
+	writeFile(t, path, []byte(`{"signing_key": "ed", "keys": [{"kid": "ed", "alg": "EdDSA", "private_key_file": "missing.pem"}]}`))
+
+	if _, err := LoadKeyring(path, Settings{}); err == nil {
+		t.Error("LoadKeyring with a missing PEM file succeeded")
+	}
+
+	writeFile(t, filepath.Join(dir, "ed.pem"), []byte("not a PEM file"))
+	writeFile(t, path, []byte(`{"signing_key": "ed", "keys": [{"kid": "ed", "alg": "EdDSA", "private_key_file": "ed.pem"}]}`))
+
+	if _, err := LoadKeyring(path, Settings{}); err == nil {
+		t.Error("LoadKeyring with a file that is not PEM succeeded")
+	}
+}
@@ -0,0 +1,79 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Bruary/twitter-clone/clock"
+	"github.com/Bruary/twitter-clone/service/models"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	// ErrInvalid is returned for a token that is malformed, not signed by us, or not meant for us
+	ErrInvalid = errors.New("token: invalid")
+
+	// ErrExpired is returned for a token past its expiry
+	ErrExpired = errors.New("token: expired")
+
+	// ErrWrongPurpose is returned for a valid token issued for another purpose, like a reset link used to sign in
+	ErrWrongPurpose = errors.New("token: wrong purpose")
+)
+
+// Signer signs the JWTs handed out to clients and verifies the ones they send back
+type Signer interface {
+
+	// Sign stamps the issuer and the audience on the claims and signs them
+	Sign(claims *models.Claims) (string, error)
+
+	// ParseToken verifies the signature, the algorithm, the expiry, the issuer, the audience and
+	// the purpose of the token, the claims are only returned when all of them check out
+	ParseToken(tokenString string, purpose models.TokenPurpose) (*models.Claims, error)
+}
+
+// Settings are the claims every token carries and every parsed token is checked against
+type Settings struct {
+	Issuer      string
+	Audience    string
+	GracePeriod time.Duration // how long a retired key still verifies the tokens it signed
+	Clock       clock.Clock   // defaults to the wall clock
+}
+
+// parse verifies the signature with the key returned by keyfunc, then checks the claims against the settings
+func parse(tokenString string, purpose models.TokenPurpose, keyfunc jwt.Keyfunc, settings Settings) (*models.Claims, error) {
+
+	claims := &models.Claims{}
+
+	// the claims are checked below against our clock
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+
+	_, err := parser.ParseWithClaims(tokenString, claims, keyfunc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	now := settings.Clock.Now().Unix()
+
+	if !claims.VerifyExpiresAt(now, true) {
+		return nil, ErrExpired
+	}
+
+	if !claims.VerifyNotBefore(now, false) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalid)
+	}
+
+	if !claims.VerifyIssuer(settings.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalid, claims.Issuer)
+	}
+
+	if !claims.VerifyAudience(settings.Audience, true) {
+		return nil, fmt.Errorf("%w: unexpected audience %q", ErrInvalid, claims.Audience)
+	}
+
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("%w: got a %q token, expected %q", ErrWrongPurpose, claims.Purpose, purpose)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,121 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the secret of the test vectors of RFC 6238, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+
+	// the SHA1 vectors of the appendix B, cut down to 6 digits
+	for _, tt := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		code, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+
+		if code != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+
+		step, ok := Validate(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/period {
+			t.Errorf("Validate of %s at %d = %d %v, want step %d", tt.code, tt.unix, step, ok, tt.unix/period)
+		}
+	}
+}
+
+func TestValidateAllowsOneStepOfSkew(t *testing.T) {
+
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / period
+
+	for _, tt := range []struct {
+		offset int64 // steps the code is off from now
+		ok     bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	} {
+		code, _ := Code(rfcSecret, now.Add(time.Duration(tt.offset*period)*time.Second))
+
+		step, ok := Validate(rfcSecret, code, now)
+		if ok != tt.ok {
+			t.Errorf("Validate of a code %d steps off = %v, want %v", tt.offset, ok, tt.ok)
+		}
+
+		// the step of the code, not the current one, so that it can not be replayed in the next step
+		if ok && step != current+tt.offset {
+			t.Errorf("Validate of a code %d steps off = step %d, want %d", tt.offset, step, current+tt.offset)
+		}
+	}
+}
+
+func TestValidateRefusesMalformedCodes(t *testing.T) {
+
+	now := time.Unix(59, 0)
+
+	for _, tt := range []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"8 digits", rfcSecret, "94287082"},
+		{"5 digits", rfcSecret, "87082"},
+		{"empty", rfcSecret, ""},
+		{"secret not in base32", "not base32!", "287082"},
+	} {
+		if _, ok := Validate(tt.secret, tt.code, now); ok {
+			t.Errorf("Validate of %s succeeded", tt.name)
+		}
+	}
+
+	// the secret is accepted in lower case, as some apps copy it
+	if _, ok := Validate(strings.ToLower(rfcSecret), "287082", now); !ok {
+		t.Error("Validate with the secret in lower case failed")
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret: %v", err)
+	}
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("NewSecret = %s, want 160 bits in base32", secret)
+	}
+}
+
+func TestURI(t *testing.T) {
+
+	parsed, err := url.Parse(URI(rfcSecret, "Twitter Clone", "user@example.com"))
+	if err != nil {
+		t.Fatalf("parsing the URI: %v", err)
+	}
+
+	query := parsed.Query()
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" || query.Get("secret") != rfcSecret || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("URI = %s, want an otpauth TOTP URI of the secret", parsed)
+	}
+}
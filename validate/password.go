@@ -0,0 +1,155 @@
+package validate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// the rules of the password policy
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RulePersonalInfo = "personal_info"
+	RuleCommon       = "common"
+)
+
+// personalInfoMinLength is the shortest part of the email or the name looked for in the passwords,
+// shorter ones would refuse too many good passwords
+const personalInfoMinLength = 3
+
+// PasswordViolation is a rule of the password policy a password breaks
+type PasswordViolation struct {
+	Rule string `json:"rule"`
+	Msg  string `json:"msg"`
+}
+
+// PasswordPolicy is what the passwords of the users must look like
+type PasswordPolicy struct {
+	MinLength int // in characters, not bytes
+	MaxLength int
+	MaxBytes  int // 0 for no limit, bcrypt only hashes the first 72 bytes of a password
+	common    map[string]struct{}
+}
+
+// NewPasswordPolicy returns a policy refusing the passwords out of the lengths and the common ones,
+// which are compared regardless of the case
+func NewPasswordPolicy(minLength int, maxLength int, common []string) *PasswordPolicy {
+
+	policy := &PasswordPolicy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		common:    make(map[string]struct{}, len(common)),
+	}
+
+	for _, password := range common {
+		policy.common[strings.ToLower(password)] = struct{}{}
+	}
+
+	return policy
+}
+
+// LoadPasswordList reads a list of common or breached passwords, one per line,
+// the empty lines and the lines starting with # are skipped
+func LoadPasswordList(path string) ([]string, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the password list: %w", err)
+	}
+	defer file.Close()
+
+	var passwords []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		passwords = append(passwords, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading the password list: %w", err)
+	}
+
+	return passwords, nil
+}
+
+// Check returns every rule the password breaks, none when it is fine. The personal info is the
+// email and the names of the user, the password must not contain any of them.
+func (p *PasswordPolicy) Check(password string, personalInfo ...string) []PasswordViolation {
+
+	var violations []PasswordViolation
+
+	length := utf8.RuneCountInString(password)
+
+	if length < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Rule: RuleMinLength,
+			Msg:  fmt.Sprintf("Password should have at least %d characters.", p.MinLength),
+		})
+	}
+
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Rule: RuleMaxLength,
+			Msg:  fmt.Sprintf("Password should have at most %d characters.", p.MaxLength),
+		})
+	} else if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, PasswordViolation{
+			Rule: RuleMaxLength,
+			Msg:  fmt.Sprintf("Password should have at most %d bytes, accented letters and symbols take several.", p.MaxBytes),
+		})
+	}
+
+	lowered := strings.ToLower(password)
+
+	for _, part := range personalParts(personalInfo) {
+		if strings.Contains(lowered, part) {
+			violations = append(violations, PasswordViolation{
+				Rule: RulePersonalInfo,
+				Msg:  "Password should not contain your email or your name.",
+			})
+
+			break
+		}
+	}
+
+	if _, found := p.common[lowered]; found {
+		violations = append(violations, PasswordViolation{
+			Rule: RuleCommon,
+			Msg:  "Password is too common, it is among the passwords attackers try first.",
+		})
+	}
+
+	return violations
+}
+
+// personalParts splits the emails at the @ so that the part before it is looked for on its own
+func personalParts(personalInfo []string) []string {
+
+	var parts []string
+
+	for _, info := range personalInfo {
+
+		info = strings.ToLower(strings.TrimSpace(info))
+
+		candidates := []string{info}
+		if at := strings.LastIndex(info, "@"); at >= 0 {
+			candidates = append(candidates, info[:at])
+		}
+
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= personalInfoMinLength {
+				parts = append(parts, candidate)
+			}
+		}
+	}
+
+	return parts
+}
@@ -0,0 +1,90 @@
+package validate
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rules returns the rules of the violations in order
+func rules(violations []PasswordViolation) []string {
+
+	var broken []string
+	for _, violation := range violations {
+		broken = append(broken, violation.Rule)
+	}
+
+	return broken
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+
+	policy := NewPasswordPolicy(8, 64, []string{"Password123", "letmein!!"})
+	policy.MaxBytes = 72
+
+	for _, tt := range []struct {
+		name         string
+		password     string
+		personalInfo []string
+		want         []string
+	}{
+		{"fine", "correct horse battery", nil, nil},
+		{"too short", "short", nil, []string{RuleMinLength}},
+		{"8 characters", "abcdefgh", nil, nil},
+		// the length is in characters, 7 of them take 14 bytes
+		{"short in characters", strings.Repeat("é", 7), nil, []string{RuleMinLength}},
+		{"64 characters", strings.Repeat("a", 64), nil, nil},
+		{"too long", strings.Repeat("a", 65), nil, []string{RuleMaxLength}},
+		{"72 bytes", strings.Repeat("é", 36), nil, nil},
+		{"73 bytes", strings.Repeat("é", 36) + "a", nil, []string{RuleMaxLength}},
+		// both limits are broken, the violation is reported once
+		{"too long in both", strings.Repeat("é", 65), nil, []string{RuleMaxLength}},
+		{"common", "password123", nil, []string{RuleCommon}},
+		{"common in another case", "LETMEIN!!", nil, []string{RuleCommon}},
+		{"email", "ada@example.com!", []string{"ada@example.com"}, []string{RulePersonalInfo}},
+		{"part of the email", "my name is Lovelace42", []string{"lovelace42@example.com"}, []string{RulePersonalInfo}},
+		{"name", "ilovelovelace", []string{"ada@example.com", "Ada", "Lovelace"}, []string{RulePersonalInfo}},
+		// shorter parts would refuse too many good passwords
+		{"short name", "adamant password", []string{"x@example.com", "Ad"}, nil},
+		{"several rules", "ada", []string{"ada@example.com"}, []string{RuleMinLength, RulePersonalInfo}},
+	} {
+		got := rules(policy.Check(tt.password, tt.personalInfo...))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Check = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyWithoutLimits(t *testing.T) {
+
+	policy := NewPasswordPolicy(0, 0, nil)
+
+	if violations := policy.Check(strings.Repeat("é", 1000)); len(violations) != 0 {
+		t.Errorf("Check without limits = %+v, want none", violations)
+	}
+}
+
+func TestLoadPasswordList(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "passwords.txt")
+
+	content := "# the most common passwords\n123456\n\n  password  \n#comment\nqwerty\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+
+	passwords, err := LoadPasswordList(path)
+	if err != nil {
+		t.Fatalf("LoadPasswordList: %v", err)
+	}
+
+	if want := []string{"123456", "password", "qwerty"}; !reflect.DeepEqual(passwords, want) {
+		t.Errorf("LoadPasswordList = %q, want %q", passwords, want)
+	}
+
+	if _, err := LoadPasswordList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadPasswordList of a missing file succeeded")
+	}
+}